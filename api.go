@@ -33,6 +33,18 @@ type Route struct {
 	// Meta is optional field to put arbitrary data about the route.
 	// E.g. the list of users who are allowed to use the route.
 	Meta map[string]interface{}
+
+	// Middleware is optional middleware of the route. It is called inside
+	// the middleware passed to BindRoutes in AddMiddleware.
+	Middleware Middleware
+
+	// MaxBody limits the size of request body. If it is not set, the value
+	// passed to BindRoutes in MaxBody is used.
+	MaxBody int64
+
+	// Idempotent marks the route as safe to be retried by the client even if
+	// its HTTP method is not idempotent (e.g. POST). See RetryPolicy.
+	Idempotent bool
 }
 
 // Transport converts back and forth between HTTP and Request, Response types.
@@ -93,8 +105,7 @@ var (
 func validateRequestResponse(structType reflect.Type, request bool, path string) {
 	var jsonFields, bodyFields, statusFields []string
 	urlKeys := []string{}
-	for i := 0; i < structType.NumField(); i++ {
-		field := structType.Field(i)
+	for _, field := range flattenFields(structType) {
 		hasJson := field.Tag.Get("json") != ""
 		hasUseAsBody := field.Tag.Get("use_as_body") == "true"
 		hasUseAsStatus := field.Tag.Get("use_as_status") == "true"
@@ -150,6 +161,9 @@ func validateRequestResponse(structType reflect.Type, request bool, path string)
 		if sum > 1 {
 			panic(fmt.Sprintf("field %s of struct %s: hasJson=%v, hasUseAsBody=%v, hasUseAsStatus=%v, hasQuery=%v, hasHeader=%v, hasCookie=%v, hasUrl=%v want at most one to be true", field.Name, structType.Name(), hasJson, hasUseAsBody, hasUseAsStatus, hasQuery, hasHeader, hasCookie, hasUrl))
 		}
+		if (hasUseAsBody || hasUseAsStatus) && len(field.Index) != 1 {
+			panic(fmt.Sprintf("field %s of struct %s: hasUseAsBody=%v, hasUseAsStatus=%v, but they can not be used in embedded structs with query, header, cookie or url fields", field.Name, structType.Name(), hasUseAsBody, hasUseAsStatus))
+		}
 		if hasUseAsStatus && request {
 			panic(fmt.Sprintf("field %s of struct %s: hasUseAsStatus=%v, but HTTP status can only be set in responses", field.Name, structType.Name(), hasUseAsStatus))
 		}
@@ -0,0 +1,180 @@
+package api2test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httputil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/starius/api2"
+)
+
+// UpdateGoldenEnv is the environment variable making CheckWireFormat rewrite
+// golden files instead of comparing with them, e.g.
+//
+//	API2_UPDATE_GOLDEN=1 go test ./...
+const UpdateGoldenEnv = "API2_UPDATE_GOLDEN"
+
+// Sample is a request of a route and the result of its handler rendered by
+// CheckWireFormat.
+type Sample struct {
+	// Name of the golden file without extension. Defaults to the name of
+	// the method. Set it if a route has several samples.
+	Name string
+
+	// Request is a pointer to the request type of the route.
+	Request interface{}
+
+	// Response is a pointer to the response type of the route returned by
+	// the handler. If it is nil, the handler returns Error and the route is
+	// found by the type of Request.
+	Response interface{}
+	Error    error
+}
+
+// CheckWireFormat renders the HTTP request sent by the client for each
+// sample and the HTTP response produced by the server for it: the method,
+// the URL with the query, headers, cookies and the body. It compares them
+// with files <dir>/<name>.golden and fails the test if they differ. This
+// catches changes of the wire format made by changes of tags or transports.
+// If environment variable API2_UPDATE_GOLDEN is set, the files are written.
+// Options are passed to both the client and BindRoutes.
+func CheckWireFormat(t testing.TB, dir string, routes []api2.Route, samples []Sample, opts ...api2.Option) {
+	t.Helper()
+	update := os.Getenv(UpdateGoldenEnv) != ""
+	seen := make(map[string]bool, len(samples))
+	for i, sample := range samples {
+		got, name, err := renderWire(routes, sample, opts)
+		if err != nil {
+			t.Errorf("api2test: sample %d: %v", i, err)
+			continue
+		}
+		if seen[name] {
+			t.Errorf("api2test: sample %d: duplicate golden file %s, set Sample.Name", i, name)
+			continue
+		}
+		seen[name] = true
+
+		file := filepath.Join(dir, name+".golden")
+		if update {
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				t.Fatalf("api2test: failed to create dir %s: %v", dir, err)
+			}
+			if err := os.WriteFile(file, got, 0644); err != nil {
+				t.Fatalf("api2test: failed to write %s: %v", file, err)
+			}
+			continue
+		}
+		want, err := os.ReadFile(file)
+		if err != nil {
+			t.Errorf("api2test: failed to read golden file (run with %s=1 to create it): %v", UpdateGoldenEnv, err)
+			continue
+		}
+		if !bytes.Equal(got, want) {
+			t.Errorf("api2test: wire format of %s changed (run with %s=1 to update %s):\n--- got:\n%s\n--- want:\n%s", name, UpdateGoldenEnv, file, got, want)
+		}
+	}
+}
+
+func renderWire(routes []api2.Route, sample Sample, opts []api2.Option) ([]byte, string, error) {
+	// Replace the handler of the route with one returning the sample.
+	routes = append([]api2.Route(nil), routes...)
+	var route *api2.Route
+	for i := range routes {
+		handlerType := handlerType(routes[i].Handler)
+		if handlerType.In(1) == reflect.TypeOf(sample.Request) && (sample.Response == nil || handlerType.Out(0) == reflect.TypeOf(sample.Response)) {
+			route = &routes[i]
+			break
+		}
+	}
+	if route == nil {
+		return nil, "", fmt.Errorf("no route with request %T and response %T", sample.Request, sample.Response)
+	}
+	name := sample.Name
+	if name == "" {
+		var err error
+		name, err = api2.MethodName(route.Handler)
+		if err != nil {
+			return nil, "", err
+		}
+	}
+	handlerType := handlerType(route.Handler)
+	route.Handler = reflect.MakeFunc(handlerType, func(args []reflect.Value) []reflect.Value {
+		res := reflect.Zero(handlerType.Out(0))
+		if sample.Response != nil {
+			res = reflect.ValueOf(sample.Response)
+		}
+		err := reflect.Zero(errorType)
+		if sample.Error != nil {
+			err = reflect.ValueOf(sample.Error)
+		}
+		return []reflect.Value{res, err}
+	}).Interface()
+
+	// Other routes may have nil handlers, e.g. from GetRoutes(nil).
+	mux := http.NewServeMux()
+	api2.BindRoutes(mux, []api2.Route{*route}, append(opts[:len(opts):len(opts)], api2.ErrorLogger(func(format string, args ...interface{}) {}))...)
+	wire := &wireClient{handler: mux}
+	client := api2.NewClient(routes, "http://api2.test", append(opts[:len(opts):len(opts)], api2.CustomClient(wire))...)
+	defer client.Close()
+
+	response := reflect.New(handlerType.Out(0).Elem()).Interface()
+	err := client.Call(context.Background(), response, sample.Request)
+	if wire.request == nil {
+		return nil, "", fmt.Errorf("the client failed to send the request: %w", err)
+	}
+	if sample.Error == nil && err != nil {
+		return nil, "", fmt.Errorf("the client failed to decode the response: %w", err)
+	}
+	var buf bytes.Buffer
+	buf.WriteString("=== request ===\n")
+	buf.Write(wire.request)
+	buf.WriteString("\n=== response ===\n")
+	buf.Write(wire.response)
+	buf.WriteString("\n")
+	return []byte(strings.ReplaceAll(buf.String(), "\r\n", "\n")), name, nil
+}
+
+// wireClient passes requests to the handler and dumps requests and
+// responses.
+type wireClient struct {
+	handler http.Handler
+
+	request  []byte
+	response []byte
+}
+
+func (c *wireClient) Do(req *http.Request) (*http.Response, error) {
+	dump, err := httputil.DumpRequest(req, true)
+	if err != nil {
+		return nil, err
+	}
+	c.request = dump
+
+	r := req.Clone(req.Context())
+	r.RequestURI = req.URL.RequestURI()
+	if r.Body == nil {
+		r.Body = http.NoBody
+	}
+	rec := httptest.NewRecorder()
+	c.handler.ServeHTTP(rec, r)
+
+	res := rec.Result()
+	res.ContentLength = int64(rec.Body.Len())
+	dump, err = httputil.DumpResponse(res, true)
+	if err != nil {
+		return nil, err
+	}
+	c.response = dump
+	return res, nil
+}
+
+func (c *wireClient) CloseIdleConnections() {
+}
@@ -0,0 +1,90 @@
+package api2test
+
+import (
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/starius/api2/errors"
+	"github.com/starius/api2/example"
+	"github.com/stretchr/testify/require"
+)
+
+func exampleSamples() []Sample {
+	return []Sample{
+		{
+			Request:  &example.HelloRequest{Key: "k 1"},
+			Response: &example.HelloResponse{Session: "s1"},
+		},
+		{
+			Request: &example.EchoRequest{
+				User:    "alice",
+				Session: "s1",
+				Text:    "hi",
+				Dir:     example.East,
+				Maps:    map[string]example.Direction{"b": example.South, "a": example.North},
+			},
+			Response: &example.EchoResponse{Text: "hi", Color: example.ColorGreen},
+		},
+		{
+			Name:    "EchoNotFound",
+			Request: &example.EchoRequest{User: "nobody"},
+			Error:   errors.NotFound("no such user"),
+		},
+		{
+			Request: &example.StreamRequest{
+				Session: "s1",
+				Body:    io.NopCloser(strings.NewReader("up")),
+			},
+			Response: &example.StreamResponse{Body: io.NopCloser(strings.NewReader("down"))},
+		},
+		{
+			Request:  &example.RedirectRequest{ID: "42"},
+			Response: &example.RedirectResponse{Status: http.StatusFound, URL: "https://example.com/42"},
+		},
+		{
+			Request:  &example.RawRequest{Token: []byte("token")},
+			Response: &example.RawResponse{Token: []byte("raw")},
+		},
+	}
+}
+
+func TestCheckWireFormat(t *testing.T) {
+	CheckWireFormat(t, "testdata", example.GetRoutes(nil), exampleSamples())
+}
+
+func TestCheckWireFormatDetectsChanges(t *testing.T) {
+	dir := t.TempDir()
+	samples := exampleSamples()[:1]
+
+	t.Setenv(UpdateGoldenEnv, "1")
+	CheckWireFormat(t, dir, example.GetRoutes(nil), samples)
+	golden, err := os.ReadFile(filepath.Join(dir, "Hello.golden"))
+	require.NoError(t, err)
+	require.Equal(t, "=== request ===\n"+
+		"POST /hello?key=k+1 HTTP/1.1\n"+
+		"Host: api2.test\n"+
+		"Accept: application/json\n"+
+		"Content-Type: application/json; charset=UTF-8\n"+
+		"\n"+
+		"{}\n"+
+		"\n"+
+		"=== response ===\n"+
+		"HTTP/1.1 200 OK\n"+
+		"Content-Length: 3\n"+
+		"Content-Type: application/json; charset=UTF-8\n"+
+		"Session: s1\n"+
+		"\n"+
+		"{}\n"+
+		"\n", string(golden))
+
+	t.Setenv(UpdateGoldenEnv, "")
+	samples[0].Response = &example.HelloResponse{Session: "s2"}
+	rt := &recordingT{TB: t}
+	CheckWireFormat(rt, dir, example.GetRoutes(nil), samples)
+	require.Len(t, rt.errors, 1)
+	require.Contains(t, rt.errors[0], "wire format of %s changed")
+}
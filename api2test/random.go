@@ -0,0 +1,280 @@
+package api2test
+
+import (
+	"encoding"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// source is a source of random numbers. It is either math/rand or the input
+// of a fuzz test.
+type source interface {
+	Uint64() uint64
+}
+
+// bytesSource returns numbers read from the bytes, then zeros.
+type bytesSource struct {
+	data []byte
+}
+
+func (s *bytesSource) Uint64() uint64 {
+	var buf [8]byte
+	n := copy(buf[:], s.data)
+	s.data = s.data[n:]
+	return binary.LittleEndian.Uint64(buf[:])
+}
+
+// stringKind limits characters of generated strings.
+type stringKind int
+
+const (
+	anyString stringKind = iota
+
+	// tokenString is for headers and cookies.
+	tokenString
+
+	// urlString is for URL parameters: not empty, without "/" and ".".
+	urlString
+)
+
+const maxDepth = 4
+
+var (
+	timeType      = reflect.TypeOf(time.Time{})
+	protoType     = reflect.TypeOf((*proto.Message)(nil)).Elem()
+	jsonMarshaler = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshaler = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	stringer      = reflect.TypeOf((*fmt.Stringer)(nil)).Elem()
+	anyRunes      = []rune("abcXYZ019 _-.,;:/?#&=+%\"'\\<>{}[]\n\téß世界😀")
+	tokenRunes    = []rune("abcdefXYZ0123456789-_.")
+	urlRunes      = []rune("abcdefXYZ0123456789-_")
+	statuses      = []int{200, 201, 202}
+	valuesOfEnums = 16
+)
+
+type generator struct {
+	src source
+}
+
+func (g *generator) intn(n int) int {
+	return int(g.src.Uint64() % uint64(n))
+}
+
+// fill sets v to a random value. Fields that do not go through transports
+// (unexported, interfaces, functions, channels, json:"-") are left zero.
+func (g *generator) fill(v reflect.Value, kind stringKind, depth int) {
+	t := v.Type()
+	if depth > maxDepth {
+		return
+	}
+	if t.Implements(protoType) && t.Kind() == reflect.Ptr {
+		msg := reflect.New(t.Elem())
+		g.fillProto(msg.Interface().(proto.Message).ProtoReflect(), depth)
+		v.Set(msg)
+		return
+	}
+	if t == timeType {
+		sec := int64(g.intn(4e9)) - 1e9
+		v.Set(reflect.ValueOf(time.Unix(sec, int64(g.intn(1e9))).UTC()))
+		return
+	}
+	if t.Name() != "" && (t.Implements(jsonMarshaler) || t.Implements(textMarshaler) || t.Implements(stringer)) {
+		switch t.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			g.fillEnum(v)
+			return
+		}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		v.SetBool(g.intn(2) == 1)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		x := int64(g.src.Uint64())
+		if g.intn(2) == 0 {
+			x %= 1000
+		}
+		v.SetInt(x >> (64 - t.Bits()))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		x := g.src.Uint64()
+		if g.intn(2) == 0 {
+			x %= 1000
+		}
+		v.SetUint(x >> (64 - t.Bits()))
+	case reflect.Float32, reflect.Float64:
+		var x float64
+		switch g.intn(3) {
+		case 0:
+			x = float64(g.intn(2000)) - 1000
+		case 1:
+			x = (float64(g.intn(1e6)) - 5e5) / 1e3
+		default:
+			x = math.Float64frombits(g.src.Uint64())
+			if math.IsNaN(x) || math.IsInf(x, 0) {
+				x = 0
+			}
+		}
+		if t.Kind() == reflect.Float32 {
+			x = float64(float32(x))
+			if math.IsInf(x, 0) {
+				x = 0
+			}
+		}
+		v.SetFloat(x)
+	case reflect.String:
+		v.SetString(g.string(kind))
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			b := make([]byte, g.intn(16))
+			for i := range b {
+				b[i] = byte(g.src.Uint64())
+			}
+			v.SetBytes(b)
+			return
+		}
+		n := g.intn(4)
+		s := reflect.MakeSlice(t, n, n)
+		for i := 0; i < n; i++ {
+			g.fill(s.Index(i), kind, depth+1)
+		}
+		v.Set(s)
+	case reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			g.fill(v.Index(i), kind, depth+1)
+		}
+	case reflect.Map:
+		n := g.intn(4)
+		m := reflect.MakeMapWithSize(t, n)
+		for i := 0; i < n; i++ {
+			key := reflect.New(t.Key()).Elem()
+			g.fill(key, kind, depth+1)
+			value := reflect.New(t.Elem()).Elem()
+			g.fill(value, kind, depth+1)
+			m.SetMapIndex(key, value)
+		}
+		v.Set(m)
+	case reflect.Ptr:
+		if g.intn(4) == 0 {
+			return
+		}
+		p := reflect.New(t.Elem())
+		g.fill(p.Elem(), kind, depth+1)
+		v.Set(p)
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() || field.Tag.Get("json") == "-" {
+				continue
+			}
+			g.fill(v.Field(i), kind, depth+1)
+		}
+	}
+}
+
+func (g *generator) string(kind stringKind) string {
+	runes := anyRunes
+	n := g.intn(8)
+	switch kind {
+	case tokenString:
+		runes = tokenRunes
+	case urlString:
+		runes = urlRunes
+		n++
+	}
+	s := make([]rune, n)
+	for i := range s {
+		s[i] = runes[g.intn(len(runes))]
+	}
+	return string(s)
+}
+
+// fillEnum sets a named integer type with methods of marshaling, likely an
+// enum, to a value surviving its own marshaling.
+func (g *generator) fillEnum(v reflect.Value) {
+	start := g.intn(valuesOfEnums)
+	for i := 0; i < valuesOfEnums; i++ {
+		x := reflect.New(v.Type()).Elem()
+		if v.Kind() >= reflect.Uint && v.Kind() <= reflect.Uint64 {
+			x.SetUint(uint64((start + i) % valuesOfEnums))
+		} else {
+			x.SetInt(int64((start + i) % valuesOfEnums))
+		}
+		data, err := json.Marshal(x.Interface())
+		if err != nil {
+			continue
+		}
+		y := reflect.New(v.Type())
+		if err := json.Unmarshal(data, y.Interface()); err != nil {
+			continue
+		}
+		if y.Elem().Interface() == x.Interface() {
+			v.Set(x)
+			return
+		}
+	}
+}
+
+func (g *generator) fillProto(m protoreflect.Message, depth int) {
+	// Timestamp and Duration are encoded to JSON only within their ranges.
+	switch m.Descriptor().FullName() {
+	case "google.protobuf.Timestamp", "google.protobuf.Duration":
+		fields := m.Descriptor().Fields()
+		m.Set(fields.ByName("seconds"), protoreflect.ValueOfInt64(int64(g.intn(4e9))))
+		m.Set(fields.ByName("nanos"), protoreflect.ValueOfInt32(int32(g.intn(1e9))))
+		return
+	}
+	fields := m.Descriptor().Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		if fd.IsList() || fd.IsMap() || g.intn(4) == 0 {
+			continue
+		}
+		if fd.Message() != nil {
+			if depth < maxDepth {
+				sub := m.NewField(fd).Message()
+				g.fillProto(sub, depth+1)
+				m.Set(fd, protoreflect.ValueOfMessage(sub))
+			}
+			continue
+		}
+		m.Set(fd, g.protoScalar(fd))
+	}
+}
+
+func (g *generator) protoScalar(fd protoreflect.FieldDescriptor) protoreflect.Value {
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		return protoreflect.ValueOfBool(g.intn(2) == 1)
+	case protoreflect.EnumKind:
+		values := fd.Enum().Values()
+		return protoreflect.ValueOfEnum(values.Get(g.intn(values.Len())).Number())
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		return protoreflect.ValueOfInt32(int32(g.src.Uint64()))
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		return protoreflect.ValueOfInt64(int64(g.src.Uint64()))
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		return protoreflect.ValueOfUint32(uint32(g.src.Uint64()))
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return protoreflect.ValueOfUint64(g.src.Uint64())
+	case protoreflect.FloatKind:
+		return protoreflect.ValueOfFloat32(float32(g.intn(2000)) / 8)
+	case protoreflect.DoubleKind:
+		return protoreflect.ValueOfFloat64(float64(g.intn(2000)) / 8)
+	case protoreflect.StringKind:
+		return protoreflect.ValueOfString(g.string(anyString))
+	case protoreflect.BytesKind:
+		b := make([]byte, g.intn(16))
+		for i := range b {
+			b[i] = byte(g.src.Uint64())
+		}
+		return protoreflect.ValueOfBytes(b)
+	}
+	return fd.Default()
+}
@@ -0,0 +1,367 @@
+package api2test
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/starius/api2"
+	"google.golang.org/protobuf/proto"
+)
+
+// RoundTripSeeds is the number of random requests and responses checked by
+// RoundTrip for each route. It is divided by 10 in short mode.
+const RoundTripSeeds = 100
+
+// RoundTrip checks that requests and responses of the routes survive
+// encoding and decoding by their transports. For each route it generates
+// random requests and responses: strings, numbers, pointers, slices, maps,
+// time, enums (values of named integer types surviving their own JSON
+// marshaling) and protobuf messages. Headers, cookies and URL parameters get
+// strings which HTTP can carry. Interface fields are left nil. The request
+// is sent by the client to the server and the response is sent back through
+// a real HTTP server. Each field which does not survive the trip is reported
+// with the seed of the failing run. Options are passed to both the client
+// and BindRoutes.
+func RoundTrip(t *testing.T, routes []api2.Route, opts ...api2.Option) {
+	t.Helper()
+	seeds := RoundTripSeeds
+	if testing.Short() {
+		seeds /= 10
+	}
+	rt := newRoundTripper(t, routes, opts)
+	for _, m := range rt.methods {
+		t.Run(m.name, func(t *testing.T) {
+			for seed := uint64(0); seed < uint64(seeds); seed++ {
+				g := &generator{src: rand.NewPCG(seed, 0)}
+				if diffs := rt.check(m, g); len(diffs) != 0 {
+					t.Errorf("api2test: seed %d: %s", seed, strings.Join(diffs, "\n"))
+					return
+				}
+			}
+		})
+	}
+}
+
+// FuzzRoundTrip is RoundTrip driven by the fuzzing engine. Inputs of the
+// fuzzer are used as the source of random values:
+//
+//	func FuzzRoundTrip(f *testing.F) {
+//		api2test.FuzzRoundTrip(f, example.GetRoutes(nil))
+//	}
+func FuzzRoundTrip(f *testing.F, routes []api2.Route, opts ...api2.Option) {
+	f.Helper()
+	for seed := uint64(0); seed < 10; seed++ {
+		data := make([]byte, 256)
+		src := rand.NewPCG(seed, 0)
+		for i := 0; i < len(data); i += 8 {
+			binary.LittleEndian.PutUint64(data[i:], src.Uint64())
+		}
+		f.Add(data)
+	}
+	rt := newRoundTripper(f, routes, opts)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		for _, m := range rt.methods {
+			g := &generator{src: &bytesSource{data: data}}
+			if diffs := rt.check(m, g); len(diffs) != 0 {
+				t.Errorf("api2test: %s: %s", m.name, strings.Join(diffs, "\n"))
+			}
+		}
+	})
+}
+
+type roundTripper struct {
+	client  *api2.Client
+	methods []*roundTripMethod
+}
+
+type roundTripMethod struct {
+	name         string
+	requestType  reflect.Type
+	responseType reflect.Type
+
+	// Filled by check and the handler.
+	mu             sync.Mutex
+	response       reflect.Value
+	responseStream []byte
+	received       reflect.Value
+	receivedStream []byte
+	handlerErr     error
+}
+
+func newRoundTripper(t testing.TB, routes []api2.Route, opts []api2.Option) *roundTripper {
+	t.Helper()
+	rt := &roundTripper{}
+	routes = append([]api2.Route(nil), routes...)
+	for i := range routes {
+		name, err := api2.MethodName(routes[i].Handler)
+		if err != nil {
+			t.Fatalf("api2test: route %s %s: %v", routes[i].Method, routes[i].Path, err)
+		}
+		handlerType := handlerType(routes[i].Handler)
+		m := &roundTripMethod{
+			name:         name,
+			requestType:  handlerType.In(1),
+			responseType: handlerType.Out(0),
+		}
+		rt.methods = append(rt.methods, m)
+		routes[i].Handler = reflect.MakeFunc(handlerType, func(args []reflect.Value) []reflect.Value {
+			return m.handle(args[1])
+		}).Interface()
+	}
+	sort.Slice(rt.methods, func(i, j int) bool {
+		return rt.methods[i].name < rt.methods[j].name
+	})
+
+	mux := http.NewServeMux()
+	api2.BindRoutes(mux, routes, append(opts[:len(opts):len(opts)], api2.ErrorLogger(t.Logf))...)
+	server := httptest.NewServer(mux)
+	rt.client = api2.NewClient(routes, server.URL, opts...)
+	t.Cleanup(func() {
+		rt.client.Close()
+		server.Close()
+	})
+	return rt
+}
+
+// handle is the handler of the route on the server. It saves the request
+// and returns the response prepared by check.
+func (m *roundTripMethod) handle(req reflect.Value) []reflect.Value {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.received = req
+	if stream := streamField(req.Elem()); stream.IsValid() && !stream.IsNil() {
+		data, err := io.ReadAll(stream.Interface().(io.Reader))
+		if err != nil {
+			m.handlerErr = fmt.Errorf("failed to read request stream: %w", err)
+		}
+		m.receivedStream = data
+	}
+	res := m.response
+	if stream := streamField(res.Elem()); stream.IsValid() {
+		stream.Set(reflect.ValueOf(io.NopCloser(bytes.NewReader(m.responseStream))))
+	}
+	return []reflect.Value{res, reflect.Zero(errorType)}
+}
+
+// check sends a random request and a random response of the method and
+// returns descriptions of fields which did not survive.
+func (rt *roundTripper) check(m *roundTripMethod, g *generator) []string {
+	req := reflect.New(m.requestType.Elem())
+	reqStream := g.fillMessage(req.Elem(), false)
+	res := reflect.New(m.responseType.Elem())
+	resStream := g.fillMessage(res.Elem(), true)
+
+	m.mu.Lock()
+	m.response = res
+	m.responseStream = resStream
+	m.received = reflect.Value{}
+	m.receivedStream = nil
+	m.handlerErr = nil
+	m.mu.Unlock()
+
+	if stream := streamField(req.Elem()); stream.IsValid() {
+		stream.Set(reflect.ValueOf(io.NopCloser(bytes.NewReader(reqStream))))
+	}
+	got := reflect.New(m.responseType.Elem())
+	err := rt.client.Call(context.Background(), got.Interface(), req.Interface())
+	var gotStream []byte
+	if stream := streamField(got.Elem()); stream.IsValid() && !stream.IsNil() {
+		body := stream.Interface().(io.ReadCloser)
+		var readErr error
+		gotStream, readErr = io.ReadAll(body)
+		body.Close()
+		if err == nil && readErr != nil {
+			err = fmt.Errorf("failed to read response stream: %w", readErr)
+		}
+	}
+
+	m.mu.Lock()
+	received, receivedStream, handlerErr := m.received, m.receivedStream, m.handlerErr
+	m.mu.Unlock()
+
+	if err != nil {
+		return []string{fmt.Sprintf("call failed: %v\nrequest: %+v\nresponse: %+v", err, req.Interface(), res.Interface())}
+	}
+	if handlerErr != nil {
+		return []string{handlerErr.Error()}
+	}
+	var diffs []string
+	if !received.IsValid() {
+		diffs = append(diffs, "the handler was not called")
+	} else {
+		diff("request", req, received, &diffs)
+		if !bytes.Equal(reqStream, receivedStream) {
+			diffs = append(diffs, fmt.Sprintf("request stream: sent %q, received %q", reqStream, receivedStream))
+		}
+	}
+	diff("response", res, got, &diffs)
+	if !bytes.Equal(resStream, gotStream) {
+		diffs = append(diffs, fmt.Sprintf("response stream: sent %q, received %q", resStream, gotStream))
+	}
+	return diffs
+}
+
+// streamField returns the body field with tag is_stream or an invalid value.
+func streamField(v reflect.Value) reflect.Value {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if field.Tag.Get("use_as_body") == "true" && field.Tag.Get("is_stream") == "true" {
+			return v.Field(i)
+		}
+	}
+	return reflect.Value{}
+}
+
+// fillMessage fills a request or a response according to the tags of its
+// fields. If it has a stream body, its content is returned.
+func (g *generator) fillMessage(v reflect.Value, isResponse bool) (stream []byte) {
+	t := v.Type()
+	hasBody := false
+	for i := 0; i < t.NumField(); i++ {
+		if t.Field(i).Tag.Get("use_as_body") == "true" {
+			hasBody = true
+		}
+	}
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		fieldValue := v.Field(i)
+		switch {
+		case field.Tag.Get("header") != "" || field.Tag.Get("cookie") != "":
+			g.fill(fieldValue, tokenString, 1)
+		case field.Tag.Get("url") != "":
+			g.fill(fieldValue, urlString, 1)
+		case field.Tag.Get("query") != "":
+			g.fill(fieldValue, anyString, 1)
+		case field.Tag.Get("use_as_status") == "true":
+			if isResponse {
+				fieldValue.SetInt(int64(statuses[g.intn(len(statuses))]))
+			}
+		case field.Tag.Get("use_as_body") == "true" && field.Tag.Get("is_stream") == "true":
+			stream = make([]byte, g.intn(1024))
+			for i := range stream {
+				stream[i] = byte(g.src.Uint64())
+			}
+		case field.Tag.Get("use_as_body") == "true":
+			g.fill(fieldValue, anyString, 0)
+			if fieldValue.Kind() == reflect.Ptr && fieldValue.IsNil() {
+				fieldValue.Set(reflect.New(field.Type.Elem()))
+			}
+		case hasBody || field.Tag.Get("json") == "-":
+		case field.Anonymous && derefStruct(field.Type):
+			// Embedded structs may have their own tags.
+			if field.Type.Kind() == reflect.Ptr {
+				if g.intn(4) == 0 {
+					continue
+				}
+				fieldValue.Set(reflect.New(field.Type.Elem()))
+				fieldValue = fieldValue.Elem()
+			}
+			g.fillMessage(fieldValue, isResponse)
+		default:
+			g.fill(fieldValue, anyString, 1)
+		}
+	}
+	return stream
+}
+
+func derefStruct(t reflect.Type) bool {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t.Kind() == reflect.Struct && t != timeType
+}
+
+// diff appends differences between the sent and received values to diffs.
+// Nil and empty slices and maps are equal. Streams are skipped.
+func diff(path string, sent, received reflect.Value, diffs *[]string) {
+	report := func() {
+		*diffs = append(*diffs, fmt.Sprintf("%s: sent %#v, received %#v", path, valueOf(sent), valueOf(received)))
+	}
+	t := sent.Type()
+	if t.Implements(protoType) {
+		if !proto.Equal(sent.Interface().(proto.Message), received.Interface().(proto.Message)) {
+			report()
+		}
+		return
+	}
+	if t == timeType {
+		if !sent.Interface().(time.Time).Equal(received.Interface().(time.Time)) {
+			report()
+		}
+		return
+	}
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if sent.IsNil() || received.IsNil() {
+			if sent.IsNil() != received.IsNil() {
+				report()
+			}
+			return
+		}
+		if t.Kind() == reflect.Interface && sent.Elem().Type() != received.Elem().Type() {
+			report()
+			return
+		}
+		diff(path, sent.Elem(), received.Elem(), diffs)
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() || field.Tag.Get("json") == "-" || field.Tag.Get("is_stream") == "true" {
+				// Content of streams is compared separately.
+				continue
+			}
+			diff(path+"."+field.Name, sent.Field(i), received.Field(i), diffs)
+		}
+	case reflect.Slice, reflect.Array:
+		if sent.Len() != received.Len() {
+			report()
+			return
+		}
+		for i := 0; i < sent.Len(); i++ {
+			diff(fmt.Sprintf("%s[%d]", path, i), sent.Index(i), received.Index(i), diffs)
+		}
+	case reflect.Map:
+		if sent.Len() != received.Len() {
+			report()
+			return
+		}
+		iter := sent.MapRange()
+		for iter.Next() {
+			keyPath := fmt.Sprintf("%s[%#v]", path, iter.Key().Interface())
+			value := received.MapIndex(iter.Key())
+			if !value.IsValid() {
+				*diffs = append(*diffs, fmt.Sprintf("%s: sent %#v, missing in received", keyPath, iter.Value().Interface()))
+				continue
+			}
+			diff(keyPath, iter.Value(), value, diffs)
+		}
+	case reflect.Func, reflect.Chan, reflect.UnsafePointer:
+	default:
+		if sent.Interface() != received.Interface() {
+			report()
+		}
+	}
+}
+
+func valueOf(v reflect.Value) interface{} {
+	if !v.CanInterface() {
+		return v.String()
+	}
+	return v.Interface()
+}
@@ -0,0 +1,100 @@
+package api2test
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/starius/api2"
+	"github.com/starius/api2/example"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+func TestRoundTrip(t *testing.T) {
+	RoundTrip(t, example.GetRoutes(nil))
+}
+
+func FuzzExampleRoundTrip(f *testing.F) {
+	FuzzRoundTrip(f, example.GetRoutes(nil))
+}
+
+type rounded float64
+
+func (r rounded) MarshalText() ([]byte, error) {
+	return []byte(fmt.Sprintf("%.1f", float64(r))), nil
+}
+
+func (r *rounded) UnmarshalText(text []byte) error {
+	f, err := strconv.ParseFloat(string(text), 64)
+	*r = rounded(f)
+	return err
+}
+
+type nested struct {
+	When  *time.Time        `json:"when"`
+	Tags  map[string][]int  `json:"tags"`
+	Next  *nested           `json:"next"`
+	Extra map[string]string `json:"-"`
+}
+
+type typesRequest struct {
+	Limit  int     `query:"limit"`
+	Ratio  float64 `query:"ratio"`
+	Trace  int64   `header:"X-Trace"`
+	Token  string  `cookie:"token"`
+	ID     string  `url:"id"`
+	Nested nested  `json:"nested"`
+	Color  example.Color
+}
+
+type typesResponse struct {
+	Status int                  `use_as_status:"true"`
+	Body   *durationpb.Duration `use_as_body:"true" is_protobuf:"true"`
+}
+
+type lossyRequest struct {
+	Value rounded `query:"value"`
+}
+
+type lossyResponse struct {
+}
+
+type testService struct {
+}
+
+func (s *testService) Types(ctx context.Context, req *typesRequest) (*typesResponse, error) {
+	return nil, nil
+}
+
+func (s *testService) Lossy(ctx context.Context, req *lossyRequest) (*lossyResponse, error) {
+	return nil, nil
+}
+
+func TestRoundTripTypes(t *testing.T) {
+	s := &testService{}
+	RoundTrip(t, []api2.Route{
+		{Method: http.MethodPost, Path: "/types/:id", Handler: api2.Method(&s, "Types")},
+	})
+}
+
+func TestRoundTripDetectsLoss(t *testing.T) {
+	s := &testService{}
+	rt := newRoundTripper(t, []api2.Route{
+		{Method: http.MethodGet, Path: "/lossy", Handler: api2.Method(&s, "Lossy")},
+	}, nil)
+	require.Len(t, rt.methods, 1)
+	require.Equal(t, "Lossy", rt.methods[0].name)
+
+	var diffs []string
+	for seed := uint64(0); seed < RoundTripSeeds && len(diffs) == 0; seed++ {
+		diffs = rt.check(rt.methods[0], &generator{src: rand.NewPCG(seed, 0)})
+	}
+	require.Len(t, diffs, 1)
+	require.True(t, strings.HasPrefix(diffs[0], "request.Value: sent "), diffs[0])
+}
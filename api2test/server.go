@@ -0,0 +1,312 @@
+// Package api2test helps to test code calling services built with api2.
+//
+// NewServer starts a test server of the routes of a service with handlers
+// replaced by stubs programmed in the test:
+//
+//	s := api2test.NewServer(t, example.GetRoutes)
+//	s.On("Echo").Return(&example.EchoResponse{Text: "hi"}, nil)
+//	s.On("Echo").Match(func(req *example.EchoRequest) bool {
+//		return req.User == "nobody"
+//	}).Return(nil, errors.NotFound("no such user"))
+//
+//	client, err := example.NewClient(s.URL)
+//	...
+//	require.Equal(t, 2, s.Calls("Echo"))
+//
+// The server is closed when the test ends, so tests using goleak do not
+// report leaked goroutines.
+package api2test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/starius/api2"
+	"github.com/starius/api2/errors"
+)
+
+// Server is a test HTTP server of routes whose handlers are stubs.
+// Methods are addressed by their names in the service, e.g. "Echo".
+type Server struct {
+	// URL of the server, e.g. "http://127.0.0.1:1234". Pass it to a client.
+	URL string
+
+	t      testing.TB
+	routes []api2.Route
+
+	mu      sync.Mutex
+	methods map[string]*method
+}
+
+type method struct {
+	name         string
+	handlerType  reflect.Type
+	requestType  reflect.Type
+	responseType reflect.Type
+	stubs        []*Stub
+	requests     []interface{}
+}
+
+// Stub is the behaviour of a method for matching requests. Methods of Stub
+// return the stub itself to be chained.
+type Stub struct {
+	s *Server
+	m *method
+
+	match reflect.Value
+	do    reflect.Value
+	res   reflect.Value
+	err   error
+	times int
+	calls int
+}
+
+var errorType = reflect.TypeOf((*error)(nil)).Elem()
+
+// NewServer starts a test server with the routes returned by the functions
+// like GetRoutes(s IService) []api2.Route. They are called with a nil
+// service. Handlers of the routes are replaced with stubs. A call of a method
+// without a matching stub fails the test and returns 501 Not Implemented.
+// The server is closed when the test ends; then expectations set by Times
+// are checked.
+func NewServer(t testing.TB, getRoutes ...interface{}) *Server {
+	t.Helper()
+	s := &Server{
+		t:       t,
+		methods: make(map[string]*method),
+	}
+	for _, getRoutes := range getRoutes {
+		genValue := reflect.ValueOf(getRoutes)
+		serviceArg := reflect.New(genValue.Type().In(0)).Elem()
+		routes := genValue.Call([]reflect.Value{serviceArg})[0].Interface().([]api2.Route)
+		for _, route := range routes {
+			name, err := api2.MethodName(route.Handler)
+			if err != nil {
+				t.Fatalf("api2test: route %s %s: %v", route.Method, route.Path, err)
+			}
+			if _, has := s.methods[name]; has {
+				t.Fatalf("api2test: duplicate method %s", name)
+			}
+			handlerType := handlerType(route.Handler)
+			m := &method{
+				name:         name,
+				handlerType:  handlerType,
+				requestType:  handlerType.In(1),
+				responseType: handlerType.Out(0),
+			}
+			s.methods[name] = m
+			route.Handler = reflect.MakeFunc(handlerType, func(args []reflect.Value) []reflect.Value {
+				return s.call(m, args)
+			}).Interface()
+			s.routes = append(s.routes, route)
+		}
+	}
+
+	mux := http.NewServeMux()
+	api2.BindRoutes(mux, s.routes, api2.ErrorLogger(t.Logf))
+	server := httptest.NewServer(mux)
+	s.URL = server.URL
+	t.Cleanup(func() {
+		server.Close()
+		s.checkExpectations()
+	})
+	return s
+}
+
+func handlerType(handler interface{}) reflect.Type {
+	type funcer interface {
+		Func() interface{}
+	}
+	if f, ok := handler.(funcer); ok {
+		handler = f.Func()
+	}
+	return reflect.TypeOf(handler)
+}
+
+// Routes returns the routes served by the server. Their handlers call
+// the stubs.
+func (s *Server) Routes() []api2.Route {
+	return s.routes
+}
+
+// On adds a stub of the method. Without Return or Do the stub returns an
+// empty response. Stubs added later are checked first, so they override
+// the previous ones.
+func (s *Server) On(name string) *Stub {
+	s.t.Helper()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	m, has := s.methods[name]
+	if !has {
+		s.t.Fatalf("api2test: unknown method %s", name)
+	}
+	stub := &Stub{s: s, m: m}
+	m.stubs = append(m.stubs, stub)
+	return stub
+}
+
+// Match makes the stub apply only to requests for which fn returns true.
+// fn must be a function like func(req *EchoRequest) bool. It may call
+// methods of the server, e.g. Calls; the current call is not counted yet.
+func (st *Stub) Match(fn interface{}) *Stub {
+	fnValue := reflect.ValueOf(fn)
+	fnType := fnValue.Type()
+	if fnType.Kind() != reflect.Func || fnType.NumIn() != 1 || fnType.In(0) != st.m.requestType || fnType.NumOut() != 1 || fnType.Out(0).Kind() != reflect.Bool {
+		panic(fmt.Sprintf("api2test: matcher of %s must be func(%v) bool, got %v", st.m.name, st.m.requestType, fnType))
+	}
+	st.s.mu.Lock()
+	defer st.s.mu.Unlock()
+	st.match = fnValue
+	return st
+}
+
+// Return makes the stub return the response or the error. res must be
+// a pointer to the response type of the method or nil.
+func (st *Stub) Return(res interface{}, err error) *Stub {
+	var resValue reflect.Value
+	if res != nil {
+		resValue = reflect.ValueOf(res)
+		if resValue.Type() != st.m.responseType {
+			panic(fmt.Sprintf("api2test: response of %s must be %v, got %T", st.m.name, st.m.responseType, res))
+		}
+	}
+	st.s.mu.Lock()
+	defer st.s.mu.Unlock()
+	st.res = resValue
+	st.err = err
+	return st
+}
+
+// Do makes the stub call fn, which must have the signature of the method,
+// e.g. func(ctx context.Context, req *EchoRequest) (*EchoResponse, error).
+func (st *Stub) Do(fn interface{}) *Stub {
+	fnValue := reflect.ValueOf(fn)
+	if fnValue.Type() != st.m.handlerType {
+		panic(fmt.Sprintf("api2test: function of %s must be %v, got %v", st.m.name, st.m.handlerType, fnValue.Type()))
+	}
+	st.s.mu.Lock()
+	defer st.s.mu.Unlock()
+	st.do = fnValue
+	return st
+}
+
+// Times makes the stub apply to n calls only. The test fails if the stub
+// was called another number of times when the test ends.
+func (st *Stub) Times(n int) *Stub {
+	st.s.mu.Lock()
+	defer st.s.mu.Unlock()
+	st.times = n
+	return st
+}
+
+// Once is the same as Times(1).
+func (st *Stub) Once() *Stub {
+	return st.Times(1)
+}
+
+// Calls returns the number of calls handled by the stub.
+func (st *Stub) Calls() int {
+	st.s.mu.Lock()
+	defer st.s.mu.Unlock()
+	return st.calls
+}
+
+// Calls returns the number of calls of the method.
+func (s *Server) Calls(name string) int {
+	return len(s.Requests(name))
+}
+
+// Requests returns requests of all calls of the method in the order they
+// were received. Elements are pointers to request types, e.g.
+// *example.EchoRequest.
+func (s *Server) Requests(name string) []interface{} {
+	s.t.Helper()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	m, has := s.methods[name]
+	if !has {
+		s.t.Fatalf("api2test: unknown method %s", name)
+	}
+	return append([]interface{}(nil), m.requests...)
+}
+
+func (s *Server) call(m *method, args []reflect.Value) []reflect.Value {
+	req := args[1]
+
+	var stub *Stub
+	var do, res reflect.Value
+	var err error
+	for {
+		// Matchers are called without the lock, so they may call methods
+		// of the server such as Calls and Requests.
+		type candidate struct {
+			stub  *Stub
+			match reflect.Value
+		}
+		var candidates []candidate
+		s.mu.Lock()
+		for i := len(m.stubs) - 1; i >= 0; i-- {
+			st := m.stubs[i]
+			if st.times != 0 && st.calls >= st.times {
+				continue
+			}
+			candidates = append(candidates, candidate{stub: st, match: st.match})
+		}
+		s.mu.Unlock()
+
+		stub = nil
+		for _, c := range candidates {
+			if c.match.IsValid() && !c.match.Call([]reflect.Value{req})[0].Bool() {
+				continue
+			}
+			stub = c.stub
+			break
+		}
+
+		s.mu.Lock()
+		if stub != nil && stub.times != 0 && stub.calls >= stub.times {
+			// The stub was used up by a concurrent call. Search again.
+			s.mu.Unlock()
+			continue
+		}
+		m.requests = append(m.requests, req.Interface())
+		if stub != nil {
+			stub.calls++
+			do, res, err = stub.do, stub.res, stub.err
+		}
+		s.mu.Unlock()
+		break
+	}
+
+	if stub == nil {
+		s.t.Errorf("api2test: unexpected call of %s with %+v", m.name, req.Interface())
+		err := errors.Unimplemented("api2test: no stub of %s matches the request", m.name)
+		return []reflect.Value{reflect.Zero(m.responseType), reflect.ValueOf(err)}
+	}
+	if do.IsValid() {
+		return do.Call(args)
+	}
+	if err != nil {
+		return []reflect.Value{reflect.Zero(m.responseType), reflect.ValueOf(err)}
+	}
+	if !res.IsValid() {
+		res = reflect.New(m.responseType.Elem())
+	}
+	return []reflect.Value{res, reflect.Zero(errorType)}
+}
+
+func (s *Server) checkExpectations() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, m := range s.methods {
+		for _, st := range m.stubs {
+			if st.times != 0 && st.calls != st.times {
+				s.t.Errorf("api2test: stub of %s was called %d times, want %d", m.name, st.calls, st.times)
+			}
+		}
+	}
+}
@@ -0,0 +1,179 @@
+package api2test
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/starius/api2"
+	"github.com/starius/api2/errors"
+	"github.com/starius/api2/example"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/goleak"
+)
+
+func TestMain(m *testing.M) {
+	goleak.VerifyTestMain(m)
+}
+
+func TestServer(t *testing.T) {
+	s := NewServer(t, example.GetRoutes)
+	s.On("Echo").Return(&example.EchoResponse{Text: "hi"}, nil)
+	s.On("Echo").Match(func(req *example.EchoRequest) bool {
+		return req.User == "nobody"
+	}).Return(nil, errors.NotFound("no such user"))
+	hello := s.On("Hello").Do(func(ctx context.Context, req *example.HelloRequest) (*example.HelloResponse, error) {
+		return &example.HelloResponse{Session: "session-" + req.Key}, nil
+	}).Once()
+
+	client, err := example.NewClient(s.URL)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, client.Close())
+	})
+	ctx := context.Background()
+
+	echoRes, err := client.Echo(ctx, &example.EchoRequest{User: "alice", Text: "a"})
+	require.NoError(t, err)
+	require.Equal(t, "hi", echoRes.Text)
+
+	_, err = client.Echo(ctx, &example.EchoRequest{User: "nobody", Text: "b"})
+	require.ErrorContains(t, err, "no such user")
+
+	helloRes, err := client.Hello(ctx, &example.HelloRequest{Key: "k"})
+	require.NoError(t, err)
+	require.Equal(t, "session-k", helloRes.Session)
+	require.Equal(t, 1, hello.Calls())
+
+	require.Equal(t, 2, s.Calls("Echo"))
+	requests := s.Requests("Echo")
+	require.Equal(t, "alice", requests[0].(*example.EchoRequest).User)
+	require.Equal(t, "b", requests[1].(*example.EchoRequest).Text)
+	require.Equal(t, 0, s.Calls("Since"))
+}
+
+func TestServerCallOptions(t *testing.T) {
+	s := NewServer(t, example.GetRoutes)
+	s.On("Echo").Do(func(ctx context.Context, req *example.EchoRequest) (*example.EchoResponse, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	})
+
+	client, err := example.NewClient(s.URL)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, client.Close())
+	})
+	ctx := context.Background()
+
+	_, err = client.WithOptions().Echo(ctx, &example.EchoRequest{User: "alice"}, api2.CallTimeout(10*time.Millisecond))
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	// The client implementing the service interface takes the options from
+	// the context.
+	var service example.IEchoService = client
+	_, err = service.Echo(api2.WithCallOptions(ctx, api2.CallTimeout(10*time.Millisecond)), &example.EchoRequest{User: "alice"})
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestServerMatcherCallsServer(t *testing.T) {
+	s := NewServer(t, example.GetRoutes)
+	// The matcher uses the server: only the first call matches it.
+	s.On("Echo").Return(&example.EchoResponse{Text: "again"}, nil)
+	s.On("Echo").Match(func(req *example.EchoRequest) bool {
+		return s.Calls("Echo") == 0
+	}).Return(&example.EchoResponse{Text: "first"}, nil)
+
+	client, err := example.NewClient(s.URL)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		require.NoError(t, client.Close())
+	})
+	ctx := context.Background()
+
+	for _, want := range []string{"first", "again", "again"} {
+		res, err := client.Echo(ctx, &example.EchoRequest{User: "alice"})
+		require.NoError(t, err)
+		require.Equal(t, want, res.Text)
+	}
+	require.Equal(t, 3, s.Calls("Echo"))
+}
+
+// recordingT records failures instead of failing the test.
+type recordingT struct {
+	testing.TB
+	errors []string
+}
+
+func (t *recordingT) Errorf(format string, args ...interface{}) {
+	t.errors = append(t.errors, format)
+}
+
+func TestServerFailures(t *testing.T) {
+	rt := &recordingT{TB: t}
+	t.Run("server", func(t *testing.T) {
+		rt.TB = t
+		s := NewServer(rt, example.GetRoutes)
+		s.On("Hello").Times(2)
+
+		client, err := example.NewClient(s.URL)
+		require.NoError(t, err)
+		defer client.Close()
+		ctx := context.Background()
+
+		_, err = client.Hello(ctx, &example.HelloRequest{})
+		require.NoError(t, err)
+
+		// No stub of Echo.
+		_, err = client.Echo(ctx, &example.EchoRequest{User: "alice"})
+		require.ErrorContains(t, err, "no stub of Echo matches the request")
+		require.Len(t, rt.errors, 1)
+	})
+	// Hello was called once, not twice.
+	require.Len(t, rt.errors, 2)
+	require.Contains(t, rt.errors[1], "was called %d times, want %d")
+}
+
+func TestStubTypes(t *testing.T) {
+	s := NewServer(t, example.GetRoutes)
+	require.Panics(t, func() {
+		s.On("Echo").Return(&example.HelloResponse{}, nil)
+	})
+	require.Panics(t, func() {
+		s.On("Echo").Match(func(req *example.HelloRequest) bool { return true })
+	})
+	require.Panics(t, func() {
+		s.On("Echo").Do(func(ctx context.Context, req *example.EchoRequest) (*example.HelloResponse, error) {
+			return nil, nil
+		})
+	})
+}
+
+func TestGeneratedMock(t *testing.T) {
+	mock := &example.Mock{
+		HelloFunc: func(ctx context.Context, req *example.HelloRequest) (*example.HelloResponse, error) {
+			return &example.HelloResponse{Session: "s-" + req.Key}, nil
+		},
+	}
+	client := api2.NewDirectClient(example.GetRoutes(mock), api2.DirectWire, api2.ErrorLogger(t.Logf))
+	ctx := context.Background()
+
+	var res example.HelloResponse
+	require.NoError(t, client.Call(ctx, &res, &example.HelloRequest{Key: "k"}))
+	require.Equal(t, "s-k", res.Session)
+	require.Len(t, mock.HelloCalls(), 1)
+	require.Equal(t, "k", mock.HelloCalls()[0].Key)
+
+	err := client.Call(ctx, &example.EchoResponse{}, &example.EchoRequest{User: "u"})
+	require.ErrorContains(t, err, "Mock.EchoFunc is not set")
+	require.Len(t, mock.EchoCalls(), 1)
+}
+
+func TestGeneratedMockIsUpToDate(t *testing.T) {
+	code, mockFile, err := api2.GenerateMockCode(example.GetRoutes)
+	require.NoError(t, err)
+	want, err := os.ReadFile(mockFile)
+	require.NoError(t, err)
+	require.Equal(t, string(want), code)
+}
@@ -359,6 +359,50 @@ func TestValidateRequestResponse(t *testing.T) {
 			request:   true,
 			wantPanic: true,
 		},
+		{
+			obj: struct {
+				Pagination
+				Foo string `json:"foo"`
+			}{},
+			request: true,
+		},
+		{
+			obj: struct {
+				*Pagination
+			}{},
+			request:   false,
+			wantPanic: true,
+		},
+		{
+			obj: struct {
+				AuthHeaders
+				Foo string `json:"foo"`
+			}{},
+			request: false,
+		},
+		{
+			obj: struct {
+				UrlPart
+			}{},
+			request: true,
+			path:    "/foo/:id",
+		},
+		{
+			obj: struct {
+				UrlPart
+			}{},
+			request:   true,
+			path:      "/foo",
+			wantPanic: true,
+		},
+		{
+			obj: struct {
+				BodyPart
+				Body []string `use_as_body:"true"`
+			}{},
+			request:   true,
+			wantPanic: true,
+		},
 	}
 
 	for i, tc := range cases {
@@ -379,6 +423,15 @@ func TestValidateRequestResponse(t *testing.T) {
 	}
 }
 
+type UrlPart struct {
+	ID int `url:"id"`
+}
+
+type BodyPart struct {
+	Header string   `header:"h"`
+	Body   []string `use_as_body:"true"`
+}
+
 type HelloRequest struct {
 }
 
@@ -0,0 +1,468 @@
+package api2
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// BalanceStrategy chooses an endpoint of LoadBalancer for each request.
+type BalanceStrategy int
+
+const (
+	// RoundRobin sends requests to endpoints in turn.
+	RoundRobin BalanceStrategy = iota
+
+	// LeastOutstanding sends a request to the endpoint with the least number
+	// of requests in flight.
+	LeastOutstanding
+)
+
+// LoadBalancer distributes calls of Client across replicas of the service.
+// Pass it to NewClient in option LoadBalance. The client sends each request
+// to one of the endpoints instead of baseURL: the URL of the request is the
+// URL of the endpoint plus the path of the route. baseURL passed to
+// NewClient can be empty in this case.
+//
+// Endpoints failing MaxFailures times in a row (with errors or status codes
+// 5xx) are ejected for EjectionTime. If all endpoints are ejected or
+// unhealthy, all of them are used. If a request of an idempotent route
+// (see RetryPolicy) fails because the endpoint could not be connected to
+// (e.g. connection refused or DNS failure), it is sent to another endpoint.
+// Other errors, e.g. TLS errors, failures after the request was sent,
+// timeouts, cancellations and failures to sign the request, are returned as
+// is, since the request may have reached the server.
+type LoadBalancer struct {
+	Strategy BalanceStrategy
+
+	// Endpoints are base URLs of replicas, e.g. "http://10.0.0.1:8080".
+	Endpoints []string
+
+	// Resolve returns the list of endpoints. If it is set, Endpoints is
+	// ignored and Resolve is called every ResolveInterval (default 30s).
+	// Only one call of Resolve runs at a time; meanwhile calls use the
+	// previous list. If it fails, the previous list is used.
+	Resolve         func(ctx context.Context) ([]string, error)
+	ResolveInterval time.Duration
+
+	// MaxFailures defaults to 5, EjectionTime defaults to 30s.
+	MaxFailures  int
+	EjectionTime time.Duration
+
+	// HealthCheck enables active health checks. They run in a goroutine
+	// until Client.Close is called, so the client must be closed.
+	HealthCheck *HealthCheck
+}
+
+// HealthCheck configures active health checks of endpoints of LoadBalancer.
+// Requests which fail or return a status code other than 2xx make the
+// endpoint unhealthy until the next successful check.
+type HealthCheck struct {
+	// Method (default GET) and Path of the route checked, e.g. "/health".
+	Method string
+	Path   string
+
+	// Interval defaults to 10s, Timeout defaults to 5s.
+	Interval time.Duration
+	Timeout  time.Duration
+}
+
+type balancer struct {
+	policy *LoadBalancer
+	client HttpClient
+	errorf func(format string, args ...interface{})
+
+	mu           sync.Mutex
+	endpoints    []*endpoint
+	next         int
+	lastResolved time.Time
+
+	// resolved is closed when the running call of Resolve finishes. It is
+	// nil if Resolve is not running.
+	resolved chan struct{}
+
+	stop     chan struct{}
+	stopOnce sync.Once
+	stopped  sync.WaitGroup
+}
+
+type endpoint struct {
+	url *url.URL
+
+	// Protected by balancer.mu.
+	outstanding  int
+	failures     int
+	ejectedUntil time.Time
+	unhealthy    bool
+}
+
+func newBalancer(policy *LoadBalancer, client HttpClient, errorf func(format string, args ...interface{})) *balancer {
+	b := &balancer{
+		policy: policy,
+		client: client,
+		errorf: errorf,
+		stop:   make(chan struct{}),
+	}
+	if policy.Resolve == nil {
+		b.setEndpoints(policy.Endpoints)
+		if len(b.endpoints) == 0 {
+			panic("LoadBalancer has no endpoints")
+		}
+	}
+	if policy.HealthCheck != nil {
+		b.stopped.Add(1)
+		go b.checkHealth()
+	}
+	return b
+}
+
+func (b *balancer) setEndpoints(urls []string) {
+	old := make(map[string]*endpoint, len(b.endpoints))
+	for _, e := range b.endpoints {
+		old[e.url.String()] = e
+	}
+	endpoints := make([]*endpoint, 0, len(urls))
+	for _, u := range urls {
+		parsed, err := url.Parse(strings.TrimSuffix(u, "/"))
+		if err != nil || parsed.Scheme == "" || parsed.Host == "" {
+			panic(fmt.Sprintf("bad endpoint %q", u))
+		}
+		if e, has := old[parsed.String()]; has {
+			// Keep the state of the endpoint.
+			endpoints = append(endpoints, e)
+			continue
+		}
+		endpoints = append(endpoints, &endpoint{url: parsed})
+	}
+	b.endpoints = endpoints
+}
+
+func (b *balancer) resolve(ctx context.Context) error {
+	interval := b.policy.ResolveInterval
+	if interval == 0 {
+		interval = 30 * time.Second
+	}
+	b.mu.Lock()
+	for {
+		if len(b.endpoints) != 0 && (time.Since(b.lastResolved) < interval || b.resolved != nil) {
+			// Fresh or being resolved by another call.
+			b.mu.Unlock()
+			return nil
+		}
+		if b.resolved == nil {
+			break
+		}
+		// No endpoints yet: wait for the running call of Resolve.
+		resolved := b.resolved
+		b.mu.Unlock()
+		select {
+		case <-resolved:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		b.mu.Lock()
+	}
+	resolved := make(chan struct{})
+	b.resolved = resolved
+	b.mu.Unlock()
+
+	urls, err := b.policy.Resolve(ctx)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.resolved = nil
+	close(resolved)
+	if err == nil && len(urls) == 0 {
+		err = fmt.Errorf("no endpoints")
+	}
+	if err != nil {
+		if len(b.endpoints) != 0 {
+			b.errorf("failed to resolve endpoints, using the previous ones: %v", err)
+			return nil
+		}
+		return fmt.Errorf("failed to resolve endpoints: %w", err)
+	}
+	b.setEndpoints(urls)
+	b.lastResolved = time.Now()
+	return nil
+}
+
+// pick chooses an endpoint not in tried and increments its outstanding
+// requests. It returns nil if all endpoints were tried.
+func (b *balancer) pick(tried []*endpoint) *endpoint {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	var candidates, available []*endpoint
+	for _, e := range b.endpoints {
+		if containsEndpoint(tried, e) {
+			continue
+		}
+		candidates = append(candidates, e)
+		if !e.unhealthy && !now.Before(e.ejectedUntil) {
+			available = append(available, e)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+	if len(available) == 0 {
+		// Better to try an ejected endpoint than to fail.
+		available = candidates
+	}
+
+	b.next++
+	chosen := available[b.next%len(available)]
+	if b.policy.Strategy == LeastOutstanding {
+		for i := range available {
+			e := available[(b.next+i)%len(available)]
+			if e.outstanding < chosen.outstanding {
+				chosen = e
+			}
+		}
+	}
+	chosen.outstanding++
+	return chosen
+}
+
+func containsEndpoint(endpoints []*endpoint, e *endpoint) bool {
+	for _, e2 := range endpoints {
+		if e2 == e {
+			return true
+		}
+	}
+	return false
+}
+
+// done records the result of a request sent to the endpoint.
+func (b *balancer) done(e *endpoint, failed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	e.outstanding--
+	if !failed {
+		e.failures = 0
+		return
+	}
+	e.failures++
+	maxFailures := b.policy.MaxFailures
+	if maxFailures == 0 {
+		maxFailures = 5
+	}
+	if e.failures >= maxFailures {
+		ejectionTime := b.policy.EjectionTime
+		if ejectionTime == 0 {
+			ejectionTime = 30 * time.Second
+		}
+		e.ejectedUntil = time.Now().Add(ejectionTime)
+		e.failures = 0
+	}
+}
+
+// release undoes pick of the endpoint to which the request was not sent.
+func (b *balancer) release(e *endpoint) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	e.outstanding--
+}
+
+// rebase returns the URL with the base replaced by the endpoint.
+func rebase(u *url.URL, basePath string, e *endpoint) *url.URL {
+	u2 := *u
+	u2.Scheme = e.url.Scheme
+	u2.Host = e.url.Host
+	u2.Path = e.url.Path + strings.TrimPrefix(u.Path, basePath)
+	u2.RawPath = ""
+	if u.RawPath != "" {
+		u2.RawPath = e.url.EscapedPath() + strings.TrimPrefix(u.RawPath, basePath)
+	}
+	return &u2
+}
+
+// do sends the request to one of the endpoints. If the request fails with
+// a connection error and can be sent again, it is sent to another endpoint.
+// If all endpoints were tried, the last result is returned.
+func (b *balancer) do(c *Client, route Route, req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	if b.policy.Resolve != nil {
+		if err := b.resolve(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	// Requests of a hedged call go to different endpoints if possible.
+	group, _ := ctx.Value(hedgeGroupType{}).(*hedgeGroup)
+
+	var tried []*endpoint
+	var res *http.Response
+	var err error
+	for {
+		var e *endpoint
+		if group != nil {
+			e = b.pick(append(group.list(), tried...))
+		}
+		if e == nil {
+			e = b.pick(tried)
+		}
+		if e == nil {
+			if len(tried) != 0 {
+				return res, err
+			}
+			return nil, fmt.Errorf("no endpoints")
+		}
+		tried = append(tried, e)
+		if group != nil {
+			group.add(e)
+		}
+
+		var tickets []circuitTicket
+		if c.breakers != nil {
+			tickets, err = c.breakers.allow("host " + e.url.Host)
+			if err != nil {
+				// The request was not sent, so try another endpoint.
+				res = nil
+				b.release(e)
+				continue
+			}
+		}
+
+		attempt := req.Clone(ctx)
+		attempt.URL = rebase(req.URL, c.basePath, e)
+		attempt.Host = ""
+		res, err = c.sendTo(attempt)
+		failed := err != nil || res.StatusCode >= 500
+		if err != nil && ctx.Err() != nil {
+			// Cancellation is not a failure of the endpoint.
+			failed = false
+		}
+		b.done(e, failed)
+		if c.breakers != nil {
+			ignore := err != nil && ctx.Err() != nil
+			c.breakers.done(tickets, !ignore && c.breakers.policy.isFailure(res, err), ignore)
+		}
+
+		if err == nil || ctx.Err() != nil || !isConnectionError(err) || !canRetry(route, req) {
+			return res, err
+		}
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, fmt.Errorf("failed to rewind request body: %w", err)
+			}
+			req.Body = body
+		}
+	}
+}
+
+// isConnectionError tells if the error was returned by HttpClient failing to
+// connect to the endpoint, so the request was not sent and another endpoint
+// can be tried.
+func isConnectionError(err error) bool {
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		return true
+	}
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+func (b *balancer) checkHealth() {
+	defer b.stopped.Done()
+
+	hc := b.policy.HealthCheck
+	interval := hc.Interval
+	if interval == 0 {
+		interval = 10 * time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		b.checkHealthOnce()
+		select {
+		case <-b.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (b *balancer) checkHealthOnce() {
+	hc := b.policy.HealthCheck
+	method := hc.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	timeout := hc.Timeout
+	if timeout == 0 {
+		timeout = 5 * time.Second
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-b.stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	if b.policy.Resolve != nil {
+		if err := b.resolve(ctx); err != nil {
+			b.errorf("health check: %v", err)
+			return
+		}
+	}
+
+	b.mu.Lock()
+	endpoints := append([]*endpoint(nil), b.endpoints...)
+	b.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, e := range endpoints {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(ctx, timeout)
+			defer cancel()
+			healthy := b.probe(ctx, method, e.url.String()+hc.Path)
+			b.mu.Lock()
+			e.unhealthy = !healthy
+			b.mu.Unlock()
+		}()
+	}
+	wg.Wait()
+}
+
+func (b *balancer) probe(ctx context.Context, method, url string) bool {
+	req, err := http.NewRequestWithContext(ctx, method, url, nil)
+	if err != nil {
+		return false
+	}
+	res, err := b.client.Do(req)
+	if err != nil {
+		return false
+	}
+	_, _ = io.Copy(io.Discard, io.LimitReader(res.Body, 64*1024))
+	if err := res.Body.Close(); err != nil {
+		b.errorf("failed to close resource: %v", err)
+	}
+	return 200 <= res.StatusCode && res.StatusCode < 300
+}
+
+func (b *balancer) close() {
+	b.stopOnce.Do(func() {
+		close(b.stop)
+	})
+	b.stopped.Wait()
+}
@@ -0,0 +1,163 @@
+package api2
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBalancerPick(t *testing.T) {
+	errorf := func(format string, args ...interface{}) {}
+	b := newBalancer(&LoadBalancer{
+		Endpoints:    []string{"http://a", "http://b/", "http://c"},
+		MaxFailures:  2,
+		EjectionTime: time.Hour,
+	}, nil, errorf)
+	a, bb, c := b.endpoints[0], b.endpoints[1], b.endpoints[2]
+	require.Equal(t, "http://b", bb.url.String())
+
+	// Round robin.
+	var got []*endpoint
+	for i := 0; i < 6; i++ {
+		e := b.pick(nil)
+		got = append(got, e)
+		b.done(e, false)
+	}
+	require.Equal(t, []*endpoint{bb, c, a, bb, c, a}, got)
+
+	// Tried endpoints are skipped.
+	require.Equal(t, c, b.pick([]*endpoint{bb}))
+	b.done(c, false)
+	require.Nil(t, b.pick([]*endpoint{a, bb, c}))
+
+	// Ejection after 2 failures in a row.
+	b.done(b.pick([]*endpoint{a, c}), true)
+	b.done(b.pick([]*endpoint{a, c}), false)
+	b.done(b.pick([]*endpoint{a, c}), true)
+	require.True(t, bb.ejectedUntil.IsZero())
+	b.done(b.pick([]*endpoint{a, c}), true)
+	require.False(t, bb.ejectedUntil.IsZero())
+	for i := 0; i < 4; i++ {
+		e := b.pick(nil)
+		require.NotEqual(t, bb, e)
+		b.done(e, false)
+	}
+
+	// If all are excluded, they are used anyway.
+	a.unhealthy = true
+	c.unhealthy = true
+	e := b.pick(nil)
+	require.NotNil(t, e)
+	b.done(e, false)
+}
+
+func TestBalancerLeastOutstanding(t *testing.T) {
+	b := newBalancer(&LoadBalancer{
+		Strategy:  LeastOutstanding,
+		Endpoints: []string{"http://a", "http://b", "http://c"},
+	}, nil, func(format string, args ...interface{}) {})
+
+	e1 := b.pick(nil)
+	e2 := b.pick(nil)
+	e3 := b.pick(nil)
+	require.ElementsMatch(t, b.endpoints, []*endpoint{e1, e2, e3})
+	b.done(e2, false)
+	require.Equal(t, e2, b.pick(nil))
+}
+
+func TestBalancerResolveOnce(t *testing.T) {
+	var calls atomic.Int32
+	release := make(chan struct{})
+	b := newBalancer(&LoadBalancer{
+		Resolve: func(ctx context.Context) ([]string, error) {
+			calls.Add(1)
+			<-release
+			return []string{"http://a"}, nil
+		},
+	}, nil, func(format string, args ...interface{}) {})
+
+	resolveAll := func() {
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				require.NoError(t, b.resolve(context.Background()))
+			}()
+		}
+		time.Sleep(50 * time.Millisecond)
+		release <- struct{}{}
+		wg.Wait()
+	}
+
+	// Without endpoints calls wait for the running call of Resolve.
+	resolveAll()
+	require.Equal(t, int32(1), calls.Load())
+	require.Len(t, b.endpoints, 1)
+
+	// With stale endpoints calls use them while Resolve is running.
+	b.mu.Lock()
+	b.lastResolved = time.Time{}
+	b.mu.Unlock()
+	resolveAll()
+	require.Equal(t, int32(2), calls.Load())
+}
+
+func TestIsConnectionError(t *testing.T) {
+	urlError := func(err error) error {
+		return &url.Error{Op: "Get", URL: "http://a", Err: err}
+	}
+	require.True(t, isConnectionError(urlError(&net.OpError{Op: "dial", Net: "tcp", Err: syscall.ECONNREFUSED})))
+	require.True(t, isConnectionError(urlError(&net.OpError{Op: "dial", Net: "tcp", Err: &net.DNSError{Err: "no such host", Name: "a"}})))
+	require.True(t, isConnectionError(urlError(syscall.ECONNREFUSED)))
+
+	// The request may have reached the server.
+	require.False(t, isConnectionError(urlError(io.ErrUnexpectedEOF)))
+	require.False(t, isConnectionError(urlError(&net.OpError{Op: "read", Net: "tcp", Err: syscall.ECONNRESET})))
+	require.False(t, isConnectionError(urlError(errors.New("tls: failed to verify certificate"))))
+	require.False(t, isConnectionError(urlError(context.Canceled)))
+	require.False(t, isConnectionError(urlError(&net.OpError{Op: "dial", Net: "tcp", Err: context.DeadlineExceeded})))
+	require.False(t, isConnectionError(fmt.Errorf("failed to sign request: %w", errors.New("no key"))))
+	require.False(t, isConnectionError(errors.New("other")))
+}
+
+func TestBalancerCloseTwice(t *testing.T) {
+	type Request struct {
+	}
+	type Response struct {
+	}
+	routes := []Route{
+		NewRoute[Request, Response](http.MethodGet, "/get", nil),
+	}
+	client := NewClient(routes, "", LoadBalance(&LoadBalancer{
+		Endpoints: []string{"http://127.0.0.1:1"},
+		HealthCheck: &HealthCheck{
+			Path:    "/health",
+			Timeout: 10 * time.Millisecond,
+		},
+	}))
+	client.Close()
+	client.Close()
+}
+
+func TestRebase(t *testing.T) {
+	u, err := url.Parse("http://base/api/users/a%2Fb?x=1")
+	require.NoError(t, err)
+	e := &endpoint{url: &url.URL{Scheme: "https", Host: "replica:8443", Path: "/v1"}}
+	require.Equal(t, "https://replica:8443/v1/users/a%2Fb?x=1", rebase(u, "/api", e).String())
+
+	u, err = url.Parse("/users?x=1")
+	require.NoError(t, err)
+	require.Equal(t, "https://replica:8443/v1/users?x=1", rebase(u, "", e).String())
+}
@@ -0,0 +1,107 @@
+package api2
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"slices"
+	"time"
+)
+
+// CallOption changes a single call made by Client. Pass it to Client.Call,
+// Call, Endpoint.Call or methods of WithOptions of a generated client or
+// attach it to the context with WithCallOptions, e.g. to use it with methods
+// of a generated client implementing the service interface.
+type CallOption func(o *callOptions)
+
+type callOptions struct {
+	header        http.Header
+	timeout       time.Duration
+	authorization *string
+	human         *bool
+}
+
+// CallHeader adds the header to the request. Values set by CallHeader
+// replace values of the header set by the transport.
+func CallHeader(key, value string) CallOption {
+	return func(o *callOptions) {
+		if o.header == nil {
+			o.header = make(http.Header)
+		}
+		o.header.Add(key, value)
+	}
+}
+
+// CallTimeout limits the duration of the call including retries. If the
+// response is streamed, the limit applies to reading it as well.
+func CallTimeout(timeout time.Duration) CallOption {
+	return func(o *callOptions) {
+		o.timeout = timeout
+	}
+}
+
+// CallAuthorization replaces the value of Authorization header set by
+// option AuthorizationHeader or Credentials. Empty value removes the header.
+func CallAuthorization(authorization string) CallOption {
+	return func(o *callOptions) {
+		o.authorization = &authorization
+	}
+}
+
+// CallHuman overrides option HumanJSON of the client.
+func CallHuman(enabled bool) CallOption {
+	return func(o *callOptions) {
+		o.human = &enabled
+	}
+}
+
+type callOptionsType struct{}
+
+// WithCallOptions returns the context carrying the options. They are applied
+// to calls made with the context after the options already carried by it
+// and before the options passed to the call directly.
+func WithCallOptions(ctx context.Context, opts ...CallOption) context.Context {
+	if len(opts) == 0 {
+		return ctx
+	}
+	prev, _ := ctx.Value(callOptionsType{}).([]CallOption)
+	return context.WithValue(ctx, callOptionsType{}, append(slices.Clip(prev), opts...))
+}
+
+// getCallOptions returns the options carried by the context or nil.
+func getCallOptions(ctx context.Context) *callOptions {
+	opts, _ := ctx.Value(callOptionsType{}).([]CallOption)
+	if len(opts) == 0 {
+		return nil
+	}
+	o := &callOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+func (o *callOptions) apply(req *http.Request) {
+	if o.authorization != nil {
+		if *o.authorization == "" {
+			req.Header.Del("Authorization")
+		} else {
+			req.Header.Set("Authorization", *o.authorization)
+		}
+	}
+	for key, values := range o.header {
+		req.Header[key] = values
+	}
+}
+
+// cancelOnClose cancels the context of a streamed response when its body
+// is closed.
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c *cancelOnClose) Close() error {
+	defer c.cancel()
+	return c.ReadCloser.Close()
+}
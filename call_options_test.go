@@ -0,0 +1,42 @@
+package api2
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCallOptions(t *testing.T) {
+	require.Nil(t, getCallOptions(context.Background()))
+
+	ctx := WithCallOptions(context.Background(), CallTimeout(time.Second), CallHeader("X-A", "1"))
+	ctx1 := WithCallOptions(ctx, CallTimeout(time.Minute), CallAuthorization(""))
+	ctx2 := WithCallOptions(ctx, CallHuman(true), CallHeader("X-A", "2"))
+
+	o := getCallOptions(ctx)
+	require.Equal(t, time.Second, o.timeout)
+	require.Nil(t, o.authorization)
+	require.Nil(t, o.human)
+
+	// Later options override earlier ones, sibling contexts don't interfere.
+	o1 := getCallOptions(ctx1)
+	require.Equal(t, time.Minute, o1.timeout)
+	require.Equal(t, []string{"1"}, o1.header["X-A"])
+	require.Nil(t, o1.human)
+
+	o2 := getCallOptions(ctx2)
+	require.Equal(t, time.Second, o2.timeout)
+	require.Equal(t, []string{"1", "2"}, o2.header["X-A"])
+	require.True(t, *o2.human)
+
+	req, err := http.NewRequest(http.MethodGet, "http://example.com", nil)
+	require.NoError(t, err)
+	req.Header.Set("Authorization", "old")
+	req.Header.Set("X-A", "0")
+	o1.apply(req)
+	require.Empty(t, req.Header.Get("Authorization"))
+	require.Equal(t, []string{"1"}, req.Header.Values("X-A"))
+}
@@ -0,0 +1,316 @@
+package api2
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc/codes"
+)
+
+// CircuitState is the state of a circuit breaker.
+type CircuitState int
+
+const (
+	// CircuitClosed means that calls are allowed.
+	CircuitClosed CircuitState = iota
+
+	// CircuitOpen means that calls fail fast with CircuitOpenError.
+	CircuitOpen
+
+	// CircuitHalfOpen means that a few trial calls are allowed to check
+	// if the service has recovered.
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	}
+	return fmt.Sprintf("CircuitState(%d)", int(s))
+}
+
+// CircuitBreakerPolicy configures circuit breakers of Client. Pass it to
+// NewClient in option CircuitBreaker. Use DefaultCircuitBreakerPolicy to get
+// reasonable values.
+//
+// The client has a circuit breaker per route (named "route METHOD /path")
+// and per host of the URL (named "host example.com:8080"). A call is made
+// only if both allow it. With LoadBalance, host breakers are kept for each
+// endpoint and the balancer skips endpoints whose breakers are open.
+type CircuitBreakerPolicy struct {
+	// The breaker opens if at least MinRequests calls were made during
+	// Window and the fraction of failed calls is at least FailureRatio.
+	// Counters are reset every Window.
+	FailureRatio float64
+	MinRequests  int
+	Window       time.Duration
+
+	// Cooldown is the time the breaker stays open. Then it becomes half-open
+	// and lets HalfOpenRequests (default 1) trial calls through. If all of
+	// them succeed, the breaker closes, otherwise it opens again.
+	Cooldown         time.Duration
+	HalfOpenRequests int
+
+	// IsFailure tells if the result of HttpClient.Do is a failure. If it is
+	// nil, errors and responses with status codes 5xx and 429 are failures.
+	// Calls canceled by ctx are not counted.
+	IsFailure func(res *http.Response, err error) bool
+
+	// OnStateChange is called when a breaker changes its state, e.g. to
+	// alert. It must not block.
+	OnStateChange func(name string, from, to CircuitState)
+}
+
+// DefaultCircuitBreakerPolicy returns a policy opening a breaker if at least
+// half of at least 10 calls during 10 seconds failed. It stays open for
+// 5 seconds, then one trial call is made.
+func DefaultCircuitBreakerPolicy() *CircuitBreakerPolicy {
+	return &CircuitBreakerPolicy{
+		FailureRatio:     0.5,
+		MinRequests:      10,
+		Window:           10 * time.Second,
+		Cooldown:         5 * time.Second,
+		HalfOpenRequests: 1,
+	}
+}
+
+func (p *CircuitBreakerPolicy) halfOpenRequests() int {
+	if p.HalfOpenRequests > 0 {
+		return p.HalfOpenRequests
+	}
+	return 1
+}
+
+func (p *CircuitBreakerPolicy) isFailure(res *http.Response, err error) bool {
+	if p.IsFailure != nil {
+		return p.IsFailure(res, err)
+	}
+	return err != nil || res.StatusCode >= 500 || res.StatusCode == http.StatusTooManyRequests
+}
+
+// CircuitOpenError is returned by Client if a circuit breaker does not let
+// the call through. It has code Unavailable of package errors:
+// errors.Code(err) returns codes.Unavailable and
+// errors.Is(err, errors.Unavailable("")) is true.
+type CircuitOpenError struct {
+	// Name of the breaker.
+	Name string
+
+	// RetryAfter is the remaining time until the breaker becomes half-open.
+	RetryAfter time.Duration
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("circuit breaker %q is open, retry after %s", e.Name, e.RetryAfter)
+}
+
+// HttpCode returns 503 Service Unavailable.
+func (e *CircuitOpenError) HttpCode() int {
+	return http.StatusServiceUnavailable
+}
+
+// Code returns codes.Unavailable.
+func (e *CircuitOpenError) Code() codes.Code {
+	return codes.Unavailable
+}
+
+// Is tells if target is an error with code Unavailable, e.g. created by
+// errors.Unavailable.
+func (e *CircuitOpenError) Is(target error) bool {
+	coder, ok := target.(interface{ Code() codes.Code })
+	return ok && coder.Code() == codes.Unavailable
+}
+
+type circuitBreakers struct {
+	policy *CircuitBreakerPolicy
+
+	mu       sync.Mutex
+	breakers map[string]*circuitBreaker
+}
+
+type circuitBreaker struct {
+	name  string
+	state CircuitState
+
+	// Incremented on each change of state to ignore results of calls
+	// started in the previous state.
+	generation uint64
+
+	windowStart time.Time
+	requests    int
+	failures    int
+
+	openedAt time.Time
+
+	halfOpenInFlight  int
+	halfOpenSuccesses int
+}
+
+type circuitTransition struct {
+	name     string
+	from, to CircuitState
+}
+
+// circuitTicket is a permission to make a call.
+type circuitTicket struct {
+	b          *circuitBreaker
+	generation uint64
+}
+
+func newCircuitBreakers(policy *CircuitBreakerPolicy) *circuitBreakers {
+	return &circuitBreakers{
+		policy:   policy,
+		breakers: make(map[string]*circuitBreaker),
+	}
+}
+
+// allow returns tickets of the breakers if all of them let the call through.
+func (cb *circuitBreakers) allow(names ...string) ([]circuitTicket, error) {
+	now := time.Now()
+	var transitions []circuitTransition
+	defer func() {
+		cb.notify(transitions)
+	}()
+
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	tickets := make([]circuitTicket, 0, len(names))
+	for _, name := range names {
+		b, has := cb.breakers[name]
+		if !has {
+			b = &circuitBreaker{name: name, windowStart: now}
+			cb.breakers[name] = b
+		}
+		if b.state == CircuitOpen && now.Sub(b.openedAt) >= cb.policy.Cooldown {
+			transitions = append(transitions, b.setState(CircuitHalfOpen, now))
+		}
+		var err error
+		switch b.state {
+		case CircuitOpen:
+			err = &CircuitOpenError{
+				Name:       name,
+				RetryAfter: b.openedAt.Add(cb.policy.Cooldown).Sub(now),
+			}
+		case CircuitHalfOpen:
+			if b.halfOpenInFlight >= cb.policy.halfOpenRequests() {
+				err = &CircuitOpenError{Name: name}
+			} else {
+				b.halfOpenInFlight++
+			}
+		}
+		if err != nil {
+			// Release already taken tickets.
+			for _, t := range tickets {
+				cb.releaseLocked(t)
+			}
+			return nil, err
+		}
+		tickets = append(tickets, circuitTicket{b: b, generation: b.generation})
+	}
+	return tickets, nil
+}
+
+func (cb *circuitBreakers) releaseLocked(t circuitTicket) {
+	if t.generation == t.b.generation && t.b.state == CircuitHalfOpen {
+		t.b.halfOpenInFlight--
+	}
+}
+
+// done records the result of the call made with the tickets.
+// If ignore is true, the call is not counted.
+func (cb *circuitBreakers) done(tickets []circuitTicket, failed, ignore bool) {
+	now := time.Now()
+	var transitions []circuitTransition
+	defer func() {
+		cb.notify(transitions)
+	}()
+
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	p := cb.policy
+	for _, t := range tickets {
+		b := t.b
+		if ignore || t.generation != b.generation {
+			cb.releaseLocked(t)
+			continue
+		}
+		switch b.state {
+		case CircuitClosed:
+			if now.Sub(b.windowStart) >= p.Window {
+				b.windowStart = now
+				b.requests = 0
+				b.failures = 0
+			}
+			b.requests++
+			if failed {
+				b.failures++
+			}
+			if b.requests >= p.MinRequests && float64(b.failures) >= p.FailureRatio*float64(b.requests) {
+				transitions = append(transitions, b.setState(CircuitOpen, now))
+			}
+		case CircuitHalfOpen:
+			b.halfOpenInFlight--
+			if failed {
+				transitions = append(transitions, b.setState(CircuitOpen, now))
+				continue
+			}
+			b.halfOpenSuccesses++
+			if b.halfOpenSuccesses >= p.halfOpenRequests() {
+				transitions = append(transitions, b.setState(CircuitClosed, now))
+			}
+		}
+	}
+}
+
+func (b *circuitBreaker) setState(state CircuitState, now time.Time) circuitTransition {
+	transition := circuitTransition{name: b.name, from: b.state, to: state}
+	b.state = state
+	b.generation++
+	b.windowStart = now
+	b.requests = 0
+	b.failures = 0
+	b.openedAt = now
+	b.halfOpenInFlight = 0
+	b.halfOpenSuccesses = 0
+	return transition
+}
+
+func (cb *circuitBreakers) notify(transitions []circuitTransition) {
+	if cb.policy.OnStateChange == nil {
+		return
+	}
+	for _, t := range transitions {
+		cb.policy.OnStateChange(t.name, t.from, t.to)
+	}
+}
+
+// send sends the request if circuit breakers of the route and the host allow
+// it and records the result.
+func (c *Client) send(ctx context.Context, route Route, req *http.Request) (*http.Response, error) {
+	if c.breakers == nil {
+		return c.do(ctx, route, req)
+	}
+	names := []string{"route " + route.Method + " " + route.Path}
+	if c.balancer == nil {
+		// Otherwise host breakers are checked by the balancer per endpoint.
+		names = append(names, "host "+req.URL.Host)
+	}
+	tickets, err := c.breakers.allow(names...)
+	if err != nil {
+		return nil, err
+	}
+	res, err := c.do(ctx, route, req)
+	ignore := err != nil && ctx.Err() != nil
+	c.breakers.done(tickets, !ignore && c.breakers.policy.isFailure(res, err), ignore)
+	return res, err
+}
@@ -0,0 +1,150 @@
+package api2
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	apierrors "github.com/starius/api2/errors"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+)
+
+func TestCircuitBreakerStates(t *testing.T) {
+	type transition struct {
+		name     string
+		from, to CircuitState
+	}
+	var transitions []transition
+	cb := newCircuitBreakers(&CircuitBreakerPolicy{
+		FailureRatio:     0.5,
+		MinRequests:      4,
+		Window:           time.Hour,
+		Cooldown:         50 * time.Millisecond,
+		HalfOpenRequests: 2,
+		OnStateChange: func(name string, from, to CircuitState) {
+			transitions = append(transitions, transition{name, from, to})
+		},
+	})
+
+	call := func(failed bool) error {
+		tickets, err := cb.allow("a", "b")
+		if err != nil {
+			return err
+		}
+		cb.done(tickets, failed, false)
+		return nil
+	}
+
+	// 1 of 4 failed: stays closed.
+	require.NoError(t, call(true))
+	require.NoError(t, call(false))
+	require.NoError(t, call(false))
+	require.NoError(t, call(false))
+	require.Empty(t, transitions)
+
+	// Canceled calls are not counted.
+	tickets, err := cb.allow("a", "b")
+	require.NoError(t, err)
+	cb.done(tickets, true, true)
+	require.Empty(t, transitions)
+
+	// 3 of 6 failed during the window: opens the breakers.
+	require.NoError(t, call(true))
+	require.NoError(t, call(true))
+	require.Equal(t, []transition{
+		{"a", CircuitClosed, CircuitOpen},
+		{"b", CircuitClosed, CircuitOpen},
+	}, transitions)
+	transitions = nil
+
+	err = call(false)
+	var openErr *CircuitOpenError
+	require.True(t, errors.As(err, &openErr))
+	require.Equal(t, "a", openErr.Name)
+	require.Greater(t, openErr.RetryAfter, time.Duration(0))
+	require.Equal(t, 503, openErr.HttpCode())
+	require.Equal(t, codes.Unavailable, apierrors.Code(err))
+	require.ErrorIs(t, err, apierrors.Unavailable("unavailable"))
+	require.NotErrorIs(t, err, apierrors.NotFound("not found"))
+
+	// After the cooldown, 2 trial calls are allowed.
+	time.Sleep(60 * time.Millisecond)
+	t1, err := cb.allow("a", "b")
+	require.NoError(t, err)
+	t2, err := cb.allow("a", "b")
+	require.NoError(t, err)
+	_, err = cb.allow("a", "b")
+	require.ErrorAs(t, err, &openErr)
+	require.Equal(t, []transition{
+		{"a", CircuitOpen, CircuitHalfOpen},
+		{"b", CircuitOpen, CircuitHalfOpen},
+	}, transitions)
+	transitions = nil
+
+	// A failed trial call opens the breakers again.
+	cb.done(t1, false, false)
+	cb.done(t2, true, false)
+	require.Equal(t, []transition{
+		{"a", CircuitHalfOpen, CircuitOpen},
+		{"b", CircuitHalfOpen, CircuitOpen},
+	}, transitions)
+	transitions = nil
+
+	// Successful trial calls close the breakers.
+	time.Sleep(60 * time.Millisecond)
+	require.NoError(t, call(false))
+	require.NoError(t, call(false))
+	require.Equal(t, []transition{
+		{"a", CircuitOpen, CircuitHalfOpen},
+		{"b", CircuitOpen, CircuitHalfOpen},
+		{"a", CircuitHalfOpen, CircuitClosed},
+		{"b", CircuitHalfOpen, CircuitClosed},
+	}, transitions)
+}
+
+func TestCircuitBreakerReleasesTickets(t *testing.T) {
+	cb := newCircuitBreakers(&CircuitBreakerPolicy{
+		FailureRatio:     0.5,
+		MinRequests:      1,
+		Window:           time.Hour,
+		Cooldown:         time.Hour,
+		HalfOpenRequests: 1,
+	})
+
+	// Open breaker "b" only.
+	tickets, err := cb.allow("b")
+	require.NoError(t, err)
+	cb.done(tickets, true, false)
+
+	// Breaker "a" is half-open: its trial call is released if "b" rejects.
+	cb.breakers["a"] = &circuitBreaker{name: "a", state: CircuitHalfOpen}
+	_, err = cb.allow("a", "b")
+	require.Error(t, err)
+	require.Equal(t, 0, cb.breakers["a"].halfOpenInFlight)
+}
+
+func TestCircuitBreakerDefaultHalfOpenRequests(t *testing.T) {
+	cb := newCircuitBreakers(&CircuitBreakerPolicy{
+		FailureRatio: 0.5,
+		MinRequests:  1,
+		Window:       time.Hour,
+		Cooldown:     10 * time.Millisecond,
+	})
+	tickets, err := cb.allow("a")
+	require.NoError(t, err)
+	cb.done(tickets, true, false)
+	_, err = cb.allow("a")
+	require.Error(t, err)
+
+	// One trial call is allowed and closes the breaker.
+	time.Sleep(20 * time.Millisecond)
+	tickets, err = cb.allow("a")
+	require.NoError(t, err)
+	_, err = cb.allow("a")
+	require.Error(t, err)
+	cb.done(tickets, false, false)
+	tickets, err = cb.allow("a")
+	require.NoError(t, err)
+	cb.done(tickets, false, false)
+}
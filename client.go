@@ -2,10 +2,13 @@ package api2
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"reflect"
+	"time"
 )
 
 // Client is used on client-side to call remote methods provided by the API.
@@ -17,6 +20,15 @@ type Client struct {
 	authorization string
 	maxBody       int64
 	human         bool
+	retry         *RetryPolicy
+	breakers      *circuitBreakers
+	interceptor   Interceptor
+	credentials   CredentialProvider
+	signer        *RequestSigner
+	balancer      *balancer
+	basePath      string
+	hedger        *hedger
+	direct        map[string]directRoute // By method and path.
 }
 
 type signature struct {
@@ -24,6 +36,19 @@ type signature struct {
 	response reflect.Type
 }
 
+func routeSignature(route Route) signature {
+	handler := route.Handler
+	if f, ok := handler.(funcer); ok {
+		handler = f.Func()
+	}
+	handlerType := reflect.TypeOf(handler)
+	validateHandler(handlerType, route.Path)
+	return signature{
+		request:  handlerType.In(1),
+		response: handlerType.Out(0),
+	}
+}
+
 // NewClient creates new instance of client.
 //
 // The list of routes must provide all routes that this client is aware of.
@@ -41,16 +66,7 @@ func NewClient(routes []Route, baseURL string, opts ...Option) *Client {
 
 	routeMap := make(map[signature]Route, len(routes))
 	for _, route := range routes {
-		handler := route.Handler
-		if m, ok := handler.(*interfaceMethod); ok {
-			handler = m.Func()
-		}
-		handlerType := reflect.TypeOf(handler)
-		validateHandler(handlerType, route.Path)
-		key := signature{
-			request:  handlerType.In(1),
-			response: handlerType.Out(0),
-		}
+		key := routeSignature(route)
 		if _, has := routeMap[key]; has {
 			panic(fmt.Sprintf("Already has a handler with signature %v.", key))
 		}
@@ -65,6 +81,29 @@ func NewClient(routes []Route, baseURL string, opts ...Option) *Client {
 		client = config.client
 	}
 
+	var b *balancer
+	var basePath string
+	if config.balancer != nil {
+		b = newBalancer(config.balancer, client, config.errorf)
+		if baseURL != "" {
+			u, err := url.Parse(baseURL)
+			if err != nil {
+				panic(fmt.Sprintf("bad baseURL %q: %v", baseURL, err))
+			}
+			basePath = u.Path
+		}
+	}
+
+	var h *hedger
+	if config.hedge != nil {
+		h = newHedger(config.hedge)
+	}
+
+	var breakers *circuitBreakers
+	if config.circuitBreaker != nil {
+		breakers = newCircuitBreakers(config.circuitBreaker)
+	}
+
 	return &Client{
 		routeMap:      routeMap,
 		client:        client,
@@ -73,6 +112,14 @@ func NewClient(routes []Route, baseURL string, opts ...Option) *Client {
 		authorization: config.authorization,
 		maxBody:       config.maxBody,
 		human:         config.human,
+		retry:         config.retry,
+		breakers:      breakers,
+		interceptor:   config.interceptor,
+		credentials:   config.credentials,
+		signer:        config.signer,
+		balancer:      b,
+		basePath:      basePath,
+		hedger:        h,
 	}
 }
 
@@ -96,15 +143,42 @@ func bodyCloseNeeded(ctx context.Context, response, request interface{}, t Trans
 // Call calls remote method deduced by request and response types.
 // Both request and response must be pointers to structs.
 // The method must be called on exactly the same types as the
-// corresponding method of a service.
-func (c *Client) Call(ctx context.Context, response, request interface{}) error {
-	key := signature{
-		request:  reflect.TypeOf(request),
-		response: reflect.TypeOf(response),
+// corresponding method of a service. Options change only this call.
+func (c *Client) Call(ctx context.Context, response, request interface{}, opts ...CallOption) error {
+	route, err := c.findRoute(reflect.TypeOf(request), reflect.TypeOf(response))
+	if err != nil {
+		panic(err.Error())
 	}
-	route, has := c.routeMap[key]
+	return c.callRoute(WithCallOptions(ctx, opts...), route, response, request)
+}
+
+// ErrNoRoute is returned by Call and NewEndpoint if the client has no route
+// with the types of request and response.
+var ErrNoRoute = errors.New("no registered method with signature")
+
+func (c *Client) findRoute(request, response reflect.Type) (Route, error) {
+	route, has := c.routeMap[signature{request: request, response: response}]
 	if !has {
-		panic(fmt.Sprintf("No registered method with signature %v %v.", key.request, key.response))
+		return Route{}, fmt.Errorf("%w %v %v", ErrNoRoute, request, response)
+	}
+	return route, nil
+}
+
+func (c *Client) callRoute(ctx context.Context, route Route, response, request interface{}) error {
+	if c.interceptor != nil {
+		return c.interceptor(ctx, &route, request, response, c.invoke)
+	}
+	return c.invokeRoute(ctx, route, response, request)
+}
+
+// invoke is the Invoker passed to the interceptors.
+func (c *Client) invoke(ctx context.Context, route *Route, req, res any) error {
+	return c.invokeRoute(ctx, *route, res, req)
+}
+
+func (c *Client) invokeRoute(ctx context.Context, route Route, response, request interface{}) (err error) {
+	if c.direct != nil {
+		return c.callDirect(ctx, route, response, request)
 	}
 
 	t := route.Transport
@@ -112,8 +186,26 @@ func (c *Client) Call(ctx context.Context, response, request interface{}) error
 		t = DefaultTransport
 	}
 
-	url := c.baseURL + route.Path
-	if c.human {
+	human := c.human
+	o := getCallOptions(ctx)
+	if o != nil && o.human != nil {
+		human = *o.human
+	}
+
+	streamed := !bodyCloseNeeded(ctx, response, request, t)
+	var cancel context.CancelFunc
+	if o != nil && o.timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, o.timeout)
+		defer func() {
+			// A streamed body cancels the context when it is closed.
+			if err != nil || !streamed {
+				cancel()
+			}
+		}()
+	}
+
+	url := c.baseURL + stripParamConstraints(route.Path)
+	if human {
 		url += "?human=on"
 		ctx = context.WithValue(ctx, humanType{}, true)
 	}
@@ -126,14 +218,29 @@ func (c *Client) Call(ctx context.Context, response, request interface{}) error
 	if c.authorization != "" {
 		req.Header.Set("Authorization", c.authorization)
 	}
+	if o != nil {
+		o.apply(req)
+	}
 
-	res, err := c.client.Do(req)
+	var res *http.Response
+	if c.credentials != nil && (o == nil || o.authorization == nil) {
+		res, err = c.sendAuthorized(ctx, route, req)
+	} else {
+		res, err = c.send(ctx, route, req)
+	}
 	if err != nil {
+		var openErr *CircuitOpenError
+		if errors.As(err, &openErr) {
+			return err
+		}
 		return fmt.Errorf("request failed: %w", err)
 	}
 	res.Body = http.MaxBytesReader(nil, res.Body, c.maxBody)
+	if cancel != nil && streamed {
+		res.Body = &cancelOnClose{ReadCloser: res.Body, cancel: cancel}
+	}
 	defer func() {
-		if !bodyCloseNeeded(ctx, request, response, t) {
+		if streamed {
 			return
 		}
 		if err := res.Body.Close(); err != nil {
@@ -151,7 +258,28 @@ func (c *Client) Call(ctx context.Context, response, request interface{}) error
 	}
 }
 
+// doOnce makes one attempt to send the request.
+func (c *Client) doOnce(route Route, req *http.Request) (*http.Response, error) {
+	if c.balancer != nil {
+		return c.balancer.do(c, route, req)
+	}
+	return c.sendTo(req)
+}
+
+// sendTo signs the request if needed and sends it.
+func (c *Client) sendTo(req *http.Request) (*http.Response, error) {
+	if c.signer != nil {
+		if err := c.signer.sign(req, time.Now()); err != nil {
+			return nil, fmt.Errorf("failed to sign request: %w", err)
+		}
+	}
+	return c.client.Do(req)
+}
+
 func (c *Client) Close() error {
+	if c.balancer != nil {
+		c.balancer.close()
+	}
 	c.client.CloseIdleConnections()
 
 	if closer, ok := c.client.(io.Closer); ok {
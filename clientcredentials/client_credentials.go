@@ -0,0 +1,142 @@
+// Package clientcredentials implements api2.CredentialProvider getting
+// tokens using OAuth 2.0 client credentials grant (RFC 6749, section 4.4).
+package clientcredentials
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/starius/api2"
+)
+
+type HttpClient interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+type Config struct {
+	// TokenURL is the token endpoint of the authorization server.
+	TokenURL string
+
+	ClientID     string
+	ClientSecret string
+
+	// Scopes requested, optional.
+	Scopes []string
+
+	// EndpointParams are additional parameters of the token request,
+	// e.g. "audience".
+	EndpointParams url.Values
+
+	// AuthInParams makes the client pass its credentials in the body of the
+	// token request instead of HTTP Basic authentication.
+	AuthInParams bool
+
+	// HttpClient used to get tokens. It can be wrapped, e.g. by debugclient.
+	// Defaults to http.DefaultClient.
+	HttpClient HttpClient
+}
+
+// Error is returned if the authorization server responds with an error.
+type Error struct {
+	StatusCode       int
+	ErrorCode        string
+	ErrorDescription string
+}
+
+func (e *Error) Error() string {
+	if e.ErrorDescription != "" {
+		return fmt.Sprintf("token endpoint returned HTTP status %d: %s: %s", e.StatusCode, e.ErrorCode, e.ErrorDescription)
+	}
+	return fmt.Sprintf("token endpoint returned HTTP status %d: %s", e.StatusCode, e.ErrorCode)
+}
+
+type tokenResponse struct {
+	AccessToken      string `json:"access_token"`
+	TokenType        string `json:"token_type"`
+	ExpiresIn        int64  `json:"expires_in"`
+	ErrorCode        string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+// maxTokenResponse limits the size of response of the token endpoint.
+const maxTokenResponse = 1024 * 1024
+
+// New returns a provider caching tokens until they expire.
+func New(config Config) (api2.CredentialProvider, error) {
+	if _, err := url.ParseRequestURI(config.TokenURL); err != nil {
+		return nil, err
+	}
+	if config.HttpClient == nil {
+		config.HttpClient = http.DefaultClient
+	}
+	return api2.CacheCredentials(config.fetch), nil
+}
+
+func (c *Config) fetch(ctx context.Context) (string, time.Time, error) {
+	params := url.Values{}
+	for key, values := range c.EndpointParams {
+		params[key] = values
+	}
+	params.Set("grant_type", "client_credentials")
+	if len(c.Scopes) != 0 {
+		params.Set("scope", strings.Join(c.Scopes, " "))
+	}
+	if c.AuthInParams {
+		params.Set("client_id", c.ClientID)
+		params.Set("client_secret", c.ClientSecret)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.TokenURL, strings.NewReader(params.Encode()))
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if !c.AuthInParams {
+		req.SetBasicAuth(url.QueryEscape(c.ClientID), url.QueryEscape(c.ClientSecret))
+	}
+
+	start := time.Now()
+	res, err := c.HttpClient.Do(req)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("token request failed: %w", err)
+	}
+	defer res.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(res.Body, maxTokenResponse))
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to read token response: %w", err)
+	}
+	var token tokenResponse
+	jsonErr := json.Unmarshal(body, &token)
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		e := &Error{StatusCode: res.StatusCode}
+		if jsonErr == nil {
+			e.ErrorCode = token.ErrorCode
+			e.ErrorDescription = token.ErrorDescription
+		}
+		return "", time.Time{}, e
+	}
+	if jsonErr != nil {
+		return "", time.Time{}, fmt.Errorf("failed to parse token response: %w", jsonErr)
+	}
+	if token.AccessToken == "" {
+		return "", time.Time{}, fmt.Errorf("token response has no access_token")
+	}
+
+	tokenType := token.TokenType
+	if tokenType == "" || strings.EqualFold(tokenType, "bearer") {
+		tokenType = "Bearer"
+	}
+	var expiry time.Time
+	if token.ExpiresIn > 0 {
+		expiry = start.Add(time.Duration(token.ExpiresIn) * time.Second)
+	}
+	return tokenType + " " + token.AccessToken, expiry, nil
+}
@@ -0,0 +1,102 @@
+package clientcredentials
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newTokenServer(t *testing.T, issued *atomic.Int32) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, http.MethodPost, r.Method)
+		require.NoError(t, r.ParseForm())
+		clientID, clientSecret, ok := r.BasicAuth()
+		if ok {
+			// Form-encoded as required by RFC 6749, section 2.3.1.
+			clientID, _ = url.QueryUnescape(clientID)
+			clientSecret, _ = url.QueryUnescape(clientSecret)
+		} else {
+			clientID = r.PostForm.Get("client_id")
+			clientSecret = r.PostForm.Get("client_secret")
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if r.PostForm.Get("grant_type") != "client_credentials" || clientID != "my id" || clientSecret != "s3cret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			_ = json.NewEncoder(w).Encode(map[string]string{
+				"error":             "invalid_client",
+				"error_description": "bad credentials",
+			})
+			return
+		}
+		n := issued.Add(1)
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": "token-" + r.PostForm.Get("scope") + "-" + r.PostForm.Get("audience") + "-" + string(rune('0'+n)),
+			"token_type":   "bearer",
+			"expires_in":   3600,
+		})
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestClientCredentials(t *testing.T) {
+	var issued atomic.Int32
+	server := newTokenServer(t, &issued)
+	ctx := context.Background()
+
+	for _, authInParams := range []bool{false, true} {
+		issued.Store(0)
+		provider, err := New(Config{
+			TokenURL:       server.URL,
+			ClientID:       "my id",
+			ClientSecret:   "s3cret",
+			Scopes:         []string{"read", "write"},
+			EndpointParams: url.Values{"audience": {"api"}},
+			AuthInParams:   authInParams,
+		})
+		require.NoError(t, err)
+
+		value, err := provider.Credential(ctx, "")
+		require.NoError(t, err)
+		require.Equal(t, "Bearer token-read write-api-1", value)
+
+		// Cached.
+		value, err = provider.Credential(ctx, "")
+		require.NoError(t, err)
+		require.Equal(t, "Bearer token-read write-api-1", value)
+
+		// Refreshed after rejection.
+		value, err = provider.Credential(ctx, value)
+		require.NoError(t, err)
+		require.Equal(t, "Bearer token-read write-api-2", value)
+		require.Equal(t, int32(2), issued.Load())
+	}
+}
+
+func TestClientCredentialsError(t *testing.T) {
+	var issued atomic.Int32
+	server := newTokenServer(t, &issued)
+
+	provider, err := New(Config{
+		TokenURL:     server.URL,
+		ClientID:     "my id",
+		ClientSecret: "wrong",
+	})
+	require.NoError(t, err)
+
+	_, err = provider.Credential(context.Background(), "")
+	var tokenErr *Error
+	require.ErrorAs(t, err, &tokenErr)
+	require.Equal(t, http.StatusUnauthorized, tokenErr.StatusCode)
+	require.Equal(t, "invalid_client", tokenErr.ErrorCode)
+	require.Equal(t, "bad credentials", tokenErr.ErrorDescription)
+
+	_, err = New(Config{TokenURL: "not a url"})
+	require.Error(t, err)
+}
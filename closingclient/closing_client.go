@@ -20,6 +20,8 @@ type ClosingClient struct {
 	closing       bool
 	cancels       map[uint64]func()
 	lastCancelKey uint64
+	done          chan struct{}
+	doneClosed    bool
 
 	wg sync.WaitGroup
 }
@@ -28,9 +30,13 @@ func New(impl HttpClient) (*ClosingClient, error) {
 	return &ClosingClient{
 		impl:    impl,
 		cancels: make(map[uint64]func()),
+		done:    make(chan struct{}),
 	}, nil
 }
 
+// Do sends the request. The call is in flight until Do returns an error or,
+// if it succeeds, until the body of the response is closed. This covers
+// streaming responses read after Do returns.
 func (c *ClosingClient) Do(req *http.Request) (*http.Response, error) {
 	ctx, cancel := context.WithCancel(req.Context())
 
@@ -51,31 +57,107 @@ func (c *ClosingClient) Do(req *http.Request) (*http.Response, error) {
 	c.cancels[key] = cancel
 	c.mu.Unlock()
 
-	defer func() {
-		c.mu.Lock()
-		defer c.mu.Unlock()
+	res, err := c.impl.Do(req.Clone(ctx))
+	if err != nil || res.Body == nil || res.Body == http.NoBody {
+		c.release(key)
+		return res, err
+	}
+	body := &trackedBody{
+		ReadCloser: res.Body,
+		release: func() {
+			c.release(key)
+		},
+	}
+	if rwc, ok := res.Body.(io.ReadWriteCloser); ok {
+		// E.g. the body of 101 Switching Protocols response.
+		res.Body = &trackedReadWriteBody{trackedBody: body, w: rwc}
+	} else {
+		res.Body = body
+	}
+	return res, nil
+}
+
+// release removes the finished call and cancels its context.
+func (c *ClosingClient) release(key uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if cancel, has := c.cancels[key]; has {
+		cancel()
 		delete(c.cancels, key)
-	}()
+	}
+	c.checkDoneLocked()
+}
 
-	return c.impl.Do(req.Clone(ctx))
+func (c *ClosingClient) checkDoneLocked() {
+	if c.closing && len(c.cancels) == 0 && !c.doneClosed {
+		close(c.done)
+		c.doneClosed = true
+	}
+}
+
+// InFlight returns the number of calls in flight, including responses whose
+// bodies are not closed yet.
+func (c *ClosingClient) InFlight() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.cancels)
+}
+
+// Done returns a channel closed when the client is closing (Shutdown or
+// Close was called) and no calls are in flight.
+func (c *ClosingClient) Done() <-chan struct{} {
+	return c.done
 }
 
 func (c *ClosingClient) CloseIdleConnections() {
 	c.impl.CloseIdleConnections()
 }
 
+// Close cancels all calls in flight and closes the client.
 func (c *ClosingClient) Close() error {
+	c.stop()
+	return c.finish()
+}
+
+// Shutdown closes the client gracefully. It stops accepting new calls and
+// waits for calls in flight to finish until ctx is done. Then it cancels
+// the remaining calls and closes the client like Close. If ctx expires
+// before all calls finish, its error is returned.
+func (c *ClosingClient) Shutdown(ctx context.Context) error {
 	c.mu.Lock()
-	if !c.closing {
-		c.closing = true
-		// Close active connections.
-		for _, cancel := range c.cancels {
-			cancel()
-		}
-		c.cancels = nil
-	}
+	c.closing = true
+	c.checkDoneLocked()
 	c.mu.Unlock()
 
+	var ctxErr error
+	select {
+	case <-c.done:
+	case <-ctx.Done():
+		ctxErr = ctx.Err()
+		c.stop()
+	}
+
+	if err := c.finish(); err != nil {
+		return err
+	}
+	return ctxErr
+}
+
+// stop stops accepting new calls and cancels calls in flight.
+func (c *ClosingClient) stop() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.closing = true
+	// Close active connections.
+	for _, cancel := range c.cancels {
+		cancel()
+	}
+	c.cancels = make(map[uint64]func())
+	c.checkDoneLocked()
+}
+
+// finish waits for calls of Do to return and closes the underlying client.
+func (c *ClosingClient) finish() error {
 	c.impl.CloseIdleConnections()
 
 	// Add(1) and Wait() must not be called in parallel.
@@ -92,3 +174,26 @@ func (c *ClosingClient) Close() error {
 
 	return nil
 }
+
+// trackedBody ends the call when the body is closed.
+type trackedBody struct {
+	io.ReadCloser
+	once    sync.Once
+	release func()
+}
+
+func (b *trackedBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.release)
+	return err
+}
+
+// trackedReadWriteBody is trackedBody keeping io.Writer of the body.
+type trackedReadWriteBody struct {
+	*trackedBody
+	w io.Writer
+}
+
+func (b *trackedReadWriteBody) Write(p []byte) (int, error) {
+	return b.w.Write(p)
+}
@@ -3,8 +3,10 @@ package closingclient
 import (
 	"context"
 	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -193,3 +195,217 @@ func TestClosingClient(t *testing.T) {
 		}
 	})
 }
+
+func TestClosingClientShutdown(t *testing.T) {
+	type HelloRequest struct {
+		Sleep time.Duration `json:"sleep"`
+	}
+	type HelloResponse struct {
+	}
+	type StreamRequest struct {
+	}
+	type StreamResponse struct {
+		Body io.ReadCloser `use_as_body:"true" is_stream:"true"`
+	}
+
+	helloHandler := func(ctx context.Context, req *HelloRequest) (res *HelloResponse, err error) {
+		time.Sleep(req.Sleep)
+		return &HelloResponse{}, nil
+	}
+	streamHandler := func(ctx context.Context, req *StreamRequest) (res *StreamResponse, err error) {
+		return &StreamResponse{Body: io.NopCloser(strings.NewReader("data"))}, nil
+	}
+
+	routes := []api2.Route{
+		{
+			Method:  http.MethodPost,
+			Path:    "/hello",
+			Handler: helloHandler,
+		},
+		{
+			Method:  http.MethodPost,
+			Path:    "/stream",
+			Handler: streamHandler,
+		},
+	}
+
+	mux := http.NewServeMux()
+	api2.BindRoutes(mux, routes)
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	ctx := context.Background()
+
+	t.Run("waits for calls in flight", func(t *testing.T) {
+		cc, err := New(&http.Client{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		client := api2.NewClient(routes, server.URL, api2.CustomClient(cc))
+
+		errCall := make(chan error, 1)
+		go func() {
+			errCall <- client.Call(ctx, &HelloResponse{}, &HelloRequest{Sleep: 300 * time.Millisecond})
+		}()
+		time.Sleep(100 * time.Millisecond)
+		if n := cc.InFlight(); n != 1 {
+			t.Errorf("Expected 1 call in flight, got %d.", n)
+		}
+
+		shutdownCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+		if err := cc.Shutdown(shutdownCtx); err != nil {
+			t.Errorf("Shutdown failed: %v.", err)
+		}
+		if err := <-errCall; err != nil {
+			t.Errorf("Call failed: %v.", err)
+		}
+		select {
+		case <-cc.Done():
+		default:
+			t.Errorf("Done is not closed after Shutdown.")
+		}
+
+		if err := client.Call(ctx, &HelloResponse{}, &HelloRequest{}); err == nil {
+			t.Errorf("Call after Shutdown not failed.")
+		}
+	})
+
+	t.Run("cancels calls after deadline", func(t *testing.T) {
+		cc, err := New(&http.Client{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		client := api2.NewClient(routes, server.URL, api2.CustomClient(cc))
+
+		errCall := make(chan error, 1)
+		go func() {
+			errCall <- client.Call(ctx, &HelloResponse{}, &HelloRequest{Sleep: time.Second})
+		}()
+		time.Sleep(100 * time.Millisecond)
+
+		t1 := time.Now()
+		shutdownCtx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+		defer cancel()
+		if err := cc.Shutdown(shutdownCtx); err != context.DeadlineExceeded {
+			t.Errorf("Expected Shutdown to return DeadlineExceeded, got %v.", err)
+		}
+		if spent := time.Since(t1); spent > time.Second/2 {
+			t.Errorf("Expected Shutdown to spend 0.5s or less, but spent %s.", spent)
+		}
+		if err := <-errCall; err == nil {
+			t.Errorf("Call not failed.")
+		}
+		if n := cc.InFlight(); n != 0 {
+			t.Errorf("Expected no calls in flight, got %d.", n)
+		}
+	})
+
+	t.Run("streaming body is in flight until closed", func(t *testing.T) {
+		cc, err := New(&http.Client{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		client := api2.NewClient(routes, server.URL, api2.CustomClient(cc))
+
+		res := &StreamResponse{}
+		if err := client.Call(ctx, res, &StreamRequest{}); err != nil {
+			t.Fatalf("Call failed: %v.", err)
+		}
+		if n := cc.InFlight(); n != 1 {
+			t.Errorf("Expected the open body to be in flight, got %d calls.", n)
+		}
+
+		shutdownDone := make(chan error, 1)
+		go func() {
+			shutdownDone <- cc.Shutdown(ctx)
+		}()
+		time.Sleep(50 * time.Millisecond)
+		select {
+		case <-cc.Done():
+			t.Errorf("Done is closed while the body is open.")
+		default:
+		}
+
+		data, err := io.ReadAll(res.Body)
+		if err != nil {
+			t.Errorf("Failed to read the body: %v.", err)
+		}
+		if string(data) != "data" {
+			t.Errorf("Expected body %q, got %q.", "data", data)
+		}
+		if err := res.Body.Close(); err != nil {
+			t.Errorf("Failed to close the body: %v.", err)
+		}
+		if err := <-shutdownDone; err != nil {
+			t.Errorf("Shutdown failed: %v.", err)
+		}
+		if n := cc.InFlight(); n != 0 {
+			t.Errorf("Expected no calls in flight, got %d.", n)
+		}
+	})
+}
+
+func TestClosingClientSwitchingProtocols(t *testing.T) {
+	// The server echoes the upgraded connection.
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, rw, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			t.Errorf("Failed to hijack: %v.", err)
+			return
+		}
+		defer conn.Close()
+		_, _ = rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: echo\r\n\r\n")
+		_ = rw.Flush()
+		_, _ = io.Copy(conn, rw)
+	}))
+	t.Cleanup(server.Close)
+
+	cc, err := New(&http.Client{})
+	if err != nil {
+		t.Fatalf("Failed to create ClosingClient: %v.", err)
+	}
+	t.Cleanup(func() {
+		if err := cc.Close(); err != nil {
+			t.Errorf("Close failed: %v.", err)
+		}
+	})
+
+	req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+	if err != nil {
+		t.Fatalf("Failed to create the request: %v.", err)
+	}
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "echo")
+	res, err := cc.Do(req)
+	if err != nil {
+		t.Fatalf("Do failed: %v.", err)
+	}
+	if res.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("Expected status 101, got %d.", res.StatusCode)
+	}
+
+	rwc, ok := res.Body.(io.ReadWriteCloser)
+	if !ok {
+		t.Fatalf("Expected the body to implement io.ReadWriteCloser, got %T.", res.Body)
+	}
+	if _, err := io.WriteString(rwc, "ping"); err != nil {
+		t.Fatalf("Failed to write: %v.", err)
+	}
+	buf := make([]byte, len("ping"))
+	if _, err := io.ReadFull(rwc, buf); err != nil {
+		t.Fatalf("Failed to read: %v.", err)
+	}
+	if string(buf) != "ping" {
+		t.Errorf("Expected %q, got %q.", "ping", buf)
+	}
+	if n := cc.InFlight(); n != 1 {
+		t.Errorf("Expected 1 call in flight, got %d.", n)
+	}
+	if err := rwc.Close(); err != nil {
+		t.Errorf("Failed to close the body: %v.", err)
+	}
+	if n := cc.InFlight(); n != 0 {
+		t.Errorf("Expected no calls in flight, got %d.", n)
+	}
+}
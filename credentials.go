@@ -0,0 +1,104 @@
+package api2
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// CredentialProvider provides the value of Authorization header of calls
+// made by Client. Pass it to NewClient in option Credentials. It is consulted
+// on every call, so it should cache the credentials, e.g. using
+// CacheCredentials.
+type CredentialProvider interface {
+	// Credential returns the value of Authorization header, e.g.
+	// "Bearer token". If rejected is not empty, the server responded with
+	// 401 Unauthorized to the call made with this value, so it must not be
+	// returned again.
+	Credential(ctx context.Context, rejected string) (string, error)
+}
+
+// credentialExpiryDelta is how long before the expiry cached credentials
+// are refreshed to let calls made with them complete.
+const credentialExpiryDelta = 10 * time.Second
+
+type cachedCredentials struct {
+	fetch func(ctx context.Context) (authorization string, expiry time.Time, err error)
+
+	mu            sync.Mutex
+	authorization string
+	expiry        time.Time
+}
+
+// CacheCredentials returns CredentialProvider calling fetch to get the value
+// of Authorization header and its expiry and reusing it until it expires or
+// is rejected by the server. Zero expiry means that the value does not
+// expire. Concurrent calls wait for the same fetch.
+func CacheCredentials(fetch func(ctx context.Context) (authorization string, expiry time.Time, err error)) CredentialProvider {
+	return &cachedCredentials{
+		fetch: fetch,
+	}
+}
+
+func (c *cachedCredentials) Credential(ctx context.Context, rejected string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	valid := c.authorization != "" && c.authorization != rejected &&
+		(c.expiry.IsZero() || time.Now().Add(credentialExpiryDelta).Before(c.expiry))
+	if valid {
+		return c.authorization, nil
+	}
+
+	authorization, expiry, err := c.fetch(ctx)
+	if err != nil {
+		return "", err
+	}
+	c.authorization = authorization
+	c.expiry = expiry
+	return authorization, nil
+}
+
+// sendAuthorized sets Authorization header from the credential provider
+// and sends the request. If the server responds with 401 Unauthorized, it
+// gets new credentials and sends the request once again, if its body can be
+// rewound.
+func (c *Client) sendAuthorized(ctx context.Context, route Route, req *http.Request) (*http.Response, error) {
+	authorization, err := c.credentials.Credential(ctx, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get credentials: %w", err)
+	}
+	req.Header.Set("Authorization", authorization)
+
+	canResend := req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+	res, err := c.send(ctx, route, req)
+	if err != nil || res.StatusCode != http.StatusUnauthorized || !canResend {
+		return res, err
+	}
+
+	authorization, err = c.credentials.Credential(ctx, authorization)
+	if err != nil {
+		// Return the original response.
+		c.errorf("failed to refresh credentials: %v", err)
+		return res, nil
+	}
+
+	// Let the connection be reused.
+	_, _ = io.Copy(io.Discard, io.LimitReader(res.Body, 64*1024))
+	if err := res.Body.Close(); err != nil {
+		c.errorf("failed to close resource: %v", err)
+	}
+
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, fmt.Errorf("failed to rewind request body: %w", err)
+		}
+		req.Body = body
+	}
+	req.Header.Set("Authorization", authorization)
+	return c.send(ctx, route, req)
+}
@@ -0,0 +1,55 @@
+package api2
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCacheCredentials(t *testing.T) {
+	var fetches int
+	var expiry time.Time
+	provider := CacheCredentials(func(ctx context.Context) (string, time.Time, error) {
+		fetches++
+		if fetches == 3 {
+			return "", time.Time{}, fmt.Errorf("token server is down")
+		}
+		return fmt.Sprintf("Bearer %d", fetches), expiry, nil
+	})
+	ctx := context.Background()
+
+	expiry = time.Now().Add(time.Hour)
+	for i := 0; i < 3; i++ {
+		value, err := provider.Credential(ctx, "")
+		require.NoError(t, err)
+		require.Equal(t, "Bearer 1", value)
+	}
+	require.Equal(t, 1, fetches)
+
+	// A value rejected earlier does not cause another fetch.
+	value, err := provider.Credential(ctx, "Bearer 0")
+	require.NoError(t, err)
+	require.Equal(t, "Bearer 1", value)
+
+	// Expiring soon.
+	expiry = time.Now().Add(time.Second)
+	value, err = provider.Credential(ctx, "Bearer 1")
+	require.NoError(t, err)
+	require.Equal(t, "Bearer 2", value)
+
+	_, err = provider.Credential(ctx, "")
+	require.ErrorContains(t, err, "token server is down")
+
+	// Zero expiry never expires.
+	expiry = time.Time{}
+	value, err = provider.Credential(ctx, "")
+	require.NoError(t, err)
+	require.Equal(t, "Bearer 4", value)
+	value, err = provider.Credential(ctx, "")
+	require.NoError(t, err)
+	require.Equal(t, "Bearer 4", value)
+	require.Equal(t, 4, fetches)
+}
@@ -1,11 +1,16 @@
 package debugclient
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"net/http/httptrace"
 	"net/http/httputil"
+	"sync"
 	"sync/atomic"
+	"time"
 
 	"moul.io/http2curl"
 )
@@ -15,46 +20,352 @@ type HttpClient interface {
 	CloseIdleConnections()
 }
 
+// Format of the log.
+type Format int
+
+const (
+	// Text logs requests as curl commands and responses as HTTP dumps.
+	Text Format = iota
+
+	// JSONLines logs each request and its response as Entry in JSON,
+	// one per line.
+	JSONLines
+
+	// HAR collects requests and writes them as HTTP Archive 1.2 when the
+	// client is closed or flushed.
+	HAR
+)
+
 type DebugClient struct {
 	impl HttpClient
 	log  io.Writer
 	n    uint64
+
+	format           Format
+	redactHeaders    []string
+	redactJSONFields []string
+	maxBodySize      int
+	teeStreams       bool
+
+	mu      sync.Mutex
+	entries []*Entry // Only in HAR format.
+}
+
+// Option configures DebugClient.
+type Option func(c *DebugClient)
+
+// WithFormat sets the format of the log. Defaults to Text.
+func WithFormat(format Format) Option {
+	return func(c *DebugClient) {
+		c.format = format
+	}
+}
+
+// RedactHeaders replaces values of the headers of requests and responses
+// with "REDACTED" in the log.
+func RedactHeaders(names ...string) Option {
+	return func(c *DebugClient) {
+		c.redactHeaders = append(c.redactHeaders, names...)
+	}
+}
+
+// RedactJSONFields replaces values of the fields of JSON objects at any
+// depth in bodies with "REDACTED" in the log.
+func RedactJSONFields(names ...string) Option {
+	return func(c *DebugClient) {
+		c.redactJSONFields = append(c.redactJSONFields, names...)
+	}
+}
+
+// MaxBodySize limits the number of bytes of each body in the log.
+func MaxBodySize(n int) Option {
+	return func(c *DebugClient) {
+		c.maxBodySize = n
+	}
+}
+
+// TeeStreams makes the client pass response bodies to the caller as they
+// arrive, copying them to the log, instead of reading them fully before
+// returning the response. The response is logged when its body is read to
+// the end or closed. Streaming request bodies (without GetBody) are copied
+// to the log as they are sent, instead of being read before the request is
+// sent. Such a request is logged when its body is sent or closed. Use it
+// with streaming requests and responses.
+func TeeStreams(enabled bool) Option {
+	return func(c *DebugClient) {
+		c.teeStreams = enabled
+	}
 }
 
-func New(impl HttpClient, log io.Writer) (*DebugClient, error) {
-	return &DebugClient{
+func New(impl HttpClient, log io.Writer, opts ...Option) (*DebugClient, error) {
+	c := &DebugClient{
 		impl: impl,
 		log:  log,
-	}, nil
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c, nil
 }
 
 func (c *DebugClient) Do(req *http.Request) (*http.Response, error) {
 	n := atomic.AddUint64(&c.n, 1)
 
-	curl, err := http2curl.GetCurlCommand(req)
-	if err != nil {
-		return nil, fmt.Errorf("http2curl.GetCurlCommand failed for %d: %w", n, err)
+	teeRequest := c.teeStreams && req.Body != nil && req.Body != http.NoBody && req.GetBody == nil
+	var reqBody []byte
+	if !teeRequest {
+		var err error
+		reqBody, err = c.readRequestBody(req)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read request body for %d: %w", n, err)
+		}
+		if c.format == Text {
+			if err := c.logTextRequest(n, req, reqBody, int64(len(reqBody))); err != nil {
+				return nil, err
+			}
+		}
 	}
-	if _, err = fmt.Fprintf(c.log, "=== client request %d ===\n$ %s\n=== end of client request %d ===\n", n, curl, n); err != nil {
-		return nil, fmt.Errorf("fmt.Fprintf(request) failed for %d: %w", n, err)
+
+	entry := c.newEntry(n, req, reqBody)
+	trace := &timingTrace{}
+	origReq := req
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace.clientTrace()))
+	trace.start = time.Now()
+
+	if teeRequest {
+		// The request is logged when the transport has sent or closed
+		// the body.
+		req.Body = &teeBody{
+			impl:  req.Body,
+			limit: c.bodyLimit(),
+			done: func(body []byte, size int64) {
+				if c.format == Text {
+					if err := c.logTextRequest(n, origReq, body, size); err != nil {
+						// The request is already being sent.
+						fmt.Fprintf(c.log, "failed to log request %d: %v\n", n, err)
+					}
+					return
+				}
+				c.mu.Lock()
+				entry.Request.Body = c.newEntryBody(body, size)
+				c.mu.Unlock()
+			},
+		}
 	}
 
 	res, err := c.impl.Do(req)
 	if err != nil {
+		if c.format != Text {
+			entry.Error = err.Error()
+			entry.Timings = trace.timings(time.Now())
+			if err := c.writeEntry(entry); err != nil {
+				return nil, fmt.Errorf("failed to log request %d: %w", n, err)
+			}
+		}
 		return nil, err
 	}
 
-	resDump, err := httputil.DumpResponse(res, true)
+	if c.teeStreams {
+		res.Body = &teeBody{
+			impl:  res.Body,
+			limit: c.bodyLimit(),
+			done: func(body []byte, size int64) {
+				if err := c.logResponse(n, entry, trace, res, body, size); err != nil {
+					// The response is already returned.
+					fmt.Fprintf(c.log, "failed to log response %d: %v\n", n, err)
+				}
+			},
+		}
+		return res, nil
+	}
+
+	body, err := io.ReadAll(res.Body)
 	if err != nil {
-		return nil, fmt.Errorf("httputil.DumpResponse failed for %d: %w", n, err)
+		return nil, fmt.Errorf("failed to read response body for %d: %w", n, err)
 	}
-	if _, err = fmt.Fprintf(c.log, "=== server response %d ===\n%s\n=== end of server response %d ===\n", n, string(resDump), n); err != nil {
-		return nil, fmt.Errorf("fmt.Fprintf(response) failed for %d: %w", n, err)
+	if err := res.Body.Close(); err != nil {
+		return nil, fmt.Errorf("failed to close response body for %d: %w", n, err)
 	}
+	res.Body = io.NopCloser(bytes.NewReader(body))
 
+	if err := c.logResponse(n, entry, trace, res, body, int64(len(body))); err != nil {
+		return nil, err
+	}
 	return res, nil
 }
 
+// readRequestBody returns a copy of the request body. Streaming bodies
+// (without GetBody) are replaced with a buffer. With TeeStreams they are
+// not read here, see Do.
+func (c *DebugClient) readRequestBody(req *http.Request) ([]byte, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return nil, nil
+	}
+	if req.GetBody != nil {
+		body, err := req.GetBody()
+		if err != nil {
+			return nil, err
+		}
+		defer body.Close()
+		return io.ReadAll(body)
+	}
+	data, err := io.ReadAll(req.Body)
+	if err != nil {
+		return nil, err
+	}
+	if err := req.Body.Close(); err != nil {
+		return nil, err
+	}
+	req.Body = io.NopCloser(bytes.NewReader(data))
+	return data, nil
+}
+
+// logTextRequest logs the request as a curl command. size is the full size
+// of the body, body may be a prefix.
+func (c *DebugClient) logTextRequest(n uint64, req *http.Request, body []byte, size int64) error {
+	// Log a copy with redacted headers and body.
+	req2 := req.Clone(req.Context())
+	req2.Header = c.redactHeader(req.Header)
+	shown, note := c.showBody(body, size)
+	req2.Body = io.NopCloser(bytes.NewReader(shown))
+	req2.ContentLength = int64(len(shown))
+
+	curl, err := http2curl.GetCurlCommand(req2)
+	if err != nil {
+		return fmt.Errorf("http2curl.GetCurlCommand failed for %d: %w", n, err)
+	}
+	if _, err = fmt.Fprintf(c.log, "=== client request %d ===\n$ %s%s\n=== end of client request %d ===\n", n, curl, note, n); err != nil {
+		return fmt.Errorf("fmt.Fprintf(request) failed for %d: %w", n, err)
+	}
+	return nil
+}
+
+func (c *DebugClient) logResponse(n uint64, entry *Entry, trace *timingTrace, res *http.Response, body []byte, size int64) error {
+	if c.format == Text {
+		return c.logTextResponse(n, res, body, size)
+	}
+	entry.Response = c.newEntryResponse(res, body, size)
+	entry.Timings = trace.timings(time.Now())
+	if err := c.writeEntry(entry); err != nil {
+		return fmt.Errorf("failed to log response %d: %w", n, err)
+	}
+	return nil
+}
+
+func (c *DebugClient) logTextResponse(n uint64, res *http.Response, body []byte, size int64) error {
+	shown, note := c.showBody(body, size)
+	res2 := *res
+	res2.Header = c.redactHeader(res.Header)
+	var resDump []byte
+	var err error
+	if note == "" && bytes.Equal(shown, body) {
+		res2.Body = io.NopCloser(bytes.NewReader(body))
+		resDump, err = httputil.DumpResponse(&res2, true)
+	} else {
+		res2.Body = http.NoBody
+		resDump, err = httputil.DumpResponse(&res2, false)
+		resDump = append(resDump, shown...)
+		resDump = append(resDump, note...)
+	}
+	if err != nil {
+		return fmt.Errorf("httputil.DumpResponse failed for %d: %w", n, err)
+	}
+	if _, err = fmt.Fprintf(c.log, "=== server response %d ===\n%s\n=== end of server response %d ===\n", n, string(resDump), n); err != nil {
+		return fmt.Errorf("fmt.Fprintf(response) failed for %d: %w", n, err)
+	}
+	return nil
+}
+
+func (c *DebugClient) writeEntry(entry *Entry) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.format == HAR {
+		c.entries = append(c.entries, entry)
+		return nil
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	_, err = c.log.Write(append(data, '\n'))
+	return err
+}
+
+// Flush writes the collected requests in HAR format. The following requests
+// are collected anew. It does nothing in other formats.
+func (c *DebugClient) Flush() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.format != HAR {
+		return nil
+	}
+	data, err := json.MarshalIndent(newHAR(c.entries), "", "  ")
+	if err != nil {
+		return err
+	}
+	c.entries = nil
+	_, err = c.log.Write(append(data, '\n'))
+	return err
+}
+
 func (c *DebugClient) CloseIdleConnections() {
 	c.impl.CloseIdleConnections()
 }
+
+// Close flushes the log and closes the underlying client if it is io.Closer.
+func (c *DebugClient) Close() error {
+	if err := c.Flush(); err != nil {
+		return err
+	}
+	if closer, ok := c.impl.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// teeBody copies the body to a buffer (up to the limit) as it is read and
+// calls done once when the body ends or is closed.
+type teeBody struct {
+	impl  io.ReadCloser
+	limit int
+	done  func(body []byte, size int64)
+
+	buf      bytes.Buffer
+	size     int64
+	doneOnce sync.Once
+}
+
+func (t *teeBody) Read(p []byte) (int, error) {
+	n, err := t.impl.Read(p)
+	if n > 0 {
+		t.size += int64(n)
+		if room := t.limit - t.buf.Len(); room > 0 {
+			t.buf.Write(p[:min(n, room)])
+		}
+	}
+	if err != nil {
+		t.finish()
+	}
+	return n, err
+}
+
+func (t *teeBody) Close() error {
+	err := t.impl.Close()
+	t.finish()
+	return err
+}
+
+func (t *teeBody) finish() {
+	t.doneOnce.Do(func() {
+		t.done(t.buf.Bytes(), t.size)
+	})
+}
+
+// bodyLimit returns the number of bytes of bodies to keep. One byte more
+// than MaxBodySize is kept to detect truncation.
+func (c *DebugClient) bodyLimit() int {
+	if c.maxBodySize > 0 {
+		return c.maxBodySize + 1
+	}
+	return int(^uint(0) >> 1)
+}
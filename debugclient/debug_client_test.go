@@ -3,9 +3,13 @@ package debugclient
 import (
 	"bytes"
 	"context"
+	"encoding/json"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"regexp"
+	"strings"
+	"sync"
 	"testing"
 
 	"github.com/starius/api2"
@@ -62,3 +66,245 @@ func TestDebugClient(t *testing.T) {
 	require.Equal(t, wantLog, gotLog)
 
 }
+
+func TestDebugClientJSONLines(t *testing.T) {
+	type LoginRequest struct {
+		User     string `json:"user"`
+		Password string `json:"password"`
+	}
+	type LoginResponse struct {
+		Token   string `json:"token"`
+		Padding string `json:"padding"`
+	}
+
+	loginHandler := func(ctx context.Context, req *LoginRequest) (*LoginResponse, error) {
+		return &LoginResponse{Token: "tok-" + req.User, Padding: strings.Repeat("x", 100)}, nil
+	}
+	routes := []api2.Route{
+		{Method: http.MethodPost, Path: "/login", Handler: loginHandler},
+	}
+	mux := http.NewServeMux()
+	api2.BindRoutes(mux, routes)
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	var log bytes.Buffer
+	debugClient, err := New(http.DefaultClient, &log,
+		WithFormat(JSONLines),
+		RedactHeaders("Authorization"),
+		RedactJSONFields("password", "token"),
+		MaxBodySize(40),
+	)
+	require.NoError(t, err)
+	client := api2.NewClient(routes, server.URL, api2.CustomClient(debugClient), api2.AuthorizationHeader("Bearer secret"))
+
+	res := &LoginResponse{}
+	require.NoError(t, client.Call(context.Background(), res, &LoginRequest{User: "alice", Password: "pass"}))
+	require.Equal(t, "tok-alice", res.Token)
+
+	gotLog := log.String()
+	require.NotContains(t, gotLog, "secret")
+	require.NotContains(t, gotLog, "pass\"")
+	require.NotContains(t, gotLog, "tok-alice")
+
+	lines := strings.Split(strings.TrimSpace(gotLog), "\n")
+	require.Len(t, lines, 1)
+	var entry Entry
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &entry))
+	require.Equal(t, uint64(1), entry.N)
+	require.Equal(t, http.MethodPost, entry.Request.Method)
+	require.Equal(t, []string{Redacted}, entry.Request.Header["Authorization"])
+	require.Equal(t, `{"password":"REDACTED","user":"alice"}`+"\n", entry.Request.Body.Text)
+	require.False(t, entry.Request.Body.Truncated)
+	require.Equal(t, http.StatusOK, entry.Response.Status)
+	require.True(t, entry.Response.Body.Truncated)
+	require.Equal(t, `{"padding":"`+strings.Repeat("x", 28), entry.Response.Body.Text)
+	require.Equal(t, int64(len(`{"token":"tok-alice","padding":""}`)+100+1), entry.Response.Body.Size)
+	require.GreaterOrEqual(t, entry.Timings.Connect, 0.0)
+	require.GreaterOrEqual(t, entry.Timings.TTFB, 0.0)
+	require.Equal(t, -1.0, entry.Timings.TLS)
+	require.GreaterOrEqual(t, entry.Timings.Total, entry.Timings.TTFB)
+}
+
+func TestDebugClientHAR(t *testing.T) {
+	type EchoRequest struct {
+		Text string `query:"text"`
+	}
+	type EchoResponse struct {
+		Text string `json:"text"`
+	}
+	echoHandler := func(ctx context.Context, req *EchoRequest) (*EchoResponse, error) {
+		return &EchoResponse{Text: req.Text}, nil
+	}
+	routes := []api2.Route{
+		{Method: http.MethodGet, Path: "/echo", Handler: echoHandler},
+	}
+	mux := http.NewServeMux()
+	api2.BindRoutes(mux, routes)
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	var log bytes.Buffer
+	debugClient, err := New(http.DefaultClient, &log, WithFormat(HAR))
+	require.NoError(t, err)
+	client := api2.NewClient(routes, server.URL, api2.CustomClient(debugClient))
+
+	ctx := context.Background()
+	for _, text := range []string{"a", "b"} {
+		require.NoError(t, client.Call(ctx, &EchoResponse{}, &EchoRequest{Text: text}))
+	}
+	require.Empty(t, log.String())
+	require.NoError(t, client.Close())
+
+	var har struct {
+		Log struct {
+			Version string `json:"version"`
+			Entries []struct {
+				Request struct {
+					Method      string `json:"method"`
+					QueryString []struct {
+						Name  string `json:"name"`
+						Value string `json:"value"`
+					} `json:"queryString"`
+				} `json:"request"`
+				Response struct {
+					Status  int `json:"status"`
+					Content struct {
+						Text     string `json:"text"`
+						MimeType string `json:"mimeType"`
+					} `json:"content"`
+				} `json:"response"`
+			} `json:"entries"`
+		} `json:"log"`
+	}
+	require.NoError(t, json.Unmarshal(log.Bytes(), &har))
+	require.Equal(t, "1.2", har.Log.Version)
+	require.Len(t, har.Log.Entries, 2)
+	e := har.Log.Entries[1]
+	require.Equal(t, http.MethodGet, e.Request.Method)
+	require.Equal(t, "text", e.Request.QueryString[0].Name)
+	require.Equal(t, "b", e.Request.QueryString[0].Value)
+	require.Equal(t, http.StatusOK, e.Response.Status)
+	require.Equal(t, `{"text":"b"}`+"\n", e.Response.Content.Text)
+	require.Contains(t, e.Response.Content.MimeType, "application/json")
+}
+
+func TestDebugClientTeeStreams(t *testing.T) {
+	type StreamRequest struct {
+	}
+	type StreamResponse struct {
+		Body io.ReadCloser `use_as_body:"true" is_stream:"true"`
+	}
+
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		_, _ = io.WriteString(w, `{"secret":"s1","n":1}`+"\n")
+		w.(http.Flusher).Flush()
+		<-release
+		_, _ = io.WriteString(w, `{"secret":"s2","n":2}`+"\n")
+	}))
+	t.Cleanup(server.Close)
+
+	routes := []api2.Route{
+		{Method: http.MethodGet, Path: "/stream", Handler: func(ctx context.Context, req *StreamRequest) (*StreamResponse, error) {
+			return nil, nil
+		}},
+	}
+
+	var log bytes.Buffer
+	debugClient, err := New(http.DefaultClient, &log, TeeStreams(true), RedactJSONFields("secret"))
+	require.NoError(t, err)
+	client := api2.NewClient(routes, server.URL, api2.CustomClient(debugClient))
+
+	// The response is returned before the stream ends.
+	res := &StreamResponse{}
+	require.NoError(t, client.Call(context.Background(), res, &StreamRequest{}))
+	line := make([]byte, len(`{"secret":"s1","n":1}`+"\n"))
+	_, err = io.ReadFull(res.Body, line)
+	require.NoError(t, err)
+	require.Equal(t, `{"secret":"s1","n":1}`+"\n", string(line))
+	require.NotContains(t, log.String(), "server response")
+
+	close(release)
+	rest, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+	require.Equal(t, `{"secret":"s2","n":2}`+"\n", string(rest))
+	require.NoError(t, res.Body.Close())
+
+	gotLog := log.String()
+	require.Contains(t, gotLog, "=== server response 1 ===")
+	require.Contains(t, gotLog, `{"secret":"REDACTED","n":1}`+"\n"+`{"secret":"REDACTED","n":2}`)
+	require.NotContains(t, gotLog, "s1")
+	require.Equal(t, 1, strings.Count(gotLog, "=== end of server response 1 ==="))
+}
+
+// syncBuffer is bytes.Buffer safe for concurrent use.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestDebugClientTeeRequestStream(t *testing.T) {
+	received := make(chan string)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		line := make([]byte, len("chunk1\n"))
+		if _, err := io.ReadFull(r.Body, line); err != nil {
+			t.Errorf("failed to read the first chunk: %v", err)
+			return
+		}
+		received <- string(line)
+		rest, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Errorf("failed to read the rest: %v", err)
+			return
+		}
+		received <- string(rest)
+	}))
+	t.Cleanup(server.Close)
+
+	var log syncBuffer
+	debugClient, err := New(http.DefaultClient, &log, TeeStreams(true))
+	require.NoError(t, err)
+
+	pr, pw := io.Pipe()
+	req, err := http.NewRequest(http.MethodPost, server.URL, pr)
+	require.NoError(t, err)
+	resCh := make(chan error, 1)
+	go func() {
+		res, err := debugClient.Do(req)
+		if err == nil {
+			err = res.Body.Close()
+		}
+		resCh <- err
+	}()
+
+	// The server gets the body before the client finishes writing it.
+	_, err = io.WriteString(pw, "chunk1\n")
+	require.NoError(t, err)
+	require.Equal(t, "chunk1\n", <-received)
+	require.NotContains(t, log.String(), "client request")
+
+	_, err = io.WriteString(pw, "chunk2\n")
+	require.NoError(t, err)
+	require.NoError(t, pw.Close())
+	require.Equal(t, "chunk2\n", <-received)
+	require.NoError(t, <-resCh)
+
+	gotLog := log.String()
+	require.Contains(t, gotLog, "=== client request 1 ===")
+	require.Contains(t, gotLog, "-d 'chunk1\nchunk2\n'")
+	require.Contains(t, gotLog, "=== server response 1 ===")
+}
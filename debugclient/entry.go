@@ -0,0 +1,283 @@
+package debugclient
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptrace"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// Redacted replaces secret values in the log.
+const Redacted = "REDACTED"
+
+// Entry is a request and its response logged in JSONLines format.
+type Entry struct {
+	N        uint64         `json:"n"`
+	Started  time.Time      `json:"started"`
+	Request  EntryRequest   `json:"request"`
+	Response *EntryResponse `json:"response,omitempty"`
+	Error    string         `json:"error,omitempty"`
+	Timings  Timings        `json:"timings"`
+}
+
+type EntryRequest struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Proto  string      `json:"proto"`
+	Header http.Header `json:"header,omitempty"`
+	Body   EntryBody   `json:"body"`
+}
+
+type EntryResponse struct {
+	Status int         `json:"status"`
+	Proto  string      `json:"proto"`
+	Header http.Header `json:"header,omitempty"`
+	Body   EntryBody   `json:"body"`
+}
+
+// EntryBody is a body, possibly redacted and truncated.
+type EntryBody struct {
+	Text      string `json:"text,omitempty"`
+	Size      int64  `json:"size"`
+	Truncated bool   `json:"truncated,omitempty"`
+
+	// Binary is true if the body is not valid UTF-8. Text is omitted then.
+	Binary bool `json:"binary,omitempty"`
+}
+
+// Timings are durations in milliseconds of the phases of the request,
+// -1 if the phase did not happen (e.g. a connection was reused).
+type Timings struct {
+	DNS     float64 `json:"dns"`
+	Connect float64 `json:"connect"`
+	TLS     float64 `json:"tls"`
+
+	// Send is from getting a connection to writing the request.
+	Send float64 `json:"send"`
+
+	// Wait is from writing the request to the first byte of the response.
+	Wait float64 `json:"wait"`
+
+	// TTFB is from the start to the first byte of the response.
+	TTFB float64 `json:"ttfb"`
+
+	// Receive is from the first byte to the end of the response.
+	Receive float64 `json:"receive"`
+
+	Total float64 `json:"total"`
+}
+
+func (c *DebugClient) newEntry(n uint64, req *http.Request, body []byte) *Entry {
+	return &Entry{
+		N:       n,
+		Started: time.Now(),
+		Request: EntryRequest{
+			Method: req.Method,
+			URL:    req.URL.String(),
+			Proto:  req.Proto,
+			Header: c.redactHeader(req.Header),
+			Body:   c.newEntryBody(body, int64(len(body))),
+		},
+	}
+}
+
+func (c *DebugClient) newEntryResponse(res *http.Response, body []byte, size int64) *EntryResponse {
+	return &EntryResponse{
+		Status: res.StatusCode,
+		Proto:  res.Proto,
+		Header: c.redactHeader(res.Header),
+		Body:   c.newEntryBody(body, size),
+	}
+}
+
+func (c *DebugClient) newEntryBody(body []byte, size int64) EntryBody {
+	shown, truncated := c.redactBody(body, size)
+	if !utf8.Valid(shown) {
+		return EntryBody{Size: size, Truncated: truncated, Binary: true}
+	}
+	return EntryBody{Text: string(shown), Size: size, Truncated: truncated}
+}
+
+func (c *DebugClient) redactHeader(header http.Header) http.Header {
+	if len(c.redactHeaders) == 0 {
+		return header
+	}
+	header = header.Clone()
+	for _, name := range c.redactHeaders {
+		if values, has := header[http.CanonicalHeaderKey(name)]; has {
+			for i := range values {
+				values[i] = Redacted
+			}
+		}
+	}
+	return header
+}
+
+// redactBody returns the redacted body, truncated to MaxBodySize.
+// size is the full size of the body, body may be a prefix of it.
+func (c *DebugClient) redactBody(body []byte, size int64) ([]byte, bool) {
+	complete := int64(len(body)) == size
+	if len(c.redactJSONFields) != 0 && len(body) != 0 {
+		body = c.redactJSON(body, complete)
+	}
+	if c.maxBodySize > 0 && (size > int64(c.maxBodySize) || len(body) > c.maxBodySize) {
+		return body[:min(len(body), c.maxBodySize)], true
+	}
+	return body, false
+}
+
+// showBody returns the body for the text format and the note about
+// truncation.
+func (c *DebugClient) showBody(body []byte, size int64) ([]byte, string) {
+	shown, truncated := c.redactBody(body, size)
+	if truncated {
+		return shown, fmt.Sprintf("\n... (truncated, %d bytes total)", size)
+	}
+	return shown, ""
+}
+
+// redactJSON replaces values of the fields. If the body is complete JSON,
+// it is decoded and encoded again. Otherwise (e.g. a prefix of a stream or
+// JSON lines) scalar values are replaced textually.
+func (c *DebugClient) redactJSON(body []byte, complete bool) []byte {
+	if complete {
+		var value interface{}
+		decoder := json.NewDecoder(bytes.NewReader(body))
+		decoder.UseNumber()
+		if err := decoder.Decode(&value); err == nil && !decoder.More() {
+			if redacted, err := json.Marshal(c.redactValue(value)); err == nil {
+				if bytes.HasSuffix(body, []byte("\n")) {
+					redacted = append(redacted, '\n')
+				}
+				return redacted
+			}
+		}
+	}
+	return c.fieldsRegexp().ReplaceAll(body, []byte(`"$1":"`+Redacted+`"`))
+}
+
+func (c *DebugClient) redactValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, field := range v {
+			if c.isRedactedField(key) {
+				v[key] = Redacted
+			} else {
+				v[key] = c.redactValue(field)
+			}
+		}
+	case []interface{}:
+		for i := range v {
+			v[i] = c.redactValue(v[i])
+		}
+	}
+	return value
+}
+
+func (c *DebugClient) isRedactedField(name string) bool {
+	for _, field := range c.redactJSONFields {
+		if field == name {
+			return true
+		}
+	}
+	return false
+}
+
+var fieldsRegexps sync.Map
+
+func (c *DebugClient) fieldsRegexp() *regexp.Regexp {
+	key := strings.Join(c.redactJSONFields, "\x00")
+	if re, has := fieldsRegexps.Load(key); has {
+		return re.(*regexp.Regexp)
+	}
+	quoted := make([]string, len(c.redactJSONFields))
+	for i, field := range c.redactJSONFields {
+		quoted[i] = regexp.QuoteMeta(field)
+	}
+	// A field followed by a string (possibly cut) or another scalar.
+	re := regexp.MustCompile(`"(` + strings.Join(quoted, "|") + `)"\s*:\s*(?:"(?:[^"\\]|\\.)*"?|[^,{}\[\]\s]+)`)
+	fieldsRegexps.Store(key, re)
+	return re
+}
+
+// timingTrace records times of events of httptrace.
+type timingTrace struct {
+	mu sync.Mutex
+
+	start                    time.Time
+	dnsStart, dnsDone        time.Time
+	connectStart, connectEnd time.Time
+	tlsStart, tlsDone        time.Time
+	gotConn                  time.Time
+	wroteRequest             time.Time
+	firstByte                time.Time
+}
+
+func (t *timingTrace) set(field *time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if field.IsZero() {
+		*field = time.Now()
+	}
+}
+
+func (t *timingTrace) clientTrace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) {
+			t.set(&t.dnsStart)
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			t.set(&t.dnsDone)
+		},
+		ConnectStart: func(network, addr string) {
+			t.set(&t.connectStart)
+		},
+		ConnectDone: func(network, addr string, err error) {
+			t.set(&t.connectEnd)
+		},
+		TLSHandshakeStart: func() {
+			t.set(&t.tlsStart)
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			t.set(&t.tlsDone)
+		},
+		GotConn: func(httptrace.GotConnInfo) {
+			t.set(&t.gotConn)
+		},
+		WroteRequest: func(httptrace.WroteRequestInfo) {
+			t.set(&t.wroteRequest)
+		},
+		GotFirstResponseByte: func() {
+			t.set(&t.firstByte)
+		},
+	}
+}
+
+func (t *timingTrace) timings(end time.Time) Timings {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return Timings{
+		DNS:     millis(t.dnsStart, t.dnsDone),
+		Connect: millis(t.connectStart, t.connectEnd),
+		TLS:     millis(t.tlsStart, t.tlsDone),
+		Send:    millis(t.gotConn, t.wroteRequest),
+		Wait:    millis(t.wroteRequest, t.firstByte),
+		TTFB:    millis(t.start, t.firstByte),
+		Receive: millis(t.firstByte, end),
+		Total:   millis(t.start, end),
+	}
+}
+
+func millis(from, to time.Time) float64 {
+	if from.IsZero() || to.IsZero() {
+		return -1
+	}
+	return float64(to.Sub(from).Microseconds()) / 1000
+}
@@ -0,0 +1,188 @@
+package debugclient
+
+import (
+	"net/http"
+	"net/url"
+	"sort"
+	"time"
+)
+
+// Types of HTTP Archive 1.2, see http://www.softwareishard.com/blog/har-12-spec/
+
+type harFile struct {
+	Log harLog `json:"log"`
+}
+
+type harLog struct {
+	Version string     `json:"version"`
+	Creator harCreator `json:"creator"`
+	Entries []harEntry `json:"entries"`
+}
+
+type harCreator struct {
+	Name    string `json:"name"`
+	Version string `json:"version"`
+}
+
+type harEntry struct {
+	StartedDateTime string      `json:"startedDateTime"`
+	Time            float64     `json:"time"`
+	Request         harRequest  `json:"request"`
+	Response        harResponse `json:"response"`
+	Cache           struct{}    `json:"cache"`
+	Timings         harTimings  `json:"timings"`
+	Comment         string      `json:"comment,omitempty"`
+}
+
+type harRequest struct {
+	Method      string         `json:"method"`
+	URL         string         `json:"url"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []harNameValue `json:"cookies"`
+	Headers     []harNameValue `json:"headers"`
+	QueryString []harNameValue `json:"queryString"`
+	PostData    *harPostData   `json:"postData,omitempty"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int64          `json:"bodySize"`
+}
+
+type harResponse struct {
+	Status      int            `json:"status"`
+	StatusText  string         `json:"statusText"`
+	HTTPVersion string         `json:"httpVersion"`
+	Cookies     []harNameValue `json:"cookies"`
+	Headers     []harNameValue `json:"headers"`
+	Content     harContent     `json:"content"`
+	RedirectURL string         `json:"redirectURL"`
+	HeadersSize int            `json:"headersSize"`
+	BodySize    int64          `json:"bodySize"`
+}
+
+type harNameValue struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+type harPostData struct {
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text"`
+}
+
+type harContent struct {
+	Size     int64  `json:"size"`
+	MimeType string `json:"mimeType"`
+	Text     string `json:"text,omitempty"`
+	Comment  string `json:"comment,omitempty"`
+}
+
+type harTimings struct {
+	Blocked float64 `json:"blocked"`
+	DNS     float64 `json:"dns"`
+	Connect float64 `json:"connect"`
+	Send    float64 `json:"send"`
+	Wait    float64 `json:"wait"`
+	Receive float64 `json:"receive"`
+	SSL     float64 `json:"ssl"`
+}
+
+func newHAR(entries []*Entry) harFile {
+	har := harFile{
+		Log: harLog{
+			Version: "1.2",
+			Creator: harCreator{Name: "api2 debugclient", Version: "1.0"},
+			Entries: make([]harEntry, 0, len(entries)),
+		},
+	}
+	for _, e := range entries {
+		har.Log.Entries = append(har.Log.Entries, newHAREntry(e))
+	}
+	return har
+}
+
+func newHAREntry(e *Entry) harEntry {
+	entry := harEntry{
+		StartedDateTime: e.Started.Format(time.RFC3339Nano),
+		Time:            max(e.Timings.Total, 0),
+		Request: harRequest{
+			Method:      e.Request.Method,
+			URL:         e.Request.URL,
+			HTTPVersion: e.Request.Proto,
+			Cookies:     []harNameValue{},
+			Headers:     harHeaders(e.Request.Header),
+			QueryString: harQuery(e.Request.URL),
+			HeadersSize: -1,
+			BodySize:    e.Request.Body.Size,
+		},
+		Response: harResponse{
+			Cookies:     []harNameValue{},
+			Headers:     []harNameValue{},
+			HeadersSize: -1,
+			BodySize:    -1,
+		},
+		Timings: harTimings{
+			Blocked: -1,
+			DNS:     e.Timings.DNS,
+			Connect: e.Timings.Connect,
+			Send:    max(e.Timings.Send, 0),
+			Wait:    max(e.Timings.Wait, 0),
+			Receive: max(e.Timings.Receive, 0),
+			SSL:     e.Timings.TLS,
+		},
+		Comment: e.Error,
+	}
+	if e.Request.Body.Size != 0 {
+		entry.Request.PostData = &harPostData{
+			MimeType: e.Request.Header.Get("Content-Type"),
+			Text:     e.Request.Body.Text,
+		}
+	}
+	if r := e.Response; r != nil {
+		entry.Response.Status = r.Status
+		entry.Response.StatusText = http.StatusText(r.Status)
+		entry.Response.HTTPVersion = r.Proto
+		entry.Response.Headers = harHeaders(r.Header)
+		entry.Response.BodySize = r.Body.Size
+		entry.Response.Content = harContent{
+			Size:     r.Body.Size,
+			MimeType: r.Header.Get("Content-Type"),
+			Text:     r.Body.Text,
+		}
+		if r.Body.Truncated {
+			entry.Response.Content.Comment = "truncated"
+		}
+	}
+	return entry
+}
+
+func harHeaders(header http.Header) []harNameValue {
+	list := []harNameValue{}
+	for name, values := range header {
+		for _, value := range values {
+			list = append(list, harNameValue{Name: name, Value: value})
+		}
+	}
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].Name < list[j].Name
+	})
+	return list
+}
+
+func harQuery(rawURL string) []harNameValue {
+	list := []harNameValue{}
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return list
+	}
+	query := u.Query()
+	names := make([]string, 0, len(query))
+	for name := range query {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, value := range query[name] {
+			list = append(list, harNameValue{Name: name, Value: value})
+		}
+	}
+	return list
+}
@@ -0,0 +1,224 @@
+package api2
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"sync"
+)
+
+// DirectMode selects how a client created by NewDirectClient calls handlers.
+type DirectMode int
+
+const (
+	// DirectWire passes calls through the same encoding and decoding as
+	// remote calls: requests are encoded by Transport, handled by the handler
+	// installed by BindRoutes and responses are decoded from its output.
+	// Only the network is skipped. Use it in tests to cover everything the
+	// client and the server do.
+	DirectWire DirectMode = iota
+
+	// DirectFast passes request and response structs to handlers directly.
+	// Transport is not used, so the handler receives the object passed to
+	// Call and its errors are returned as is. Middleware of the routes and
+	// option AddMiddleware are applied, interceptors too; the handler of the
+	// route passed by the interceptor is called. CallTimeout limits the
+	// context of the handler, CallHuman has no effect. CallHeader and
+	// CallAuthorization need an HTTP request, so calls with them fail.
+	// Use it in monoliths hosting several services in one process.
+	DirectFast
+)
+
+// directRoute is a handler called by a client in DirectFast mode.
+type directRoute struct {
+	start      Handler
+	middleware Middleware
+}
+
+// NewDirectClient creates a client calling handlers of the routes in the
+// same process, without network. The calling code is the same as for
+// a client created by NewClient. Options are applied to the client and, in
+// DirectWire mode, to BindRoutes.
+func NewDirectClient(routes []Route, mode DirectMode, opts ...Option) *Client {
+	switch mode {
+	case DirectWire:
+		mux := http.NewServeMux()
+		BindRoutes(mux, routes, opts...)
+		opts = append(opts[:len(opts):len(opts)], CustomClient(NewHandlerClient(mux)))
+		return NewClient(routes, "http://in-process", opts...)
+
+	case DirectFast:
+		config := NewDefaultConfig()
+		for _, opt := range opts {
+			opt(config)
+		}
+		c := NewClient(routes, "", opts...)
+		c.direct = make(map[string]directRoute, len(routes))
+		for _, route := range routes {
+			_, start := routeCaller(route)
+			c.direct[route.Method+" "+route.Path] = directRoute{
+				start:      start,
+				middleware: chainMiddleware(config.middleware, route.Middleware),
+			}
+		}
+		return c
+	}
+	panic(fmt.Sprintf("unknown DirectMode %d", mode))
+}
+
+func (c *Client) callDirect(ctx context.Context, route Route, response, request interface{}) error {
+	d, has := c.direct[route.Method+" "+route.Path]
+	if !has {
+		return fmt.Errorf("route %s %s is not served by the direct client", route.Method, route.Path)
+	}
+	if o := getCallOptions(ctx); o != nil {
+		if o.header != nil {
+			return fmt.Errorf("CallHeader is not supported by DirectFast client")
+		}
+		if o.authorization != nil {
+			return fmt.Errorf("CallAuthorization is not supported by DirectFast client")
+		}
+		if o.timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, o.timeout)
+			defer cancel()
+		}
+	}
+
+	var res, errReflect any
+	if d.middleware != nil {
+		res, errReflect = d.middleware(ctx, request, d.start)
+	} else {
+		res, errReflect = d.start(ctx, request)
+	}
+	if errReflect != nil {
+		return errReflect.(error)
+	}
+	if err := ctx.Err(); err != nil {
+		// The handler finished after the timeout.
+		return err
+	}
+
+	resValue := reflect.ValueOf(res)
+	if res == nil || resValue.IsNil() {
+		return fmt.Errorf("handler returned nil response")
+	}
+	reflect.ValueOf(response).Elem().Set(resValue.Elem())
+	return nil
+}
+
+// HandlerClient is HttpClient passing requests to http.Handler in the same
+// process. The response is returned as soon as the handler writes its
+// header, so streaming responses are passed while the handler writes them.
+// The handler runs in its own goroutine until it returns: if the context
+// of the request is canceled before the header is written, Do waits for the
+// handler to return; after Do returns a response, closing its body makes
+// further writes of the handler fail.
+type HandlerClient struct {
+	handler http.Handler
+}
+
+// NewHandlerClient returns HttpClient calling the handler. Pass it to
+// NewClient in option CustomClient.
+func NewHandlerClient(handler http.Handler) *HandlerClient {
+	return &HandlerClient{handler: handler}
+}
+
+func (c *HandlerClient) Do(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+
+	// Make the request look like a request received by http.Server.
+	r := req.Clone(ctx)
+	r.RequestURI = req.URL.RequestURI()
+	r.RemoteAddr = "in-process"
+	if r.Host == "" {
+		r.Host = req.URL.Host
+	}
+	if r.Body == nil {
+		r.Body = http.NoBody
+	}
+
+	pr, pw := io.Pipe()
+	w := &pipeResponseWriter{
+		header: make(http.Header),
+		pw:     pw,
+		res: &http.Response{
+			Proto:         "HTTP/1.1",
+			ProtoMajor:    1,
+			ProtoMinor:    1,
+			Body:          pr,
+			ContentLength: -1,
+			Request:       req,
+		},
+		headerSent: make(chan struct{}),
+	}
+
+	handlerErr := make(chan error, 1)
+	handlerDone := make(chan struct{})
+	go func() {
+		defer close(handlerDone)
+		defer r.Body.Close()
+		defer func() {
+			if p := recover(); p != nil {
+				err := fmt.Errorf("handler panicked: %v", p)
+				handlerErr <- err
+				pw.CloseWithError(err)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+			pw.Close()
+		}()
+		c.handler.ServeHTTP(w, r)
+	}()
+
+	select {
+	case <-w.headerSent:
+		return w.res, nil
+	case err := <-handlerErr:
+		return nil, err
+	case <-ctx.Done():
+		pr.CloseWithError(ctx.Err())
+		// The handler gets the canceled context through the request.
+		<-handlerDone
+		return nil, ctx.Err()
+	}
+}
+
+func (c *HandlerClient) CloseIdleConnections() {
+}
+
+// pipeResponseWriter passes the response written by a handler to the client
+// through a pipe.
+type pipeResponseWriter struct {
+	header http.Header
+	pw     *io.PipeWriter
+	res    *http.Response
+
+	headerOnce sync.Once
+	headerSent chan struct{}
+}
+
+func (w *pipeResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *pipeResponseWriter) WriteHeader(status int) {
+	w.headerOnce.Do(func() {
+		w.res.StatusCode = status
+		w.res.Status = fmt.Sprintf("%03d %s", status, http.StatusText(status))
+		w.res.Header = w.header.Clone()
+		close(w.headerSent)
+	})
+}
+
+func (w *pipeResponseWriter) Write(p []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	return w.pw.Write(p)
+}
+
+// Flush sends the header. The body is not buffered.
+func (w *pipeResponseWriter) Flush() {
+	w.WriteHeader(http.StatusOK)
+}
@@ -0,0 +1,72 @@
+package api2
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandlerClient(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/empty", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Test", "1")
+	})
+	mux.HandleFunc("/echo", func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/echo?a=b", r.RequestURI)
+		w.WriteHeader(http.StatusCreated)
+		_, _ = io.Copy(w, r.Body)
+	})
+	mux.HandleFunc("/panic", func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	})
+	handlerReturned := make(chan struct{})
+	mux.HandleFunc("/block", func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+		close(handlerReturned)
+	})
+	client := NewHandlerClient(mux)
+
+	res, err := client.Do(newTestRequest(t, context.Background(), "/empty", ""))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, res.StatusCode)
+	require.Equal(t, "1", res.Header.Get("X-Test"))
+	body, err := io.ReadAll(res.Body)
+	require.NoError(t, err)
+	require.Empty(t, body)
+
+	res, err = client.Do(newTestRequest(t, context.Background(), "/echo?a=b", "hello"))
+	require.NoError(t, err)
+	require.Equal(t, http.StatusCreated, res.StatusCode)
+	body, err = io.ReadAll(res.Body)
+	require.NoError(t, err)
+	require.Equal(t, "hello", string(body))
+
+	_, err = client.Do(newTestRequest(t, context.Background(), "/panic", ""))
+	require.ErrorContains(t, err, "handler panicked: boom")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = client.Do(newTestRequest(t, ctx, "/block", ""))
+	require.ErrorIs(t, err, context.Canceled)
+
+	// Do waits for the handler.
+	select {
+	case <-handlerReturned:
+	default:
+		t.Errorf("the handler is still running")
+	}
+}
+
+func newTestRequest(t *testing.T, ctx context.Context, path, body string) *http.Request {
+	var r io.Reader
+	if body != "" {
+		r = strings.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "http://in-process"+path, r)
+	require.NoError(t, err)
+	return req
+}
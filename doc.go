@@ -50,11 +50,25 @@ Let's define a service Foo with method Bar.
 	}
 
 A field must not have more than one of tags: json, query, header, cookie.
-Fields in query, header and cookie parts are encoded and decoded with
-fmt.Sprintf and fmt.Sscanf. Strings are not decoded with fmt.Sscanf,
-but passed as is. Types implementing encoding.TextMarshaler and
+Fields in query, header, cookie and url parts are encoded and decoded with
+value codecs registered with RegisterValueCodec, either globally in
+DefaultValueCodecs or per transport in JsonTransport.ValueCodecs.
+Built-in codecs encode time.Duration as "5s" and []byte as base64.
+RegisterTimeValueCodec sets the layout of time.Time values.
+Types without a codec implementing encoding.TextMarshaler and
 encoding.TextUnmarshaler are encoded and decoded using it.
+Strings are passed as is. Numbers and booleans are parsed with strconv,
+trailing garbage is rejected. Integers must be decimal: prefixes like 0x
+and underscores are not accepted. Other types are encoded and decoded with
+fmt.Sprintf and fmt.Sscanf.
 Cookie in Response part must be of type http.Cookie.
+Embedded structs (and pointers to structs) having query, header, cookie
+or url fields are flattened: their fields are handled as if they were
+fields of the outer struct. This is useful to share common fields like
+pagination parameters between requests. Nil embedded pointers are skipped
+when encoding and allocated when decoding if some of their fields is present.
+JSON fields with the same name follow the rules of encoding/json: the
+shallowest field wins and ambiguous fields are dropped.
 If no field is no JSON field in the struct, then HTTP body is skipped.
 
 You can also set HTTP status code of response by adding a field of type
@@ -109,9 +123,35 @@ Now let's write the function that generates the table of routes:
 You can add multiple routes with the same path, but in this case their
 HTTP methods must be different so that they can be distinguished.
 
+URL parameters can have constraints: /users/:id<int>, /users/:id<uint>,
+/users/:id<uuid> or /files/:name<re:[a-z0-9-]+> (the regular expression
+must match the whole path segment and must not contain "/", "?", "#" or "%").
+A route is selected only if the values satisfy the constraints, otherwise
+the server responds with 404. Routes differing only by constraints can
+coexist.
+
+The order of routes does not matter. In each path segment static segments
+take precedence over parameters with constraints, then over regular
+parameters and then over wildcard parameters. Paths of the same method
+differing only in names of parameters, e.g. /users/:id and /users/:name,
+can not be registered together, because only one of them could match.
+
 If Transport is not set, DefaultTransport is used which is defined as
 &api2.JsonTransport{}.
 
+Routes sharing a path prefix, Transport, Meta entries, middleware or body
+size limit can be put into api2.Group. Method Routes of the group returns
+a plain table of routes which can be used everywhere:
+
+	admin := &api2.Group{Prefix: "/v1/admin", Name: "admin", Transport: t}
+	routes := admin.Routes([]api2.Route{
+		{Method: http.MethodPost, Path: "/users", Handler: s.CreateUser},
+	})
+
+Names of groups are stored in Meta[api2.GroupsMetaKey] and are used as
+tags in generated OpenAPI spec and YAML. The TypeScript generator ignores
+groups, and Meta entries of groups are not emitted by any generator.
+
 **Error handling**. A handler can return any Go error. `JsonTransport`
 by default returns JSON. `Error()` value is put into "error" field of
 that JSON. If the error has `HttpCode() int` method, it is called and
@@ -135,6 +175,16 @@ Then just bind the routes to http.ServeMux and run the server:
 The server is running.
 It serves foo.Bar function on path /v1/foo/bar with HTTP method Post.
 
+By default BindRoutes registers static prefixes of the paths in the mux and
+dispatches requests using its own router. With option
+api2.NativePatterns(true) each route is registered as a Go 1.22+
+http.ServeMux pattern including the method, e.g. "POST /v1/foo/bar/{product}",
+and URL parameters are read using r.PathValue. In this mode api2 routes can
+coexist with other handlers registered under the same prefixes. Wildcard
+parameters are only allowed in the last segment of the path. Unknown paths and
+unsupported methods are answered with JSON errors by catch-all pattern "/"
+registered by BindRoutes, so the mux must not have another handler for "/".
+
 Now let's create the client:
 
 	// Client.
@@ -153,6 +203,289 @@ Now let's create the client:
 The client sent request to path "/v1/foo/bar/product1", from which
 the server understood that product=product1.
 
+client.Call panics if the client has no route with the types of request
+and response. Generic function api2.Call and typed endpoints return
+an error wrapping api2.ErrNoRoute instead:
+
+	barRes, err := api2.Call[BarRequest, BarResponse](ctx, client, &BarRequest{...})
+
+	// The route is looked up once.
+	bar, err := api2.NewEndpoint[BarRequest, BarResponse](client)
+	...
+	barRes, err := bar.Call(ctx, &BarRequest{...})
+
+The client can retry failed calls. Pass option api2.Retry with
+a policy to NewClient:
+
+	policy := api2.DefaultRetryPolicy()
+	policy.MaxAttempts = 5
+	client := api2.NewClient(routes, "http://127.0.0.1:8080", api2.Retry(policy))
+
+The policy sets retryable status codes (by default 429, 502, 503 and 504)
+and network errors, exponential backoff with jitter, the maximum number of
+attempts and the overall time budget, which is also limited by the deadline
+of ctx. Header Retry-After of the response is honored. Only routes with
+idempotent HTTP methods (GET, HEAD, OPTIONS, TRACE, PUT, DELETE) and routes
+with Idempotent: true are retried. Requests with streaming bodies
+(is_stream) are never retried.
+
+The client can also fail fast while the server is failing. Pass option
+api2.CircuitBreaker with a policy:
+
+	policy := api2.DefaultCircuitBreakerPolicy()
+	policy.OnStateChange = func(name string, from, to api2.CircuitState) {
+		log.Printf("circuit breaker %s: %s -> %s", name, from, to)
+	}
+	client := api2.NewClient(routes, "http://127.0.0.1:8080", api2.CircuitBreaker(policy))
+
+The client tracks a breaker per route and per host. A breaker opens when
+the ratio of failed calls (network errors and status codes 5xx and 429 by
+default) during the window reaches FailureRatio. While it is open, calls
+return *api2.CircuitOpenError (its HttpCode() is 503 and its code in
+package errors is Unavailable) without sending anything. After Cooldown a few trial calls are let through: the breaker
+closes if they succeed and opens again otherwise.
+
+Calls made by the client can be wrapped with interceptors. An interceptor
+gets the route and the typed request and response, so it can add
+authorization, log, collect metrics or change the request:
+
+	logger := func(ctx context.Context, route *api2.Route, req, res any, next api2.Invoker) error {
+		start := time.Now()
+		err := next(ctx, route, req, res)
+		log.Printf("%s %s took %s: %v", route.Method, route.Path, time.Since(start), err)
+		return err
+	}
+	client := api2.NewClient(routes, "http://127.0.0.1:8080", api2.AddInterceptor(logger))
+
+Interceptors are called in the order they were added. The generated static
+client passes its options to api2.NewClient, so interceptors work with it
+as well.
+
+Options of a single call are passed to Call after the request:
+
+	err := client.Call(ctx, res, req,
+		api2.CallTimeout(5*time.Second),
+		api2.CallHeader("X-Request-Id", requestID),
+		api2.CallAuthorization("Bearer "+token),
+		api2.CallHuman(true),
+	)
+
+api2.Call and Endpoint.Call accept them as well. Methods of the generated
+static client implement the service interface, so they take the options
+from the context: ctx = api2.WithCallOptions(ctx, opts...). Methods of
+client.WithOptions() accept them after the request:
+client.WithOptions().Echo(ctx, req, api2.CallTimeout(time.Second)). The
+timeout covers retries and, for streamed responses, reading the body.
+
+Short-lived credentials are provided by api2.CredentialProvider passed in
+option api2.Credentials. It is consulted on every call and should cache the
+value: api2.CacheCredentials does it given a function fetching the value
+and its expiry. Package clientcredentials implements OAuth 2.0 client
+credentials grant:
+
+	provider, err := clientcredentials.New(clientcredentials.Config{
+		TokenURL:     "https://auth.example.com/oauth2/token",
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		Scopes:       []string{"api"},
+	})
+	client := api2.NewClient(routes, "http://127.0.0.1:8080", api2.Credentials(provider))
+
+If the server responds with 401 Unauthorized, the client gets new
+credentials and repeats the call once. Credentials are set before the request
+reaches HttpClient, so they work with clients wrapped by debugclient and
+closingclient.
+
+Calls between services can be authenticated by HMAC-SHA256 signatures
+without a token server. The client signs the method, the path, the query,
+selected headers, a timestamp, a nonce and SHA-256 of the body; the server
+verifies the signature, the clock skew and that the nonce was not used
+before decoding the request:
+
+	client := api2.NewClient(routes, "http://127.0.0.1:8080", api2.SignRequests(&api2.RequestSigner{
+		KeyID: "2024-01",
+		Key:   key,
+	}))
+
+	verifier := api2.NewSignatureVerifier(map[string][]byte{
+		"2023-12": oldKey,
+		"2024-01": key,
+	})
+	api2.BindRoutes(mux, routes, api2.VerifySignatures(verifier))
+
+The verifier accepts all its keys, which allows rotating them. Bodies of
+streaming requests (is_stream) are not signed: the client sends
+"X-Api2-Content-Sha256: UNSIGNED-PAYLOAD" and the server accepts it only for
+routes with streaming requests. Headers listed in RequiredHeaders of the
+verifier must be signed by the client (see RequestSigner.Headers).
+
+The client can distribute calls across replicas of the service. Pass option
+api2.LoadBalance with the list of endpoints or a function resolving them;
+baseURL can be empty then:
+
+	client := api2.NewClient(routes, "", api2.LoadBalance(&api2.LoadBalancer{
+		Strategy:  api2.LeastOutstanding,
+		Endpoints: []string{"http://10.0.0.1:8080", "http://10.0.0.2:8080"},
+		HealthCheck: &api2.HealthCheck{
+			Path: "/health",
+		},
+	}))
+	defer client.Close()
+
+Strategies are api2.RoundRobin (default) and api2.LeastOutstanding.
+Endpoints failing several times in a row are ejected for a while, endpoints
+failing health checks are not used until they pass them. Requests of
+idempotent routes failing to connect to an endpoint are sent to another
+endpoint. With api2.CircuitBreaker, host breakers are kept per endpoint and
+endpoints whose breakers are open are skipped.
+
+Tail latency of idempotent routes can be reduced by hedging: if the response
+does not arrive within the delay, the client sends the request again (to
+another endpoint, if load balancing is used), uses the first response and
+cancels the other request:
+
+	client := api2.NewClient(routes, "", api2.LoadBalance(balancer), api2.Hedge(&api2.HedgePolicy{
+		Percentile: 0.95, // Or a fixed Delay.
+		Budget:     0.1,  // At most 10% extra requests.
+	}))
+
+Only routes marked Idempotent (e.g. with api2.RouteIdempotent()) are hedged.
+
+Package replayclient records traffic of a client to a cassette file and
+replays it, so tests can run without network:
+
+	config := replayclient.Config{
+		RedactHeaders:    []string{"Authorization"},
+		RedactJSONFields: []string{"password"},
+	}
+	recorder, err := replayclient.NewRecorder(http.DefaultClient, "testdata/cassette.json", config)
+	client := api2.NewClient(routes, serverURL, api2.CustomClient(recorder))
+	// ... make calls ...
+	client.Close() // Writes the cassette.
+
+	replayer, err := replayclient.NewReplayer("testdata/cassette.json", config)
+	client = api2.NewClient(routes, serverURL, api2.CustomClient(replayer))
+
+The replayer matches requests by method, path, query and body (configurable
+with Config.Match) and returns an error for requests not in the cassette.
+
+Package debugclient logs requests and responses of a client. By default
+requests are logged as curl commands and responses as HTTP dumps. It can
+also write JSON lines or an HTTP Archive (HAR) readable by browser tools,
+hide secrets and limit the size of bodies:
+
+	debugClient, err := debugclient.New(http.DefaultClient, os.Stderr,
+		debugclient.WithFormat(debugclient.HAR),
+		debugclient.RedactHeaders("Authorization"),
+		debugclient.RedactJSONFields("password", "token"),
+		debugclient.MaxBodySize(4096),
+		debugclient.TeeStreams(true), // Don't buffer streaming bodies.
+	)
+	client := api2.NewClient(routes, serverURL, api2.CustomClient(debugClient))
+	// ... make calls ...
+	client.Close() // Writes the HAR.
+
+Entries of JSON lines and HAR include timings of DNS lookup, connecting,
+TLS handshake, time to first byte and the total time.
+
+The server-side counterpart is option DumpRequests of BindRoutes. It logs
+each incoming request as a curl command reproducing it and the response:
+
+	api2.BindRoutes(mux, routes, api2.DumpRequests(&api2.DumpPolicy{
+		Log:              os.Stderr,
+		BaseURL:          "https://api.example.com",
+		SampleRate:       0.01,
+		RedactHeaders:    []string{"Authorization"},
+		RedactJSONFields: []string{"password", "token"},
+	}))
+
+A route is excluded with api2.RouteMeta(api2.DumpMetaKey, false) or gets
+its own sampling rate with api2.RouteMeta(api2.DumpMetaKey, 0.5). Streaming
+bodies are passed through, only their first bytes are logged. A request whose
+body was truncated or not read completely by the handler is logged as an
+incomplete dump with the request line and headers instead of a curl command.
+
+NewDirectClient creates a client calling the handlers in the same process,
+e.g. in tests or in a monolith hosting several services. The calling code is
+the same as with a remote client:
+
+	// Requests and responses are encoded and decoded as usual, without network.
+	client := api2.NewDirectClient(routes, api2.DirectWire)
+
+	// Request and response structs are passed to handlers as is.
+	client = api2.NewDirectClient(routes, api2.DirectFast)
+
+In DirectFast mode CallTimeout limits the context of the handler, while
+calls with CallHeader or CallAuthorization fail, because there is no HTTP
+request to apply them to.
+
+To call any http.Handler in process, pass api2.NewHandlerClient(handler)
+to NewClient in option CustomClient.
+
+Package api2test starts a test server of the routes of a service with
+handlers replaced by stubs, to test code calling the service:
+
+	s := api2test.NewServer(t, example.GetRoutes)
+	s.On("Echo").Return(&example.EchoResponse{Text: "hi"}, nil)
+	s.On("Echo").Match(func(req *example.EchoRequest) bool {
+		return req.User == "nobody"
+	}).Return(nil, errors.NotFound("no such user")).Once()
+
+	client, err := example.NewClient(s.URL)
+	// ... use the client ...
+	require.Equal(t, 2, s.Calls("Echo"))
+
+Calls without a matching stub fail the test. api2.GenerateMock(GetRoutes)
+generates file mock.go with type Mock implementing the service interface
+by calling functions set in its fields, e.g. EchoFunc, and recording
+requests, e.g. EchoCalls().
+
+api2test.CheckWireFormat renders the exact HTTP request sent by the client
+and the HTTP response produced by the server for sample values and compares
+them with golden files, failing the test if the wire format changed, e.g.
+because of a changed tag:
+
+	api2test.CheckWireFormat(t, "testdata", example.GetRoutes(nil), []api2test.Sample{
+		{
+			Request:  &example.HelloRequest{Key: "k"},
+			Response: &example.HelloResponse{Session: "s"},
+		},
+		{
+			Name:    "EchoNotFound",
+			Request: &example.EchoRequest{User: "nobody"},
+			Error:   errors.NotFound("no such user"),
+		},
+	})
+
+Run tests with API2_UPDATE_GOLDEN=1 to write the golden files.
+
+api2test.RoundTrip checks that requests and responses of all routes survive
+the trip through the client and the server. It sends random values respecting
+tags, enums, pointers and protobuf messages and reports each field which
+changed on the way, e.g. a query field of a type losing precision:
+
+	func TestRoundTrip(t *testing.T) {
+		api2test.RoundTrip(t, example.GetRoutes(nil))
+	}
+
+	func FuzzRoundTrip(f *testing.F) {
+		api2test.FuzzRoundTrip(f, example.GetRoutes(nil))
+	}
+
+closingclient.ClosingClient cancels all calls in flight on Close. To
+drain it during deploys, call Shutdown: it stops accepting new calls, waits
+for calls in flight, including streaming response bodies not closed yet, and
+cancels the rest when the context expires:
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := cc.Shutdown(ctx); err != nil {
+		log.Printf("calls were cancelled: %v", err)
+	}
+
+InFlight returns the number of calls in flight and Done returns a channel
+closed when the client is closing and all calls finished.
+
 Note that you don't have to pass a real service object to GetRoutes
 on client side. You can pass nil, it is sufficient to pass all needed
 information about request and response types in the routes table, that
@@ -173,6 +506,23 @@ provides function Method(service pointer, methodName) which you can use:
 		}
 	}
 
+Routes can also be created by generic functions api2.NewRoute and
+api2.NewMethodRoute. The types of the handler are checked at compile
+time and the server calls the handler without reflection. Transport, Meta,
+Middleware and MaxBody are set by options:
+
+	func GetRoutes(s Service) []api2.Route {
+		return []api2.Route{
+			api2.NewMethodRoute[BarRequest, BarResponse](http.MethodPost, "/v1/foo/bar/:product", &s, "Bar", api2.RouteTransport(&api2.JsonTransport{})),
+			api2.NewRoute(http.MethodGet, "/v1/health", health),
+		}
+	}
+
+NewRoute accepts a nil handler if explicit type parameters are passed,
+e.g. api2.NewRoute[HealthRequest, HealthResponse](http.MethodGet, "/v1/health", nil),
+which is enough to create a client. BindRoutes panics on routes with nil
+handlers.
+
 If you have function GetRoutes in package foo as above you can generate static client
 for it in file client.go located near the file in which GetRoutes is defined:
 
@@ -0,0 +1,421 @@
+package api2
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"moul.io/http2curl"
+)
+
+// DumpMetaKey is the key in Route.Meta controlling dumping of the route by
+// DumpRequests. The value is either a bool enabling or disabling dumps of the
+// route or a number (of any numeric type) which is the sampling rate of the
+// route overriding DumpPolicy.SampleRate. BindRoutes panics on values of
+// other types.
+const DumpMetaKey = "api2_dump"
+
+// DumpRedacted replaces secret values in dumps.
+const DumpRedacted = "REDACTED"
+
+const defaultDumpMaxBodySize = 64 * 1024
+
+// DumpPolicy configures dumps of requests handled by BindRoutes. It is the
+// server-side counterpart of package debugclient. Pass it to BindRoutes in
+// option DumpRequests.
+//
+// Each dumped request is logged as a curl command reproducing it, followed by
+// the response produced by the handler:
+//
+//	=== server request 1 ===
+//	$ curl -X 'POST' -d '{"foo":123}' ... 'https://api.example.com/hello'
+//	=== end of server request 1 ===
+//	=== server response 1 ===
+//	HTTP/1.1 200 OK
+//	...
+//	=== end of server response 1 ===
+//
+// Bodies are copied while the handler reads and writes them, so streaming
+// (is_stream) bodies are passed through without buffering. Only the first
+// MaxBodySize bytes of each body are kept. The dump is written once the
+// handler returns.
+//
+// If the request body was truncated or was not read completely by the
+// handler, the curl command could not reproduce the request. Such a request
+// is logged as an incomplete dump instead, with the request line, headers
+// and the part of the body which is known:
+//
+//	=== server request 2 (incomplete) ===
+//	POST https://api.example.com/upload HTTP/1.1
+//	...
+//	=== end of server request 2 ===
+type DumpPolicy struct {
+	// Log receives the dumps. Each dump is written in one call of Write.
+	Log io.Writer
+
+	// BaseURL is prepended to paths in curl commands, e.g.
+	// "https://api.example.com". If it is empty, the Host header is used.
+	BaseURL string
+
+	// SampleRate is the fraction of requests to dump, from 0 to 1. If it is 0,
+	// all requests are dumped.
+	SampleRate float64
+
+	// If OptIn is true, only routes with Meta[DumpMetaKey] set to true or to
+	// a rate are dumped. Otherwise all routes are dumped except those with
+	// Meta[DumpMetaKey] set to false.
+	OptIn bool
+
+	// RedactHeaders are names of headers of requests and responses whose
+	// values are replaced with DumpRedacted.
+	RedactHeaders []string
+
+	// RedactJSONFields are names of fields of JSON objects at any depth in
+	// bodies whose values are replaced with DumpRedacted.
+	RedactJSONFields []string
+
+	// MaxBodySize limits the number of bytes of each body in the dump.
+	// If it is 0, 64 KiB are kept.
+	MaxBodySize int
+}
+
+type dumper struct {
+	policy  *DumpPolicy
+	n       uint64
+	fieldRe *regexp.Regexp
+
+	logMu sync.Mutex
+}
+
+func newDumper(policy *DumpPolicy) *dumper {
+	d := &dumper{policy: policy}
+	if len(policy.RedactJSONFields) != 0 {
+		quoted := make([]string, len(policy.RedactJSONFields))
+		for i, field := range policy.RedactJSONFields {
+			quoted[i] = regexp.QuoteMeta(field)
+		}
+		// A field followed by a string (possibly cut) or another scalar.
+		d.fieldRe = regexp.MustCompile(`"(` + strings.Join(quoted, "|") + `)"\s*:\s*(?:"(?:[^"\\]|\\.)*"?|[^,{}\[\]\s]+)`)
+	}
+	return d
+}
+
+// dumpMetaRate parses Meta[DumpMetaKey] of the route. It returns the sampling
+// rate if the value is a number. Otherwise the value is nil or bool.
+// It panics if the value has another type.
+func dumpMetaRate(route Route) (rate float64, isRate bool) {
+	value := route.Meta[DumpMetaKey]
+	switch value.(type) {
+	case nil, bool:
+		return 0, false
+	}
+	v := reflect.ValueOf(value)
+	switch {
+	case v.CanInt():
+		return float64(v.Int()), true
+	case v.CanUint():
+		return float64(v.Uint()), true
+	case v.CanFloat():
+		return v.Float(), true
+	default:
+		panic(fmt.Sprintf("route %s %s: Meta[%q] must be bool or a number, got %T", route.Method, route.Path, DumpMetaKey, value))
+	}
+}
+
+// sampleRate returns the fraction of requests of the route to dump.
+func (d *dumper) sampleRate(route Route) float64 {
+	if rate, isRate := dumpMetaRate(route); isRate {
+		return rate
+	}
+	rate := d.policy.SampleRate
+	if rate == 0 {
+		rate = 1
+	}
+	switch v := route.Meta[DumpMetaKey].(type) {
+	case bool:
+		if !v {
+			return 0
+		}
+		return rate
+	default:
+		if d.policy.OptIn {
+			return 0
+		}
+		return rate
+	}
+}
+
+func (d *dumper) maxBodySize() int {
+	if d.policy.MaxBodySize > 0 {
+		return d.policy.MaxBodySize
+	}
+	return defaultDumpMaxBodySize
+}
+
+// wrap returns the handler dumping sampled requests and their responses.
+func (d *dumper) wrap(route Route, handler http.HandlerFunc, errorf func(format string, args ...interface{})) http.HandlerFunc {
+	rate := d.sampleRate(route)
+	if rate <= 0 {
+		return handler
+	}
+
+	h := route.Handler
+	if f, ok := h.(funcer); ok {
+		h = f.Func()
+	}
+	handlerType := reflect.TypeOf(h)
+	requestType := handlerType.In(1).Elem()
+	responseType := handlerType.Out(0).Elem()
+	requestStream := requestType.Kind() == reflect.Struct && getPrepared(requestType).Stream
+	responseStream := responseType.Kind() == reflect.Struct && getPrepared(responseType).Stream
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if rate < 1 && rand.Float64() >= rate {
+			handler(w, r)
+			return
+		}
+		n := atomic.AddUint64(&d.n, 1)
+
+		limit := d.maxBodySize() + 1
+		reqBody := &dumpBody{ReadCloser: r.Body, limit: limit}
+		r2 := r.Clone(r.Context())
+		r2.Body = reqBody
+		rec := &dumpResponseWriter{ResponseWriter: w, limit: limit}
+
+		handler(rec, r2)
+
+		dump, err := d.dump(n, r, reqBody, requestStream, rec, responseStream)
+		if err != nil {
+			errorf("%s %s failed to dump request %d: %v", r.Method, r.URL.Path, n, err)
+			return
+		}
+		d.logMu.Lock()
+		_, err = d.policy.Log.Write(dump)
+		d.logMu.Unlock()
+		if err != nil {
+			errorf("%s %s failed to write dump of request %d: %v", r.Method, r.URL.Path, n, err)
+		}
+	}
+}
+
+func (d *dumper) dump(n uint64, r *http.Request, reqBody *dumpBody, requestStream bool, rec *dumpResponseWriter, responseStream bool) ([]byte, error) {
+	// Log a copy of the request with redacted headers and body.
+	u := *r.URL
+	if d.policy.BaseURL != "" {
+		base := strings.TrimSuffix(d.policy.BaseURL, "/")
+		u.Scheme = ""
+		u.Host = ""
+		parsed, err := r.URL.Parse(base + u.RequestURI())
+		if err != nil {
+			return nil, fmt.Errorf("bad BaseURL %q: %w", d.policy.BaseURL, err)
+		}
+		u = *parsed
+	} else {
+		u.Host = r.Host
+		u.Scheme = "http"
+		if r.TLS != nil {
+			u.Scheme = "https"
+		}
+	}
+	reqShown, reqNote := d.showBody(reqBody.buf.Bytes(), reqBody.size, requestStream)
+
+	// The curl command is only logged if it reproduces the request.
+	var reqDump bytes.Buffer
+	readFully := r.Body == nil || r.Body == http.NoBody || reqBody.eof || reqBody.size == r.ContentLength
+	incomplete := !readFully || reqBody.size > int64(d.maxBodySize())
+	if incomplete {
+		if !readFully {
+			reqNote += fmt.Sprintf("\n... (not read completely by the handler, %d bytes read)", reqBody.size)
+		}
+		fmt.Fprintf(&reqDump, "%s %s HTTP/1.1\r\n", r.Method, u.String())
+		if err := d.redactHeader(r.Header).Write(&reqDump); err != nil {
+			return nil, fmt.Errorf("failed to write request headers: %w", err)
+		}
+		reqDump.WriteString("\r\n")
+		reqDump.Write(reqShown)
+	} else {
+		req2 := &http.Request{
+			Method:        r.Method,
+			URL:           &u,
+			Header:        d.redactHeader(r.Header),
+			Body:          io.NopCloser(bytes.NewReader(reqShown)),
+			ContentLength: int64(len(reqShown)),
+		}
+		curl, err := http2curl.GetCurlCommand(req2)
+		if err != nil {
+			return nil, fmt.Errorf("http2curl.GetCurlCommand failed: %w", err)
+		}
+		fmt.Fprintf(&reqDump, "$ %s", curl)
+	}
+
+	status := rec.status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	var resDump bytes.Buffer
+	fmt.Fprintf(&resDump, "HTTP/1.1 %03d %s\r\n", status, http.StatusText(status))
+	if err := d.redactHeader(rec.Header()).Write(&resDump); err != nil {
+		return nil, fmt.Errorf("failed to write response headers: %w", err)
+	}
+	resDump.WriteString("\r\n")
+	resShown, resNote := d.showBody(rec.buf.Bytes(), rec.size, responseStream)
+
+	var buf bytes.Buffer
+	title := fmt.Sprintf("server request %d", n)
+	if incomplete {
+		title += " (incomplete)"
+	}
+	fmt.Fprintf(&buf, "=== %s ===\n%s%s\n=== end of server request %d ===\n", title, reqDump.Bytes(), reqNote, n)
+	fmt.Fprintf(&buf, "=== server response %d ===\n%s%s%s\n=== end of server response %d ===\n", n, resDump.Bytes(), resShown, resNote, n)
+	return buf.Bytes(), nil
+}
+
+// showBody returns the redacted body truncated to MaxBodySize and a note
+// about truncation. size is the full size of the body, body may be a prefix.
+func (d *dumper) showBody(body []byte, size int64, stream bool) ([]byte, string) {
+	if d.fieldRe != nil && len(body) != 0 {
+		body = d.redactJSON(body, int64(len(body)) == size && !stream)
+	}
+	var note string
+	if limit := d.maxBodySize(); size > int64(limit) || len(body) > limit {
+		body = body[:min(len(body), limit)]
+		note = fmt.Sprintf("\n... (truncated, %d bytes total)", size)
+	}
+	if stream {
+		note += "\n... (stream)"
+	}
+	return body, note
+}
+
+func (d *dumper) redactHeader(header http.Header) http.Header {
+	if len(d.policy.RedactHeaders) == 0 {
+		return header
+	}
+	header = header.Clone()
+	for _, name := range d.policy.RedactHeaders {
+		if values, has := header[http.CanonicalHeaderKey(name)]; has {
+			for i := range values {
+				values[i] = DumpRedacted
+			}
+		}
+	}
+	return header
+}
+
+// redactJSON replaces values of the fields. If the body is complete JSON,
+// it is decoded and encoded again. Otherwise (e.g. a prefix of a stream)
+// scalar values are replaced textually.
+func (d *dumper) redactJSON(body []byte, complete bool) []byte {
+	if complete {
+		var value interface{}
+		decoder := json.NewDecoder(bytes.NewReader(body))
+		decoder.UseNumber()
+		if err := decoder.Decode(&value); err == nil && !decoder.More() {
+			if redacted, err := json.Marshal(d.redactValue(value)); err == nil {
+				if bytes.HasSuffix(body, []byte("\n")) {
+					redacted = append(redacted, '\n')
+				}
+				return redacted
+			}
+		}
+	}
+	return d.fieldRe.ReplaceAll(body, []byte(`"$1":"`+DumpRedacted+`"`))
+}
+
+func (d *dumper) redactValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, field := range v {
+			redacted := false
+			for _, name := range d.policy.RedactJSONFields {
+				if key == name {
+					redacted = true
+					break
+				}
+			}
+			if redacted {
+				v[key] = DumpRedacted
+			} else {
+				v[key] = d.redactValue(field)
+			}
+		}
+	case []interface{}:
+		for i := range v {
+			v[i] = d.redactValue(v[i])
+		}
+	}
+	return value
+}
+
+// dumpBody copies the first bytes of the request body as the handler reads it.
+type dumpBody struct {
+	io.ReadCloser
+	limit int
+
+	buf  bytes.Buffer
+	size int64
+	eof  bool
+}
+
+func (b *dumpBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err == io.EOF {
+		b.eof = true
+	}
+	if n > 0 {
+		b.size += int64(n)
+		if room := b.limit - b.buf.Len(); room > 0 {
+			b.buf.Write(p[:min(n, room)])
+		}
+	}
+	return n, err
+}
+
+// dumpResponseWriter copies the status and the first bytes of the response
+// as the handler writes it.
+type dumpResponseWriter struct {
+	http.ResponseWriter
+	limit int
+
+	status int
+	buf    bytes.Buffer
+	size   int64
+}
+
+func (w *dumpResponseWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *dumpResponseWriter) Write(p []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(p)
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	w.size += int64(n)
+	if room := w.limit - w.buf.Len(); room > 0 {
+		w.buf.Write(p[:min(n, room)])
+	}
+	return n, err
+}
+
+func (w *dumpResponseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap is used by http.ResponseController.
+func (w *dumpResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,75 @@
+package api2
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDumpSampling(t *testing.T) {
+	type Request struct {
+	}
+	type Response struct {
+	}
+	handler := func(ctx context.Context, req *Request) (*Response, error) {
+		return &Response{}, nil
+	}
+
+	var log bytes.Buffer
+	d := newDumper(&DumpPolicy{Log: &log, SampleRate: 0.5})
+	noop := func(w http.ResponseWriter, r *http.Request) {}
+	errorf := func(format string, args ...interface{}) {}
+
+	count := func(route Route) int {
+		log.Reset()
+		h := d.wrap(route, noop, errorf)
+		for i := 0; i < 1000; i++ {
+			h(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/x", nil))
+		}
+		return strings.Count(log.String(), "=== server request")
+	}
+
+	route := Route{Method: http.MethodGet, Path: "/x", Handler: handler}
+	n := count(route)
+	require.Greater(t, n, 350)
+	require.Less(t, n, 650)
+
+	route.Meta = map[string]interface{}{DumpMetaKey: 1.0}
+	require.Equal(t, 1000, count(route))
+
+	route.Meta = map[string]interface{}{DumpMetaKey: 0.0}
+	require.Equal(t, 0, count(route))
+
+	// Any numeric type is accepted.
+	route.Meta = map[string]interface{}{DumpMetaKey: 1}
+	require.Equal(t, 1000, count(route))
+	route.Meta = map[string]interface{}{DumpMetaKey: float32(0)}
+	require.Equal(t, 0, count(route))
+
+	route.Meta = map[string]interface{}{DumpMetaKey: "yes"}
+	require.Panics(t, func() {
+		d.wrap(route, noop, errorf)
+	})
+	// BindRoutes validates the value even without DumpRequests.
+	require.Panics(t, func() {
+		BindRoutes(http.NewServeMux(), []Route{route})
+	})
+}
+
+func TestDumpRedactPartialJSON(t *testing.T) {
+	d := newDumper(&DumpPolicy{RedactJSONFields: []string{"token"}, MaxBodySize: 20})
+
+	body, note := d.showBody([]byte(`{"id":1,"token":"abc"}`), 22, false)
+	require.Equal(t, `{"id":1,"token":"RED`, string(body))
+	require.Equal(t, "\n... (truncated, 22 bytes total)", note)
+
+	// A prefix of a stream of JSON lines is redacted textually.
+	body, note = d.showBody([]byte(`{"token":"a"}`+"\n"+`{"token":"b`), 100, true)
+	require.Equal(t, `{"token":"REDACTED"}`, string(body))
+	require.Equal(t, "\n... (truncated, 100 bytes total)\n... (stream)", note)
+}
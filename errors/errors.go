@@ -1,6 +1,7 @@
 package errors
 
 import (
+	"errors"
 	"fmt"
 
 	"github.com/grpc-ecosystem/grpc-gateway/v2/runtime"
@@ -24,6 +25,25 @@ func (e *CodeError) HttpCode() int {
 	return runtime.HTTPStatusFromCode(e.code)
 }
 
+// Code returns the gRPC code of the error.
+func (e *CodeError) Code() codes.Code {
+	return e.code
+}
+
+// Code returns the code of the first error in the chain of err having method
+// Code() codes.Code, e.g. created by functions of this package. It returns
+// codes.OK if err is nil and codes.Unknown if no error has the method.
+func Code(err error) codes.Code {
+	if err == nil {
+		return codes.OK
+	}
+	var coder interface{ Code() codes.Code }
+	if errors.As(err, &coder) {
+		return coder.Code()
+	}
+	return codes.Unknown
+}
+
 func makeError(code codes.Code, format string, a ...interface{}) *CodeError {
 	return &CodeError{
 		code: code,
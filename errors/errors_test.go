@@ -11,6 +11,7 @@ import (
 	"testing"
 
 	"github.com/starius/api2"
+	"google.golang.org/grpc/codes"
 )
 
 func TestErrToHttp(t *testing.T) {
@@ -85,3 +86,22 @@ func TestUnwrap(t *testing.T) {
 		}
 	}
 }
+
+func TestCode(t *testing.T) {
+	cases := []struct {
+		err  error
+		want codes.Code
+	}{
+		{err: nil, want: codes.OK},
+		{err: NotFound("document is not found"), want: codes.NotFound},
+		{err: fmt.Errorf("shard 1: %w", Unavailable("shard is down")), want: codes.Unavailable},
+		{err: &api2.CircuitOpenError{Name: "host example.com"}, want: codes.Unavailable},
+		{err: io.EOF, want: codes.Unknown},
+	}
+
+	for _, tc := range cases {
+		if got := Code(tc.err); got != tc.want {
+			t.Errorf("Code(%v) returned %v, want %v.", tc.err, got, tc.want)
+		}
+	}
+}
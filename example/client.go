@@ -15,6 +15,15 @@ type Client struct {
 
 var _ IEchoService = (*Client)(nil)
 
+// NewClient creates a client. Options, e.g. interceptors added by
+// api2.AddInterceptor, are passed to api2.NewClient.
+//
+// Methods of the client implement the service interface, so they take
+// options of a single call from the context:
+//
+//	ctx = api2.WithCallOptions(ctx, api2.CallTimeout(time.Second))
+//
+// Methods of WithOptions accept them after the request as well.
 func NewClient(baseURL string, opts ...api2.Option) (*Client, error) {
 	if _, err := url.ParseRequestURI(baseURL); err != nil {
 		return nil, err
@@ -104,3 +113,87 @@ func (c *Client) BasicWildcard(ctx context.Context, req *BasicWildcardRequest) (
 	}
 	return
 }
+
+// ClientWithOptions is the client whose methods accept options of a single
+// call after the request.
+type ClientWithOptions struct {
+	api2client *api2.Client
+}
+
+// WithOptions returns the client whose methods accept options of a single
+// call after the request.
+func (c *Client) WithOptions() ClientWithOptions {
+	return ClientWithOptions{api2client: c.api2client}
+}
+
+func (c ClientWithOptions) Hello(ctx context.Context, req *HelloRequest, opts ...api2.CallOption) (res *HelloResponse, err error) {
+	res = &HelloResponse{}
+	err = c.api2client.Call(ctx, res, req, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return
+}
+
+func (c ClientWithOptions) Echo(ctx context.Context, req *EchoRequest, opts ...api2.CallOption) (res *EchoResponse, err error) {
+	res = &EchoResponse{}
+	err = c.api2client.Call(ctx, res, req, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return
+}
+
+func (c ClientWithOptions) Since(ctx context.Context, req *SinceRequest, opts ...api2.CallOption) (res *SinceResponse, err error) {
+	res = &SinceResponse{}
+	err = c.api2client.Call(ctx, res, req, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return
+}
+
+func (c ClientWithOptions) Stream(ctx context.Context, req *StreamRequest, opts ...api2.CallOption) (res *StreamResponse, err error) {
+	res = &StreamResponse{}
+	err = c.api2client.Call(ctx, res, req, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return
+}
+
+func (c ClientWithOptions) Redirect(ctx context.Context, req *RedirectRequest, opts ...api2.CallOption) (res *RedirectResponse, err error) {
+	res = &RedirectResponse{}
+	err = c.api2client.Call(ctx, res, req, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return
+}
+
+func (c ClientWithOptions) Raw(ctx context.Context, req *RawRequest, opts ...api2.CallOption) (res *RawResponse, err error) {
+	res = &RawResponse{}
+	err = c.api2client.Call(ctx, res, req, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return
+}
+
+func (c ClientWithOptions) AdvancedWildcard(ctx context.Context, req *AdvancedWildcardRequest, opts ...api2.CallOption) (res *AdvancedWildcardResponse, err error) {
+	res = &AdvancedWildcardResponse{}
+	err = c.api2client.Call(ctx, res, req, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return
+}
+
+func (c ClientWithOptions) BasicWildcard(ctx context.Context, req *BasicWildcardRequest, opts ...api2.CallOption) (res *BasicWildcardResponse, err error) {
+	res = &BasicWildcardResponse{}
+	err = c.api2client.Call(ctx, res, req, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return
+}
@@ -7,6 +7,7 @@ import (
 
 func main() {
 	api2.GenerateClient(example.GetRoutes)
+	api2.GenerateMock(example.GetRoutes)
 	api2.GenerateTSClient(&api2.TypesGenConfig{
 		OutDir:    "./ts-types",
 		Blacklist: []api2.BlacklistItem{{Service: "Hello"}},
@@ -0,0 +1,171 @@
+package example
+
+// Code generated by api2. DO NOT EDIT.
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+var _ IEchoService = (*Mock)(nil)
+
+// Mock implements the service by calling the functions set in its fields.
+// A method whose function is not set returns an error. Requests of all calls
+// are recorded. Set the functions before calling the methods.
+type Mock struct {
+	HelloFunc            func(ctx context.Context, req *HelloRequest) (*HelloResponse, error)
+	EchoFunc             func(ctx context.Context, req *EchoRequest) (*EchoResponse, error)
+	SinceFunc            func(ctx context.Context, req *SinceRequest) (*SinceResponse, error)
+	StreamFunc           func(ctx context.Context, req *StreamRequest) (*StreamResponse, error)
+	RedirectFunc         func(ctx context.Context, req *RedirectRequest) (*RedirectResponse, error)
+	RawFunc              func(ctx context.Context, req *RawRequest) (*RawResponse, error)
+	AdvancedWildcardFunc func(ctx context.Context, req *AdvancedWildcardRequest) (*AdvancedWildcardResponse, error)
+	BasicWildcardFunc    func(ctx context.Context, req *BasicWildcardRequest) (*BasicWildcardResponse, error)
+
+	mu                    sync.Mutex
+	helloCalls            []*HelloRequest
+	echoCalls             []*EchoRequest
+	sinceCalls            []*SinceRequest
+	streamCalls           []*StreamRequest
+	redirectCalls         []*RedirectRequest
+	rawCalls              []*RawRequest
+	advancedWildcardCalls []*AdvancedWildcardRequest
+	basicWildcardCalls    []*BasicWildcardRequest
+}
+
+func (m *Mock) Hello(ctx context.Context, req *HelloRequest) (*HelloResponse, error) {
+	m.mu.Lock()
+	m.helloCalls = append(m.helloCalls, req)
+	m.mu.Unlock()
+	if m.HelloFunc == nil {
+		return nil, fmt.Errorf("Mock.HelloFunc is not set")
+	}
+	return m.HelloFunc(ctx, req)
+}
+
+// HelloCalls returns requests of all calls of Hello.
+func (m *Mock) HelloCalls() []*HelloRequest {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]*HelloRequest(nil), m.helloCalls...)
+}
+
+func (m *Mock) Echo(ctx context.Context, req *EchoRequest) (*EchoResponse, error) {
+	m.mu.Lock()
+	m.echoCalls = append(m.echoCalls, req)
+	m.mu.Unlock()
+	if m.EchoFunc == nil {
+		return nil, fmt.Errorf("Mock.EchoFunc is not set")
+	}
+	return m.EchoFunc(ctx, req)
+}
+
+// EchoCalls returns requests of all calls of Echo.
+func (m *Mock) EchoCalls() []*EchoRequest {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]*EchoRequest(nil), m.echoCalls...)
+}
+
+func (m *Mock) Since(ctx context.Context, req *SinceRequest) (*SinceResponse, error) {
+	m.mu.Lock()
+	m.sinceCalls = append(m.sinceCalls, req)
+	m.mu.Unlock()
+	if m.SinceFunc == nil {
+		return nil, fmt.Errorf("Mock.SinceFunc is not set")
+	}
+	return m.SinceFunc(ctx, req)
+}
+
+// SinceCalls returns requests of all calls of Since.
+func (m *Mock) SinceCalls() []*SinceRequest {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]*SinceRequest(nil), m.sinceCalls...)
+}
+
+func (m *Mock) Stream(ctx context.Context, req *StreamRequest) (*StreamResponse, error) {
+	m.mu.Lock()
+	m.streamCalls = append(m.streamCalls, req)
+	m.mu.Unlock()
+	if m.StreamFunc == nil {
+		return nil, fmt.Errorf("Mock.StreamFunc is not set")
+	}
+	return m.StreamFunc(ctx, req)
+}
+
+// StreamCalls returns requests of all calls of Stream.
+func (m *Mock) StreamCalls() []*StreamRequest {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]*StreamRequest(nil), m.streamCalls...)
+}
+
+func (m *Mock) Redirect(ctx context.Context, req *RedirectRequest) (*RedirectResponse, error) {
+	m.mu.Lock()
+	m.redirectCalls = append(m.redirectCalls, req)
+	m.mu.Unlock()
+	if m.RedirectFunc == nil {
+		return nil, fmt.Errorf("Mock.RedirectFunc is not set")
+	}
+	return m.RedirectFunc(ctx, req)
+}
+
+// RedirectCalls returns requests of all calls of Redirect.
+func (m *Mock) RedirectCalls() []*RedirectRequest {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]*RedirectRequest(nil), m.redirectCalls...)
+}
+
+func (m *Mock) Raw(ctx context.Context, req *RawRequest) (*RawResponse, error) {
+	m.mu.Lock()
+	m.rawCalls = append(m.rawCalls, req)
+	m.mu.Unlock()
+	if m.RawFunc == nil {
+		return nil, fmt.Errorf("Mock.RawFunc is not set")
+	}
+	return m.RawFunc(ctx, req)
+}
+
+// RawCalls returns requests of all calls of Raw.
+func (m *Mock) RawCalls() []*RawRequest {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]*RawRequest(nil), m.rawCalls...)
+}
+
+func (m *Mock) AdvancedWildcard(ctx context.Context, req *AdvancedWildcardRequest) (*AdvancedWildcardResponse, error) {
+	m.mu.Lock()
+	m.advancedWildcardCalls = append(m.advancedWildcardCalls, req)
+	m.mu.Unlock()
+	if m.AdvancedWildcardFunc == nil {
+		return nil, fmt.Errorf("Mock.AdvancedWildcardFunc is not set")
+	}
+	return m.AdvancedWildcardFunc(ctx, req)
+}
+
+// AdvancedWildcardCalls returns requests of all calls of AdvancedWildcard.
+func (m *Mock) AdvancedWildcardCalls() []*AdvancedWildcardRequest {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]*AdvancedWildcardRequest(nil), m.advancedWildcardCalls...)
+}
+
+func (m *Mock) BasicWildcard(ctx context.Context, req *BasicWildcardRequest) (*BasicWildcardResponse, error) {
+	m.mu.Lock()
+	m.basicWildcardCalls = append(m.basicWildcardCalls, req)
+	m.mu.Unlock()
+	if m.BasicWildcardFunc == nil {
+		return nil, fmt.Errorf("Mock.BasicWildcardFunc is not set")
+	}
+	return m.BasicWildcardFunc(ctx, req)
+}
+
+// BasicWildcardCalls returns requests of all calls of BasicWildcard.
+func (m *Mock) BasicWildcardCalls() []*BasicWildcardRequest {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]*BasicWildcardRequest(nil), m.basicWildcardCalls...)
+}
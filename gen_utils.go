@@ -29,6 +29,10 @@ func GetFnInfo(i interface{}) FnInfo {
 		}
 	}
 
+	if f, ok := i.(funcer); ok {
+		i = f.Func()
+	}
+
 	// Format: pgkpath.(object).Method-fm.
 	funcName := runtime.FuncForPC(reflect.ValueOf(i).Pointer()).Name()
 	lastSlash := strings.LastIndexByte(funcName, '/')
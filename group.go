@@ -0,0 +1,107 @@
+package api2
+
+import (
+	"strings"
+)
+
+// GroupsMetaKey is the key in Route.Meta under which names of groups
+// containing the route are stored (as []string, outermost group first).
+// Generators of OpenAPI spec and YAML use them as tags of the route. The
+// TypeScript generator ignores them.
+const GroupsMetaKey = "api2_groups"
+
+// Group describes common properties of a set of routes. Use method Routes
+// to apply them to the routes. Groups can be nested.
+//
+// Only names of groups reach generated code: they are emitted as tags by the
+// OpenAPI and YAML generators. The TypeScript generator ignores groups, and
+// no generator emits Meta entries; they are used only at runtime, e.g. by
+// middleware or DumpMetaKey.
+//
+//	func GetRoutes(s *Service) []api2.Route {
+//		admin := &api2.Group{
+//			Prefix:    "/v1/admin",
+//			Name:      "admin",
+//			Transport: adminTransport,
+//			Meta:      map[string]interface{}{"roles": []string{"admin"}},
+//		}
+//		return admin.Routes([]api2.Route{
+//			{Method: http.MethodPost, Path: "/users", Handler: s.CreateUser},
+//			...
+//		})
+//	}
+type Group struct {
+	// Prefix prepended to paths of the routes.
+	Prefix string
+
+	// Name of the group. It is added to Meta[GroupsMetaKey] of the routes.
+	Name string
+
+	// Transport used in routes without Transport.
+	Transport Transport
+
+	// Meta entries added to Meta of the routes. Entries set in the route
+	// itself or in an inner group take precedence.
+	Meta map[string]interface{}
+
+	// Middleware wrapping Middleware of the routes.
+	Middleware Middleware
+
+	// MaxBody used in routes without MaxBody.
+	MaxBody int64
+}
+
+// Routes returns copies of the routes with the properties of the group
+// applied. The result can be passed to BindRoutes, NewClient and generators
+// as any other table of routes.
+func (g *Group) Routes(routes []Route) []Route {
+	result := make([]Route, 0, len(routes))
+	for _, route := range routes {
+		route.Path = joinPaths(g.Prefix, route.Path)
+		if route.Transport == nil {
+			route.Transport = g.Transport
+		}
+		if route.MaxBody == 0 {
+			route.MaxBody = g.MaxBody
+		}
+		route.Middleware = chainMiddleware(g.Middleware, route.Middleware)
+
+		if len(g.Meta) != 0 || g.Name != "" {
+			meta := make(map[string]interface{}, len(g.Meta)+len(route.Meta)+1)
+			for k, v := range g.Meta {
+				meta[k] = v
+			}
+			for k, v := range route.Meta {
+				meta[k] = v
+			}
+			if g.Name != "" {
+				groups, _ := route.Meta[GroupsMetaKey].([]string)
+				meta[GroupsMetaKey] = append([]string{g.Name}, groups...)
+			}
+			route.Meta = meta
+		}
+
+		result = append(result, route)
+	}
+	return result
+}
+
+// RouteGroups returns names of groups containing the route, outermost first.
+func RouteGroups(route *Route) []string {
+	groups, _ := route.Meta[GroupsMetaKey].([]string)
+	return groups
+}
+
+func joinPaths(prefix, path string) string {
+	if prefix == "" {
+		return path
+	}
+	prefix = strings.TrimSuffix(prefix, "/")
+	if path == "" || path == "/" {
+		return prefix + "/"
+	}
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	return prefix + path
+}
@@ -0,0 +1,72 @@
+package api2
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGroupRoutes(t *testing.T) {
+	handler := func(ctx context.Context, req *HelloRequest) (*HelloResponse, error) {
+		return &HelloResponse{}, nil
+	}
+	ownTransport := &JsonTransport{}
+	groupTransport := &JsonTransport{}
+
+	inner := &Group{
+		Prefix:  "/admin/",
+		Name:    "admin",
+		Meta:    map[string]interface{}{"roles": "admin", "audit": true},
+		MaxBody: 100,
+	}
+	outer := &Group{
+		Prefix:    "/v1",
+		Name:      "v1",
+		Transport: groupTransport,
+		Meta:      map[string]interface{}{"roles": "user", "version": 1},
+		MaxBody:   1000,
+	}
+
+	routes := outer.Routes(inner.Routes([]Route{
+		{Method: http.MethodGet, Path: "/users", Handler: handler, Meta: map[string]interface{}{"audit": false}},
+		{Method: http.MethodPost, Path: "/", Handler: handler, Transport: ownTransport, MaxBody: 10},
+	}))
+
+	require.Len(t, routes, 2)
+
+	require.Equal(t, "/v1/admin/users", routes[0].Path)
+	require.Equal(t, Transport(groupTransport), routes[0].Transport)
+	require.Equal(t, int64(100), routes[0].MaxBody)
+	require.Equal(t, map[string]interface{}{
+		"roles":       "admin",
+		"audit":       false,
+		"version":     1,
+		GroupsMetaKey: []string{"v1", "admin"},
+	}, routes[0].Meta)
+	require.Equal(t, []string{"v1", "admin"}, RouteGroups(&routes[0]))
+
+	require.Equal(t, "/v1/admin/", routes[1].Path)
+	require.Equal(t, Transport(ownTransport), routes[1].Transport)
+	require.Equal(t, int64(10), routes[1].MaxBody)
+}
+
+func TestChainMiddleware(t *testing.T) {
+	var calls []string
+	newMiddleware := func(name string) Middleware {
+		return func(ctx context.Context, req any, next Handler) (any, any) {
+			calls = append(calls, name)
+			return next(ctx, req)
+		}
+	}
+	m := chainMiddleware(newMiddleware("outer"), chainMiddleware(nil, newMiddleware("inner")))
+	res, err := m(context.Background(), 1, func(ctx context.Context, req any) (any, any) {
+		calls = append(calls, "handler")
+		return req.(int) + 1, nil
+	})
+	require.Nil(t, err)
+	require.Equal(t, 2, res)
+	require.Equal(t, []string{"outer", "inner", "handler"}, calls)
+	require.Nil(t, chainMiddleware(nil, nil))
+}
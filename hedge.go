@@ -0,0 +1,304 @@
+package api2
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// HedgePolicy configures hedged requests of Client. Pass it to NewClient
+// in option Hedge.
+//
+// If the response to a request does not arrive within the delay, the client
+// sends the same request again (to another endpoint if LoadBalance is used)
+// and uses the response which arrives first. The other requests are canceled.
+// Only routes marked Idempotent are hedged. Requests with streaming bodies
+// (is_stream) are never hedged.
+type HedgePolicy struct {
+	// Delay before sending the next request. If it is 0, the delay is the
+	// Percentile (default 0.95) of the latency of the route observed by the
+	// client. Until 20 calls of the route complete, requests are not hedged.
+	// Percentile must be in (0, 1] or 0 for the default.
+	Delay      time.Duration
+	Percentile float64
+
+	// MaxHedges is the maximum number of extra requests per call. Defaults
+	// to 1.
+	MaxHedges int
+
+	// Budget caps the extra load: each call earns Budget tokens (up to 10)
+	// and each extra request spends one. E.g. 0.1 allows at most 10% more
+	// requests. Zero means no limit.
+	Budget float64
+}
+
+const (
+	hedgeMinSamples = 20
+	hedgeSamples    = 128
+	hedgeMaxTokens  = 10
+)
+
+type hedger struct {
+	policy *HedgePolicy
+
+	mu        sync.Mutex
+	tokens    float64
+	latencies map[string]*latencyWindow
+}
+
+// latencyWindow keeps the latest latencies of a route.
+type latencyWindow struct {
+	samples []time.Duration
+	next    int
+	total   int
+
+	// The percentile is recomputed every hedgeMinSamples samples.
+	percentile time.Duration
+}
+
+func newHedger(policy *HedgePolicy) *hedger {
+	if !(policy.Percentile >= 0 && policy.Percentile <= 1) {
+		panic(fmt.Sprintf("HedgePolicy.Percentile must be in (0, 1], got %v", policy.Percentile))
+	}
+	return &hedger{
+		policy:    policy,
+		tokens:    hedgeMaxTokens,
+		latencies: make(map[string]*latencyWindow),
+	}
+}
+
+func (h *hedger) delay(key string) (time.Duration, bool) {
+	if h.policy.Delay > 0 {
+		return h.policy.Delay, true
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	w, has := h.latencies[key]
+	if !has || w.total < hedgeMinSamples {
+		return 0, false
+	}
+	return w.percentile, true
+}
+
+func (h *hedger) observe(key string, latency time.Duration) {
+	if h.policy.Delay > 0 {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	w, has := h.latencies[key]
+	if !has {
+		w = &latencyWindow{}
+		h.latencies[key] = w
+	}
+	if len(w.samples) < hedgeSamples {
+		w.samples = append(w.samples, latency)
+	} else {
+		w.samples[w.next] = latency
+		w.next = (w.next + 1) % hedgeSamples
+	}
+	w.total++
+	if w.total%hedgeMinSamples == 0 {
+		percentile := h.policy.Percentile
+		if percentile == 0 {
+			percentile = 0.95
+		}
+		sorted := append([]time.Duration(nil), w.samples...)
+		sort.Slice(sorted, func(i, j int) bool {
+			return sorted[i] < sorted[j]
+		})
+		w.percentile = sorted[int(percentile*float64(len(sorted)-1))]
+	}
+}
+
+// earn adds tokens for a call.
+func (h *hedger) earn() {
+	if h.policy.Budget == 0 {
+		return
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.tokens = min(h.tokens+h.policy.Budget, hedgeMaxTokens)
+}
+
+// spend takes a token for an extra request.
+func (h *hedger) spend() bool {
+	if h.policy.Budget == 0 {
+		return true
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.tokens < 1 {
+		return false
+	}
+	h.tokens--
+	return true
+}
+
+// hedgeGroup is the set of endpoints used by requests of one hedged call.
+// Load balancer sends them to different endpoints if possible.
+type hedgeGroup struct {
+	mu        sync.Mutex
+	endpoints []*endpoint
+}
+
+type hedgeGroupType struct{}
+
+func (g *hedgeGroup) add(e *endpoint) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.endpoints = append(g.endpoints, e)
+}
+
+func (g *hedgeGroup) list() []*endpoint {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return append([]*endpoint(nil), g.endpoints...)
+}
+
+func canHedge(route Route, req *http.Request) bool {
+	return route.Idempotent && (req.Body == nil || req.Body == http.NoBody || req.GetBody != nil)
+}
+
+// attempt makes one attempt of the call, hedged if the policy allows.
+func (c *Client) attempt(route Route, req *http.Request) (*http.Response, error) {
+	if c.hedger == nil || !canHedge(route, req) {
+		return c.doOnce(route, req)
+	}
+	return c.hedge(route, req)
+}
+
+type hedgeResult struct {
+	res    *http.Response
+	err    error
+	cancel context.CancelFunc
+	index  int
+}
+
+func (c *Client) hedge(route Route, req *http.Request) (*http.Response, error) {
+	h := c.hedger
+	h.earn()
+	key := route.Method + " " + route.Path
+	delay, hedged := h.delay(key)
+	maxRequests := 1
+	if hedged {
+		maxRequests += max(h.policy.MaxHedges, 1)
+	}
+
+	parent := req.Context()
+	group := &hedgeGroup{}
+	results := make(chan hedgeResult, maxRequests)
+	var cancels []context.CancelFunc
+	start := time.Now()
+	launch := func(first bool) error {
+		ctx, cancel := context.WithCancel(context.WithValue(parent, hedgeGroupType{}, group))
+		cancels = append(cancels, cancel)
+		index := len(cancels) - 1
+		r := req.Clone(ctx)
+		if !first && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				cancel()
+				return fmt.Errorf("failed to rewind request body: %w", err)
+			}
+			r.Body = body
+		}
+		go func() {
+			res, err := c.doOnce(route, r)
+			results <- hedgeResult{res: res, err: err, cancel: cancel, index: index}
+		}()
+		return nil
+	}
+
+	if err := launch(true); err != nil {
+		return nil, err
+	}
+	launched, pending := 1, 1
+	var timer <-chan time.Time
+	if launched < maxRequests {
+		timer = time.After(delay)
+	}
+
+	var last hedgeResult
+	for {
+		select {
+		case r := <-results:
+			pending--
+			if r.err == nil && r.res.StatusCode < 500 {
+				h.observe(key, time.Since(start))
+				if last.cancel != nil {
+					if last.res != nil {
+						if err := last.res.Body.Close(); err != nil {
+							c.errorf("failed to close resource: %v", err)
+						}
+					}
+					last.cancel()
+				}
+				if pending != 0 {
+					// Cancel the other requests and release their responses.
+					for i, cancel := range cancels {
+						if i != r.index {
+							cancel()
+						}
+					}
+					go func(pending int) {
+						for ; pending > 0; pending-- {
+							r := <-results
+							if r.res != nil {
+								if err := r.res.Body.Close(); err != nil {
+									c.errorf("failed to close resource: %v", err)
+								}
+							}
+							r.cancel()
+						}
+					}(pending)
+				}
+				// The context of the winner is canceled when its body is closed.
+				r.res.Body = &cancelOnClose{ReadCloser: r.res.Body, cancel: r.cancel}
+				return r.res, nil
+			}
+			// The request failed: keep it in case all of them fail.
+			if last.res != nil {
+				if err := last.res.Body.Close(); err != nil {
+					c.errorf("failed to close resource: %v", err)
+				}
+			}
+			if last.cancel != nil {
+				last.cancel()
+			}
+			last = r
+			if pending == 0 && (launched == maxRequests || parent.Err() != nil || !h.spend()) {
+				if last.res != nil {
+					last.res.Body = &cancelOnClose{ReadCloser: last.res.Body, cancel: last.cancel}
+				} else {
+					last.cancel()
+				}
+				return last.res, last.err
+			}
+			if pending == 0 {
+				// Don't wait for the delay if nothing is in flight.
+				if err := launch(false); err != nil {
+					return nil, err
+				}
+				launched++
+				pending++
+			}
+		case <-timer:
+			timer = nil
+			if !h.spend() {
+				continue
+			}
+			if err := launch(false); err != nil {
+				continue
+			}
+			launched++
+			pending++
+			if launched < maxRequests {
+				timer = time.After(delay)
+			}
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package api2
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHedgerDelay(t *testing.T) {
+	h := newHedger(&HedgePolicy{Delay: time.Second})
+	delay, hedged := h.delay("GET /")
+	require.True(t, hedged)
+	require.Equal(t, time.Second, delay)
+
+	h = newHedger(&HedgePolicy{Percentile: 0.9})
+	_, hedged = h.delay("GET /")
+	require.False(t, hedged)
+	for i := 1; i <= 100; i++ {
+		h.observe("GET /", time.Duration(i)*time.Millisecond)
+	}
+	delay, hedged = h.delay("GET /")
+	require.True(t, hedged)
+	require.Equal(t, 90*time.Millisecond, delay)
+	_, hedged = h.delay("GET /other")
+	require.False(t, hedged)
+}
+
+func TestHedgerBadPercentile(t *testing.T) {
+	for _, percentile := range []float64{-0.5, 1.5, math.NaN()} {
+		require.Panics(t, func() {
+			newHedger(&HedgePolicy{Percentile: percentile})
+		})
+	}
+
+	// The highest latency is used.
+	h := newHedger(&HedgePolicy{Percentile: 1})
+	for i := 1; i <= 20; i++ {
+		h.observe("GET /", time.Duration(i)*time.Millisecond)
+	}
+	delay, hedged := h.delay("GET /")
+	require.True(t, hedged)
+	require.Equal(t, 20*time.Millisecond, delay)
+}
+
+func TestHedgerBudget(t *testing.T) {
+	h := newHedger(&HedgePolicy{Budget: 0.5})
+	for i := 0; i < hedgeMaxTokens; i++ {
+		require.True(t, h.spend())
+	}
+	require.False(t, h.spend())
+	h.earn()
+	require.False(t, h.spend())
+	h.earn()
+	require.True(t, h.spend())
+
+	// No budget means no limit.
+	h = newHedger(&HedgePolicy{})
+	for i := 0; i < 100; i++ {
+		require.True(t, h.spend())
+	}
+}
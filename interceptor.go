@@ -0,0 +1,28 @@
+package api2
+
+import "context"
+
+// Invoker makes the call of the route. req and res are pointers to the
+// request and response structs passed to Call.
+type Invoker func(ctx context.Context, route *Route, req, res any) error
+
+// Interceptor wraps calls made by Client. It can inspect and change the
+// context, the route and the request before calling next and inspect the
+// response and the error after it.
+type Interceptor func(ctx context.Context, route *Route, req, res any, next Invoker) error
+
+// chainInterceptors returns interceptor calling outer and then inner inside
+// it. Any of them can be nil.
+func chainInterceptors(outer, inner Interceptor) Interceptor {
+	if outer == nil {
+		return inner
+	}
+	if inner == nil {
+		return outer
+	}
+	return func(ctx context.Context, route *Route, req, res any, next Invoker) error {
+		return outer(ctx, route, req, res, func(ctx context.Context, route *Route, req, res any) error {
+			return inner(ctx, route, req, res, next)
+		})
+	}
+}
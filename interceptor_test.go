@@ -0,0 +1,34 @@
+package api2
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestChainInterceptors(t *testing.T) {
+	var log []string
+	newInterceptor := func(name string) Interceptor {
+		return func(ctx context.Context, route *Route, req, res any, next Invoker) error {
+			log = append(log, name+" before")
+			err := next(ctx, route, req, res)
+			log = append(log, name+" after")
+			return err
+		}
+	}
+	var i Interceptor
+	i = chainInterceptors(i, nil)
+	require.Nil(t, i)
+	i = chainInterceptors(i, newInterceptor("a"))
+	i = chainInterceptors(i, newInterceptor("b"))
+	i = chainInterceptors(i, nil)
+
+	route := &Route{Path: "/"}
+	err := i(context.Background(), route, nil, nil, func(ctx context.Context, route *Route, req, res any) error {
+		log = append(log, "call "+route.Path)
+		return nil
+	})
+	require.NoError(t, err)
+	require.Equal(t, []string{"a before", "b before", "call /", "b after", "a after"}, log)
+}
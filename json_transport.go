@@ -3,7 +3,6 @@ package api2
 import (
 	"bytes"
 	"context"
-	"encoding"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -45,6 +44,10 @@ type JsonTransport struct {
 	// separate JSON field ("detail") as well as its type (in JSON field
 	// "code"). Other errors are reduced to their messages.
 	Errors map[string]error
+
+	// Codecs used for query, header, cookie and url fields in addition to
+	// DefaultValueCodecs. See RegisterValueCodec.
+	ValueCodecs *ValueCodecs
 }
 
 type humanType struct{}
@@ -99,6 +102,13 @@ func (h *JsonTransport) allowLegacyBinaryProtobufFallback() bool {
 	return h.AllowLegacyBinaryProtobufWithJSONContentType
 }
 
+func (h *JsonTransport) valueCodecs() *ValueCodecs {
+	if h == nil || h.ValueCodecs == nil {
+		return DefaultValueCodecs
+	}
+	return h.ValueCodecs
+}
+
 func (h *JsonTransport) DecodeRequest(ctx context.Context, r *http.Request, req interface{}) (context.Context, error) {
 	if h.RequestDecoder != nil {
 		return h.RequestDecoder(ctx, r, req)
@@ -108,7 +118,7 @@ func (h *JsonTransport) DecodeRequest(ctx context.Context, r *http.Request, req
 		ctx = context.WithValue(ctx, requestContentTypeKey{}, ct)
 	}
 
-	actualContentType, err := readQueryHeaderCookie(h.allowLegacyBinaryProtobufFallback(), req, r.Body, r.URL.Query(), r, r.Header, 0)
+	actualContentType, err := readQueryHeaderCookie(h.allowLegacyBinaryProtobufFallback(), h.valueCodecs(), req, r.Body, r.URL.Query(), r, r.Header, 0)
 	if err != nil {
 		return ctx, err
 	}
@@ -124,7 +134,7 @@ func (h *JsonTransport) EncodeResponse(ctx context.Context, w http.ResponseWrite
 		return h.ResponseEncoder(ctx, w, res)
 	}
 
-	body, err := writeQueryHeaderCookie(ctx, w, res, nil, nil, w.Header(), hasHuman(ctx))
+	body, err := writeQueryHeaderCookie(ctx, w, res, nil, nil, w.Header(), hasHuman(ctx), h.valueCodecs())
 	if body != nil {
 		panic("unexpected body")
 	}
@@ -174,7 +184,7 @@ func (h *JsonTransport) EncodeRequest(ctx context.Context, method, urlStr string
 		human = humanValue.(bool)
 	}
 	var requestBodyBuffer bytes.Buffer
-	body, err := writeQueryHeaderCookie(ctx, &requestBodyBuffer, req, query, request, request.Header, human)
+	body, err := writeQueryHeaderCookie(ctx, &requestBodyBuffer, req, query, request, request.Header, human, h.valueCodecs())
 	if err != nil {
 		return nil, err
 	}
@@ -202,7 +212,7 @@ func (h *JsonTransport) DecodeResponse(ctx context.Context, res *http.Response,
 		return h.ResponseDecoder(ctx, res, response)
 	}
 
-	if _, err := readQueryHeaderCookie(h.allowLegacyBinaryProtobufFallback(), response, res.Body, nil, nil, res.Header, res.StatusCode); err != nil {
+	if _, err := readQueryHeaderCookie(h.allowLegacyBinaryProtobufFallback(), h.valueCodecs(), response, res.Body, nil, nil, res.Header, res.StatusCode); err != nil {
 		return err
 	}
 
@@ -306,13 +316,16 @@ func (h *JsonTransport) DecodeResponseAndError(ctx context.Context, httpRes *htt
 }
 
 type strMapping struct {
-	Field int
+	Field []int // Index sequence for reflect.Value.FieldByIndex.
 	Key   string
+	Codec *fieldCodec
 }
 
 type intMapping struct {
-	OrigField int
+	OrigField []int
 	JsonField int
+	Type      reflect.Type
+	Omit      func(v reflect.Value) bool // For omitempty and omitzero fields.
 }
 
 type preparedType struct {
@@ -322,11 +335,22 @@ type preparedType struct {
 	UrlMapping    []strMapping
 	JsonMapping   []intMapping
 	TypeForJson   reflect.Type
-	BodyField     int
-	StatusField   int
-	Protobuf      bool
-	Stream        bool
-	Raw           bool
+
+	// JsonView is a struct with pointers to JSON fields of the original
+	// struct having the same tags. JSON fields mixed with special fields are
+	// encoded and decoded through it without copying. It is nil if there are
+	// embedded structs, pointers or fields with tag options other than
+	// omitempty and omitzero among JSON fields: TypeForJson is used then.
+	JsonView  reflect.Type
+	JsonViews sync.Pool
+
+	// Embedded pointers to structs leading to JSON fields, parents first.
+	EmbeddedPtrs [][]int
+	BodyField    int
+	StatusField  int
+	Protobuf     bool
+	Stream       bool
+	Raw          bool
 
 	// Special fields are query, header, cookie and status.
 	NoJsonFields    bool
@@ -335,11 +359,180 @@ type preparedType struct {
 
 const noField = -1
 
+// embeddedStructType returns the type of the struct embedded in the field,
+// if the field is an embedded struct or a pointer to struct, which can be
+// flattened. Otherwise it returns nil.
+func embeddedStructType(field reflect.StructField) reflect.Type {
+	if !field.Anonymous || field.Tag.Get("json") != "" {
+		return nil
+	}
+	t := field.Type
+	if t.Kind() == reflect.Ptr {
+		if field.PkgPath != "" {
+			// Unexported pointer can not be allocated when decoding.
+			return nil
+		}
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+	return t
+}
+
+// hasNonJsonFields returns if the struct or structs embedded into it have
+// query, header, cookie or url fields.
+func hasNonJsonFields(structType reflect.Type, seen map[reflect.Type]bool) bool {
+	if seen[structType] {
+		return false
+	}
+	seen[structType] = true
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		if field.Tag.Get("query") != "" || field.Tag.Get("header") != "" || field.Tag.Get("cookie") != "" || field.Tag.Get("url") != "" {
+			return true
+		}
+		if embedded := embeddedStructType(field); embedded != nil && hasNonJsonFields(embedded, seen) {
+			return true
+		}
+	}
+	return false
+}
+
+// flattenFields returns fields of the struct. Embedded structs (and pointers
+// to structs) having query, header, cookie or url fields are replaced with
+// their fields. Such fields have Index relative to structType.
+// Other embedded structs are left as is and are handled by JSON encoder.
+// JSON fields hidden according to the rules of encoding/json are dropped.
+func flattenFields(structType reflect.Type) []reflect.StructField {
+	return dropHiddenJsonFields(collectFields(structType))
+}
+
+func collectFields(structType reflect.Type) []reflect.StructField {
+	fields := make([]reflect.StructField, 0, structType.NumField())
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		embedded := embeddedStructType(field)
+		if embedded == nil || !hasNonJsonFields(embedded, map[reflect.Type]bool{structType: true}) {
+			fields = append(fields, field)
+			continue
+		}
+		for _, subfield := range collectFields(embedded) {
+			subfield.Index = append([]int{i}, subfield.Index...)
+			fields = append(fields, subfield)
+		}
+	}
+	return fields
+}
+
+// jsonFieldName returns the name of the field in JSON and if the name comes
+// from the json tag. It returns false if the field is not encoded as a JSON
+// field of the object.
+func jsonFieldName(field reflect.StructField) (name string, tagged, ok bool) {
+	if field.PkgPath != "" || embeddedStructType(field) != nil {
+		return "", false, false
+	}
+	for _, key := range []string{"query", "header", "cookie", "url"} {
+		if field.Tag.Get(key) != "" {
+			return "", false, false
+		}
+	}
+	if field.Tag.Get("use_as_body") == "true" || field.Tag.Get("use_as_status") == "true" {
+		return "", false, false
+	}
+	tag := field.Tag.Get("json")
+	if tag == "-" {
+		return "", false, false
+	}
+	name, _, _ = strings.Cut(tag, ",")
+	if name == "" {
+		return field.Name, false, true
+	}
+	return name, true, true
+}
+
+// dropHiddenJsonFields removes JSON fields of flattened embedded structs
+// which encoding/json ignores: of the fields having the same JSON name only
+// the shallowest one is used. If there are several of them, the only tagged
+// one is used, otherwise the name is ambiguous and all of them are dropped.
+func dropHiddenJsonFields(fields []reflect.StructField) []reflect.StructField {
+	name2indices := make(map[string][]int)
+	for i, field := range fields {
+		if name, _, ok := jsonFieldName(field); ok {
+			name2indices[name] = append(name2indices[name], i)
+		}
+	}
+	drop := make(map[int]bool)
+	for _, indices := range name2indices {
+		if len(indices) == 1 {
+			continue
+		}
+		minDepth := len(fields[indices[0]].Index)
+		for _, i := range indices {
+			minDepth = min(minDepth, len(fields[i].Index))
+		}
+		dominant, tagged := -1, -1
+		count, taggedCount := 0, 0
+		for _, i := range indices {
+			if len(fields[i].Index) != minDepth {
+				continue
+			}
+			dominant = i
+			count++
+			if _, isTagged, _ := jsonFieldName(fields[i]); isTagged {
+				tagged = i
+				taggedCount++
+			}
+		}
+		if count != 1 {
+			dominant = -1
+			if taggedCount == 1 {
+				dominant = tagged
+			}
+		}
+		for _, i := range indices {
+			if i != dominant {
+				drop[i] = true
+			}
+		}
+	}
+	if len(drop) == 0 {
+		return fields
+	}
+	result := make([]reflect.StructField, 0, len(fields)-len(drop))
+	for i, field := range fields {
+		if !drop[i] {
+			result = append(result, field)
+		}
+	}
+	return result
+}
+
+// fieldByIndex returns nested field of the struct. If the path goes through
+// nil embedded pointer, it is allocated if alloc is true, otherwise false
+// is returned.
+func fieldByIndex(objValue reflect.Value, index []int, alloc bool) (reflect.Value, bool) {
+	v := objValue
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				if !alloc {
+					return reflect.Value{}, false
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
 func prepare(objType reflect.Type) *preparedType {
 	p := &preparedType{BodyField: noField, StatusField: noField}
-	jsonFields := make([]reflect.StructField, 0, objType.NumField())
-	for i := 0; i < objType.NumField(); i++ {
-		field := objType.Field(i)
+	fields := flattenFields(objType)
+	jsonFields := make([]reflect.StructField, 0, len(fields))
+	for _, field := range fields {
 		if field.PkgPath != "" {
 			// The field is unexported, according to https://golang.org/pkg/reflect/#StructField .
 			continue
@@ -350,6 +543,9 @@ func prepare(objType reflect.Type) *preparedType {
 		urlKey := field.Tag.Get("url")
 		isBodyField := field.Tag.Get("use_as_body") == "true"
 		isStatusField := field.Tag.Get("use_as_status") == "true"
+		if (isBodyField || isStatusField) && len(field.Index) != 1 {
+			panic(fmt.Sprintf("field %s of struct %s: use_as_body and use_as_status can not be used in embedded structs", field.Name, objType.Name()))
+		}
 		if isBodyField {
 			p.Protobuf = field.Tag.Get("is_protobuf") == "true"
 			p.Stream = field.Tag.Get("is_stream") == "true"
@@ -357,46 +553,58 @@ func prepare(objType reflect.Type) *preparedType {
 		}
 		if queryKey != "" {
 			p.QueryMapping = append(p.QueryMapping, strMapping{
-				Field: i,
+				Field: field.Index,
 				Key:   queryKey,
+				Codec: compileFieldCodec(field.Type),
 			})
 		} else if headerKey != "" {
 			p.HeaderMapping = append(p.HeaderMapping, strMapping{
-				Field: i,
+				Field: field.Index,
 				Key:   headerKey,
+				Codec: compileFieldCodec(field.Type),
 			})
 		} else if cookieKey != "" {
 			p.CookieMapping = append(p.CookieMapping, strMapping{
-				Field: i,
+				Field: field.Index,
 				Key:   cookieKey,
+				Codec: compileFieldCodec(field.Type),
 			})
 		} else if urlKey != "" {
 			p.UrlMapping = append(p.UrlMapping, strMapping{
-				Field: i,
+				Field: field.Index,
 				Key:   urlKey,
+				Codec: compileFieldCodec(field.Type),
 			})
 		} else if isBodyField {
-			p.BodyField = i
+			p.BodyField = field.Index[0]
 		} else if isStatusField {
-			p.StatusField = i
+			p.StatusField = field.Index[0]
 		} else {
-			// Add to JSON.
+			// Add to JSON. Fields of different embedded structs may have
+			// the same Go name and different JSON names.
+			if !field.Anonymous {
+				field.Name = uniqueFieldName(field.Name, jsonFields)
+			}
 			p.JsonMapping = append(p.JsonMapping, intMapping{
-				OrigField: i,
+				OrigField: field.Index,
 				JsonField: len(jsonFields),
+				Type:      field.Type,
+				Omit:      jsonOmitFunc(field),
 			})
 			jsonFields = append(jsonFields, field)
 		}
 	}
 	if p.BodyField == noField {
 		p.TypeForJson = reflect.StructOf(jsonFields)
+		p.JsonView = jsonViewType(jsonFields)
+		p.EmbeddedPtrs = embeddedPtrs(objType, p.JsonMapping)
 	}
 
 	statusFields := 0
 	if p.StatusField != noField {
 		statusFields = 1
 	}
-	if len(p.QueryMapping)+len(p.HeaderMapping)+len(p.CookieMapping)+len(p.UrlMapping)+statusFields == objType.NumField() {
+	if len(p.QueryMapping)+len(p.HeaderMapping)+len(p.CookieMapping)+len(p.UrlMapping)+statusFields == len(fields) {
 		p.NoJsonFields = true
 	}
 	if len(p.QueryMapping) == 0 && len(p.HeaderMapping) == 0 && len(p.CookieMapping) == 0 && len(p.UrlMapping) == 0 && p.StatusField == noField {
@@ -405,51 +613,193 @@ func prepare(objType reflect.Type) *preparedType {
 	return p
 }
 
+// uniqueFieldName returns name if it is not used by the fields, otherwise it
+// adds a number to it. Names of fields of TypeForJson must be unique.
+func uniqueFieldName(name string, fields []reflect.StructField) string {
+	used := func(name string) bool {
+		for _, field := range fields {
+			if field.Name == name {
+				return true
+			}
+		}
+		return false
+	}
+	unique := name
+	for i := 2; used(unique); i++ {
+		unique = fmt.Sprintf("%s%d", name, i)
+	}
+	return unique
+}
+
 var prepared sync.Map
 
-func toString(obj interface{}) (string, error) {
-	if marshaler, ok := obj.(encoding.TextMarshaler); ok {
-		valueBytes, err := marshaler.MarshalText()
-		if err != nil {
-			return "", err
+func getPrepared(objType reflect.Type) *preparedType {
+	p, has := prepared.Load(objType)
+	if !has {
+		p, _ = prepared.LoadOrStore(objType, prepare(objType))
+	}
+	return p.(*preparedType)
+}
+
+// jsonViewType returns the type of a struct with pointers to the fields
+// or nil if some field can not be accessed through a pointer.
+func jsonViewType(jsonFields []reflect.StructField) reflect.Type {
+	viewFields := make([]reflect.StructField, len(jsonFields))
+	for i, field := range jsonFields {
+		if field.Anonymous {
+			return nil
+		}
+		// encoding/json removes only one level of pointers for option
+		// "string" and decodes null into a pointer field by setting it to
+		// nil, not the field through a pointer to it.
+		if field.Type.Kind() == reflect.Pointer || !onlyOmitOptions(field.Tag) {
+			return nil
+		}
+		viewFields[i] = reflect.StructField{
+			Name: field.Name,
+			Type: reflect.PointerTo(field.Type),
+			Tag:  field.Tag,
 		}
-		return string(valueBytes), nil
-	} else {
-		return fmt.Sprintf("%v", obj), nil
 	}
+	return reflect.StructOf(viewFields)
 }
 
-func fromString(objPtr interface{}, value string) error {
-	if unmarshaler, ok := objPtr.(encoding.TextUnmarshaler); ok {
-		return unmarshaler.UnmarshalText([]byte(value))
-	} else if fieldStrPtr, ok := objPtr.(*string); ok {
-		*fieldStrPtr = value
-		return nil
-	} else if value == "" {
-		return nil
-	} else {
-		_, err := fmt.Sscanf(value, "%v", objPtr)
-		return err
+// onlyOmitOptions returns if the json tag has no options other than
+// omitempty and omitzero.
+func onlyOmitOptions(tag reflect.StructTag) bool {
+	_, opts, _ := strings.Cut(tag.Get("json"), ",")
+	for opts != "" {
+		var opt string
+		opt, opts, _ = strings.Cut(opts, ",")
+		if opt != "omitempty" && opt != "omitzero" {
+			return false
+		}
+	}
+	return true
+}
+
+type isZeroer interface {
+	IsZero() bool
+}
+
+var isZeroerType = reflect.TypeOf((*isZeroer)(nil)).Elem()
+
+// jsonOmitFunc returns a function telling if the value of the field is
+// omitted by encoding/json because of "omitempty" or "omitzero" options.
+// It returns nil if the field is never omitted.
+func jsonOmitFunc(field reflect.StructField) func(v reflect.Value) bool {
+	_, opts, _ := strings.Cut(field.Tag.Get("json"), ",")
+	var omitEmpty, omitZero bool
+	for opts != "" {
+		var opt string
+		opt, opts, _ = strings.Cut(opts, ",")
+		switch opt {
+		case "omitempty":
+			omitEmpty = true
+		case "omitzero":
+			omitZero = true
+		}
+	}
+	isZero := jsonIsZeroFunc(field.Type)
+	switch {
+	case omitEmpty && omitZero:
+		return func(v reflect.Value) bool {
+			return isEmptyJsonValue(v) || isZero(v)
+		}
+	case omitEmpty:
+		return isEmptyJsonValue
+	case omitZero:
+		return isZero
+	}
+	return nil
+}
+
+// isEmptyJsonValue is the same as isEmptyValue of encoding/json.
+func isEmptyJsonValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64,
+		reflect.Interface, reflect.Pointer:
+		return v.IsZero()
+	}
+	return false
+}
+
+// jsonIsZeroFunc returns the function used by encoding/json for "omitzero".
+func jsonIsZeroFunc(t reflect.Type) func(v reflect.Value) bool {
+	switch {
+	case t.Kind() == reflect.Interface && t.Implements(isZeroerType):
+		return func(v reflect.Value) bool {
+			return v.IsNil() ||
+				(v.Elem().Kind() == reflect.Pointer && v.Elem().IsNil()) ||
+				v.Interface().(isZeroer).IsZero()
+		}
+	case t.Kind() == reflect.Pointer && t.Implements(isZeroerType):
+		return func(v reflect.Value) bool {
+			return v.IsNil() || v.Interface().(isZeroer).IsZero()
+		}
+	case t.Implements(isZeroerType):
+		return func(v reflect.Value) bool {
+			return v.Interface().(isZeroer).IsZero()
+		}
+	case reflect.PointerTo(t).Implements(isZeroerType):
+		return func(v reflect.Value) bool {
+			return v.Addr().Interface().(isZeroer).IsZero()
+		}
+	}
+	return reflect.Value.IsZero
+}
+
+// embeddedPtrs returns the paths of embedded pointers to structs which must
+// be allocated to reach the JSON fields. Parents go before children.
+func embeddedPtrs(objType reflect.Type, jsonMapping []intMapping) [][]int {
+	var paths [][]int
+	seen := make(map[string]bool)
+	for _, m := range jsonMapping {
+		for end := 1; end < len(m.OrigField); end++ {
+			path := m.OrigField[:end]
+			if objType.FieldByIndex(path).Type.Kind() != reflect.Ptr {
+				continue
+			}
+			key := fmt.Sprint(path)
+			if !seen[key] {
+				seen[key] = true
+				paths = append(paths, path)
+			}
+		}
+	}
+	return paths
+}
+
+func (p *preparedType) getJsonView() reflect.Value {
+	if view := p.JsonViews.Get(); view != nil {
+		return reflect.ValueOf(view)
 	}
+	return reflect.New(p.JsonView)
+}
+
+func (p *preparedType) putJsonView(view reflect.Value) {
+	// Do not keep references to objects of the user in the pool.
+	view.Elem().SetZero()
+	p.JsonViews.Put(view.Interface())
 }
 
 type headerWriter interface {
 	WriteHeader(statusCode int)
 }
 
-func writeQueryHeaderCookie(ctx context.Context, w io.Writer, objPtr interface{}, query url.Values, request *http.Request, header http.Header, human bool) (io.ReadCloser, error) {
+func writeQueryHeaderCookie(ctx context.Context, w io.Writer, objPtr interface{}, query url.Values, request *http.Request, header http.Header, human bool, codecs *ValueCodecs) (io.ReadCloser, error) {
 	header.Set("Content-Type", "application/json; charset=UTF-8")
 	if request != nil {
 		request.Header.Set("Accept", "application/json")
 	}
 
 	objType := reflect.TypeOf(objPtr).Elem()
-	p0, has := prepared.Load(objType)
-	if !has {
-		p0 = prepare(objType)
-		prepared.Store(objType, p0)
-	}
-	p := p0.(*preparedType)
+	p := getPrepared(objType)
 
 	objValue := reflect.ValueOf(objPtr).Elem()
 
@@ -467,42 +817,74 @@ func writeQueryHeaderCookie(ctx context.Context, w io.Writer, objPtr interface{}
 	} else if p.NoSpecialFields {
 		// Returning the original object.
 		bodyPtr = objPtr
+	} else if p.JsonView != nil {
+		// JSON fields mixed with header and/or query fields.
+		// Encode the view pointing to JSON fields of the original struct.
+		view := p.getJsonView()
+		defer p.putJsonView(view)
+		viewValue := view.Elem()
+		for _, m := range p.JsonMapping {
+			fieldValue, ok := fieldByIndex(objValue, m.OrigField, false)
+			if !ok {
+				// Embedded pointer is nil, use zero value.
+				fieldValue = reflect.New(m.Type).Elem()
+			}
+			if m.Omit != nil && m.Omit(fieldValue) {
+				// Nil pointer is omitted by encoding/json.
+				continue
+			}
+			viewValue.Field(m.JsonField).Set(fieldValue.Addr())
+		}
+		bodyPtr = view.Interface()
 	} else {
 		// JSON fields mixed with header and/or query fields.
 		forJson := reflect.New(p.TypeForJson).Elem()
 		for _, m := range p.JsonMapping {
-			forJson.Field(m.JsonField).Set(objValue.Field(m.OrigField))
+			if fieldValue, ok := fieldByIndex(objValue, m.OrigField, false); ok {
+				forJson.Field(m.JsonField).Set(fieldValue)
+			}
 		}
 		bodyPtr = forJson.Addr().Interface()
 	}
 
 	for _, m := range p.QueryMapping {
-		value, err := toString(objValue.Field(m.Field).Interface())
+		fieldValue, ok := fieldByIndex(objValue, m.Field, false)
+		if !ok {
+			continue
+		}
+		value, err := codecs.encode(m.Codec, fieldValue)
 		if err != nil {
-			field := objType.Field(m.Field)
+			field := objType.FieldByIndex(m.Field)
 			return nil, fmt.Errorf("failed to marshal value for field %s: %w", field.Name, err)
 		}
 		query.Set(m.Key, value)
 	}
 	for _, m := range p.HeaderMapping {
-		value, err := toString(objValue.Field(m.Field).Interface())
+		fieldValue, ok := fieldByIndex(objValue, m.Field, false)
+		if !ok {
+			continue
+		}
+		value, err := codecs.encode(m.Codec, fieldValue)
 		if err != nil {
-			field := objType.Field(m.Field)
+			field := objType.FieldByIndex(m.Field)
 			return nil, fmt.Errorf("failed to marshal value for field %s: %w", field.Name, err)
 		}
 		header.Set(m.Key, value)
 	}
 	for _, m := range p.CookieMapping {
-		valueInterface := objValue.Field(m.Field).Interface()
+		fieldValue, ok := fieldByIndex(objValue, m.Field, false)
+		if !ok {
+			continue
+		}
 		if request != nil {
-			value, err := toString(valueInterface)
+			value, err := codecs.encode(m.Codec, fieldValue)
 			if err != nil {
-				field := objType.Field(m.Field)
+				field := objType.FieldByIndex(m.Field)
 				return nil, fmt.Errorf("failed to marshal value for field %s: %w", field.Name, err)
 			}
 			request.AddCookie(&http.Cookie{Name: m.Key, Value: value})
 		} else {
-			cookie := valueInterface.(http.Cookie)
+			cookie := fieldValue.Interface().(http.Cookie)
 			if cookie.Name == "" {
 				cookie.Name = m.Key
 			}
@@ -520,10 +902,14 @@ func writeQueryHeaderCookie(ctx context.Context, w io.Writer, objPtr interface{}
 		}
 		param2value := make(map[string]string, len(p.UrlMapping))
 		for _, m := range p.UrlMapping {
-			valueInterface := objValue.Field(m.Field).Interface()
-			value, err := toString(valueInterface)
+			fieldValue, ok := fieldByIndex(objValue, m.Field, false)
+			if !ok {
+				// Embedded pointer is nil, use zero value.
+				fieldValue = reflect.Zero(objType.FieldByIndex(m.Field).Type)
+			}
+			value, err := codecs.encode(m.Codec, fieldValue)
 			if err != nil {
-				field := objType.Field(m.Field)
+				field := objType.FieldByIndex(m.Field)
 				return nil, fmt.Errorf("failed to marshal value for field %s: %w", field.Name, err)
 			}
 			param2value[m.Key] = value
@@ -603,14 +989,9 @@ func writeQueryHeaderCookie(ctx context.Context, w io.Writer, objPtr interface{}
 	}
 }
 
-func readQueryHeaderCookie(allowLegacyBinaryFallback bool, objPtr interface{}, bodyReadCloser io.ReadCloser, query url.Values, request *http.Request, header http.Header, status int) (string, error) {
+func readQueryHeaderCookie(allowLegacyBinaryFallback bool, codecs *ValueCodecs, objPtr interface{}, bodyReadCloser io.ReadCloser, query url.Values, request *http.Request, header http.Header, status int) (string, error) {
 	objType := reflect.TypeOf(objPtr).Elem()
-	p0, has := prepared.Load(objType)
-	if !has {
-		p0 = prepare(objType)
-		prepared.Store(objType, p0)
-	}
-	p := p0.(*preparedType)
+	p := getPrepared(objType)
 	actualRequestContentType := ""
 
 	objValue := reflect.ValueOf(objPtr).Elem()
@@ -669,6 +1050,38 @@ func readQueryHeaderCookie(allowLegacyBinaryFallback bool, objPtr interface{}, b
 		if err := json.NewDecoder(bodyReadCloser).Decode(objPtr); err != nil {
 			return "", err
 		}
+	} else if p.JsonView != nil {
+		// JSON fields mixed with header and/or query fields.
+		// Parse JSON into the view pointing to fields of the original struct.
+		var wasNil []bool
+		if len(p.EmbeddedPtrs) != 0 {
+			wasNil = make([]bool, len(p.EmbeddedPtrs))
+			for i, path := range p.EmbeddedPtrs {
+				ptr, ok := fieldByIndex(objValue, path, false)
+				wasNil[i] = !ok || ptr.IsNil()
+			}
+		}
+		view := p.getJsonView()
+		viewValue := view.Elem()
+		for _, m := range p.JsonMapping {
+			fieldValue, _ := fieldByIndex(objValue, m.OrigField, true)
+			viewValue.Field(m.JsonField).Set(fieldValue.Addr())
+		}
+		err := json.NewDecoder(bodyReadCloser).Decode(view.Interface())
+		p.putJsonView(view)
+		if err != nil {
+			return "", err
+		}
+		// Free embedded structs allocated for nothing, children first.
+		for i := len(p.EmbeddedPtrs) - 1; i >= 0; i-- {
+			if !wasNil[i] {
+				continue
+			}
+			ptr, _ := fieldByIndex(objValue, p.EmbeddedPtrs[i], false)
+			if ptr.Elem().IsZero() {
+				ptr.SetZero()
+			}
+		}
 	} else {
 		// JSON fields mixed with header and/or query fields.
 		// Parse JSON into a temporary struct and copy fields into the original struct.
@@ -678,7 +1091,10 @@ func readQueryHeaderCookie(allowLegacyBinaryFallback bool, objPtr interface{}, b
 		}
 		jsonValue := jsonPtrValue.Elem()
 		for _, m := range p.JsonMapping {
-			objValue.Field(m.OrigField).Set(jsonValue.Field(m.JsonField))
+			value := jsonValue.Field(m.JsonField)
+			if fieldValue, ok := fieldByIndex(objValue, m.OrigField, !value.IsZero()); ok {
+				fieldValue.Set(value)
+			}
 		}
 	}
 
@@ -690,19 +1106,25 @@ func readQueryHeaderCookie(allowLegacyBinaryFallback bool, objPtr interface{}, b
 	}
 
 	for _, m := range p.QueryMapping {
-		fieldPtr := objValue.Field(m.Field).Addr().Interface()
 		value := query.Get(m.Key)
-		if err := fromString(fieldPtr, value); err != nil {
-			field := objType.Field(m.Field)
+		fieldValue, ok := fieldByIndex(objValue, m.Field, value != "")
+		if !ok {
+			continue
+		}
+		if err := codecs.decode(m.Codec, value, fieldValue); err != nil {
+			field := objType.FieldByIndex(m.Field)
 			return "", fmt.Errorf("failed to parse value %q from query key %s for field %s: %w", value, m.Key, field.Name, err)
 		}
 	}
 
 	for _, m := range p.HeaderMapping {
-		fieldPtr := objValue.Field(m.Field).Addr().Interface()
 		value := header.Get(m.Key)
-		if err := fromString(fieldPtr, value); err != nil {
-			field := objType.Field(m.Field)
+		fieldValue, ok := fieldByIndex(objValue, m.Field, value != "")
+		if !ok {
+			continue
+		}
+		if err := codecs.decode(m.Codec, value, fieldValue); err != nil {
+			field := objType.FieldByIndex(m.Field)
 			return "", fmt.Errorf("failed to parse value %q from header key %s for field %s: %w", value, m.Key, field.Name, err)
 		}
 	}
@@ -716,21 +1138,25 @@ func readQueryHeaderCookie(allowLegacyBinaryFallback bool, objPtr interface{}, b
 		}
 	}
 	for _, m := range p.CookieMapping {
-		fieldPtr := objValue.Field(m.Field).Addr().Interface()
 		if request != nil {
 			c, err := request.Cookie(m.Key)
 			value := ""
 			if err != http.ErrNoCookie {
 				value = c.Value
 			}
-			if err := fromString(fieldPtr, value); err != nil {
-				field := objType.Field(m.Field)
+			fieldValue, ok := fieldByIndex(objValue, m.Field, value != "")
+			if !ok {
+				continue
+			}
+			if err := codecs.decode(m.Codec, value, fieldValue); err != nil {
+				field := objType.FieldByIndex(m.Field)
 				return "", fmt.Errorf("failed to parse value %q from cookie key %s for field %s: %w", value, m.Key, field.Name, err)
 			}
 		} else {
 			c, has := name2cookie[m.Key]
 			if has {
-				cookiePtr := fieldPtr.(*http.Cookie)
+				fieldValue, _ := fieldByIndex(objValue, m.Field, true)
+				cookiePtr := fieldValue.Addr().Interface().(*http.Cookie)
 				*cookiePtr = *c
 			}
 		}
@@ -740,16 +1166,22 @@ func readQueryHeaderCookie(allowLegacyBinaryFallback bool, objPtr interface{}, b
 		if request == nil {
 			return "", fmt.Errorf("must specify request to set URL parameters")
 		}
-		param2valueValue := request.Context().Value(paramMapType{})
-		if param2valueValue == nil {
-			return "", fmt.Errorf("param2value map is not attached to ctx")
-		}
-		param2value := param2valueValue.(map[string]string)
+		// The map is attached by the router of BindRoutes. If it is missing,
+		// the route was registered as a native http.ServeMux pattern.
+		param2value, hasMap := request.Context().Value(paramMapType{}).(map[string]string)
 		for _, m := range p.UrlMapping {
-			fieldPtr := objValue.Field(m.Field).Addr().Interface()
-			value := param2value[m.Key]
-			if err := fromString(fieldPtr, value); err != nil {
-				field := objType.Field(m.Field)
+			var value string
+			if hasMap {
+				value = param2value[m.Key]
+			} else {
+				value = request.PathValue(m.Key)
+			}
+			fieldValue, ok := fieldByIndex(objValue, m.Field, value != "")
+			if !ok {
+				continue
+			}
+			if err := codecs.decode(m.Codec, value, fieldValue); err != nil {
+				field := objType.FieldByIndex(m.Field)
 				return "", fmt.Errorf("failed to parse value %q from URL parameter :%s for field %s: %w", value, m.Key, field.Name, err)
 			}
 		}
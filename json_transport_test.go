@@ -35,6 +35,44 @@ func (c *CustomType) UnmarshalText(text []byte) error {
 	return nil
 }
 
+type Pagination struct {
+	Limit  int    `query:"limit"`
+	Cursor string `query:"cursor"`
+}
+
+type AuthHeaders struct {
+	Token string `header:"X-Token"`
+}
+
+type JsonPart struct {
+	Name string `json:"name"`
+}
+
+type NamedPage struct {
+	Page int `query:"page"`
+	Name string
+}
+
+type NamedAuth struct {
+	Token string `header:"X-Token"`
+	Name  string
+}
+
+type TitledPage struct {
+	Page  int `query:"page"`
+	Title string
+}
+
+type TitledAuth struct {
+	Token string `header:"X-Token"`
+	Name  string `json:"title"`
+}
+
+type PageWithTotal struct {
+	Limit int `query:"limit"`
+	Total int `json:"total"`
+}
+
 func TestQueryAndHeader(t *testing.T) {
 	type Anon struct {
 		Foo string `json:"foo"`
@@ -657,6 +695,178 @@ func TestQueryAndHeader(t *testing.T) {
 			request:   false,
 			cmpToWant: true,
 		},
+
+		{
+			objPtr: &struct {
+				Pagination
+				AuthHeaders
+				Foo string `json:"foo"`
+			}{
+				Pagination:  Pagination{Limit: 10, Cursor: "abc"},
+				AuthHeaders: AuthHeaders{Token: "secret"},
+				Foo:         "foo!",
+			},
+			query:    true,
+			wantBody: `{"foo":"foo!"}`,
+			wantQuery: map[string][]string{
+				"limit":  []string{"10"},
+				"cursor": []string{"abc"},
+			},
+			wantHeader: map[string][]string{
+				"X-Token": []string{"secret"},
+			},
+		},
+		{
+			objPtr: &struct {
+				*Pagination
+				Foo string `json:"foo"`
+			}{
+				Pagination: &Pagination{Limit: 10},
+				Foo:        "foo!",
+			},
+			query:    true,
+			wantBody: `{"foo":"foo!"}`,
+			wantQuery: map[string][]string{
+				"limit":  []string{"10"},
+				"cursor": []string{""},
+			},
+		},
+		{
+			objPtr: &struct {
+				*Pagination
+				Foo string `json:"foo"`
+			}{
+				Foo: "foo!",
+			},
+			query:     true,
+			wantBody:  `{"foo":"foo!"}`,
+			wantQuery: map[string][]string{},
+		},
+		{
+			objPtr: &struct {
+				Pagination
+				JsonPart
+			}{
+				Pagination: Pagination{Limit: 10},
+				JsonPart:   JsonPart{Name: "name!"},
+			},
+			query:    true,
+			wantBody: `{"name":"name!"}`,
+			wantQuery: map[string][]string{
+				"limit":  []string{"10"},
+				"cursor": []string{""},
+			},
+		},
+
+		// Ambiguous JSON fields are dropped as by encoding/json.
+		{
+			objPtr: &struct {
+				NamedPage
+				NamedAuth
+				Foo string `json:"foo"`
+			}{
+				NamedPage: NamedPage{Page: 2},
+				NamedAuth: NamedAuth{Token: "secret"},
+				Foo:       "foo!",
+			},
+			query:    true,
+			wantBody: `{"foo":"foo!"}`,
+			wantQuery: map[string][]string{
+				"page": []string{"2"},
+			},
+			wantHeader: map[string][]string{
+				"X-Token": []string{"secret"},
+			},
+		},
+		// The shallower field wins.
+		{
+			objPtr: &struct {
+				TitledPage
+				Title string
+			}{
+				TitledPage: TitledPage{Page: 2},
+				Title:      "top",
+			},
+			query:    true,
+			wantBody: `{"Title":"top"}`,
+			wantQuery: map[string][]string{
+				"page": []string{"2"},
+			},
+		},
+		// Same Go names, different JSON names.
+		{
+			objPtr: &struct {
+				NamedPage
+				TitledAuth
+			}{
+				NamedPage:  NamedPage{Page: 2, Name: "name!"},
+				TitledAuth: TitledAuth{Token: "secret", Name: "title!"},
+			},
+			query:    true,
+			wantBody: `{"Name":"name!","title":"title!"}`,
+			wantQuery: map[string][]string{
+				"page": []string{"2"},
+			},
+		},
+		{
+			objPtr: &struct {
+				Token   string    `header:"X-Token"`
+				Empty   int       `json:"empty,omitempty"`
+				Name    string    `json:"name,omitempty"`
+				Time    time.Time `json:"time,omitzero"`
+				Number  int       `json:"number,string"`
+				Skipped string    `json:"-"`
+				Ptr     *int      `json:"ptr"`
+				List    []int     `json:"list,omitempty"`
+			}{
+				Token:  "secret",
+				Name:   "name!",
+				Number: 5,
+			},
+			wantBody: `{"name":"name!","number":"5","ptr":null}`,
+			wantHeader: map[string][]string{
+				"X-Token": []string{"secret"},
+			},
+		},
+		{
+			// Option "string" of a pointer field.
+			objPtr: &struct {
+				Token string `header:"X-Token"`
+				Count *int64 `json:"count,string"`
+			}{
+				Token: "secret",
+				Count: int64Ptr(5),
+			},
+			wantBody: `{"count":"5"}`,
+			wantHeader: map[string][]string{
+				"X-Token": []string{"secret"},
+			},
+		},
+		{
+			objPtr: &struct {
+				*PageWithTotal
+				Foo string `json:"foo"`
+			}{
+				PageWithTotal: &PageWithTotal{Limit: 10, Total: 100},
+				Foo:           "foo!",
+			},
+			query:    true,
+			wantBody: `{"total":100,"foo":"foo!"}`,
+			wantQuery: map[string][]string{
+				"limit": []string{"10"},
+			},
+		},
+		{
+			objPtr: &struct {
+				*PageWithTotal
+				Foo string `json:"foo"`
+			}{
+				Foo: "foo!",
+			},
+			query:     true,
+			wantBody:  `{"total":0,"foo":"foo!"}`,
+			wantQuery: map[string][]string{},
+		},
 	}
 
 	for i, tc := range cases {
@@ -676,7 +886,7 @@ func TestQueryAndHeader(t *testing.T) {
 		var gotStatus int
 		getBody := func(objPtr interface{}) ([]byte, error) {
 			bodyBuffer := httptest.NewRecorder()
-			bodyReadCloser, err := writeQueryHeaderCookie(context.Background(), bodyBuffer, objPtr, query, request, header, false)
+			bodyReadCloser, err := writeQueryHeaderCookie(context.Background(), bodyBuffer, objPtr, query, request, header, false, nil)
 			if err != nil {
 				return nil, fmt.Errorf("writeQueryHeaderCookie failed: %w", err)
 			}
@@ -731,7 +941,7 @@ func TestQueryAndHeader(t *testing.T) {
 
 		objPtr2 := reflect.New(reflect.TypeOf(tc.objPtr).Elem()).Interface()
 		bodyReadCloser2 := io.NopCloser(bytes.NewReader(bodyBytes))
-		if _, err := readQueryHeaderCookie(false, objPtr2, bodyReadCloser2, query, request, header, gotStatus); err != nil {
+		if _, err := readQueryHeaderCookie(false, nil, objPtr2, bodyReadCloser2, query, request, header, gotStatus); err != nil {
 			t.Errorf("case %d: readQueryHeaderCookie failed: %v", i, err)
 		}
 
@@ -775,6 +985,31 @@ func TestQueryAndHeader(t *testing.T) {
 	}
 }
 
+func int64Ptr(v int64) *int64 {
+	return &v
+}
+
+func TestReadQueryHeaderCookiePointerFields(t *testing.T) {
+	type Request struct {
+		Token string `header:"X-Token"`
+		Count *int64 `json:"count,string"`
+		Next  *int64 `json:"next"`
+	}
+
+	req := &Request{Count: int64Ptr(1), Next: int64Ptr(2)}
+	body := io.NopCloser(bytes.NewReader([]byte(`{"count":"9","next":null}`)))
+	if _, err := readQueryHeaderCookie(false, nil, req, body, nil, nil, http.Header{}, 0); err != nil {
+		t.Fatalf("readQueryHeaderCookie failed: %v", err)
+	}
+	if req.Count == nil || *req.Count != 9 {
+		t.Errorf("want Count 9, got %v", req.Count)
+	}
+	// null clears the pointer.
+	if req.Next != nil {
+		t.Errorf("want Next nil, got %d", *req.Next)
+	}
+}
+
 func TestReadQueryHeaderCookieFallsBackToBinaryProtobuf(t *testing.T) {
 	type protobufBody struct {
 		Body *timestamppb.Timestamp `use_as_body:"true" is_protobuf:"true"`
@@ -793,7 +1028,7 @@ func TestReadQueryHeaderCookieFallsBackToBinaryProtobuf(t *testing.T) {
 	request.Header.Set("Content-Type", "application/json")
 
 	got := &protobufBody{}
-	actualContentType, err := readQueryHeaderCookie(true, got, io.NopCloser(bytes.NewReader(body)), nil, request, request.Header, http.StatusOK)
+	actualContentType, err := readQueryHeaderCookie(true, nil, got, io.NopCloser(bytes.NewReader(body)), nil, request, request.Header, http.StatusOK)
 	if err != nil {
 		t.Fatalf("readQueryHeaderCookie failed: %v", err)
 	}
@@ -822,7 +1057,7 @@ func TestReadQueryHeaderCookieRejectsMislabeledBinaryProtobufInStrictMode(t *tes
 	request.Header.Set("Content-Type", "application/json")
 
 	got := &protobufBody{}
-	if _, err := readQueryHeaderCookie(false, got, io.NopCloser(bytes.NewReader(body)), nil, request, request.Header, http.StatusOK); err == nil {
+	if _, err := readQueryHeaderCookie(false, nil, got, io.NopCloser(bytes.NewReader(body)), nil, request, request.Header, http.StatusOK); err == nil {
 		t.Fatal("readQueryHeaderCookie unexpectedly succeeded in strict mode")
 	}
 }
@@ -928,7 +1163,7 @@ func TestRQHCRejectsEmptyJSONContentTypeBodyInLenient(t *testing.T) {
 	request.Header.Set("Content-Type", "application/json")
 
 	got := &protobufBody{}
-	if _, err := readQueryHeaderCookie(true, got, io.NopCloser(bytes.NewReader(nil)), nil, request, request.Header, http.StatusOK); err == nil {
+	if _, err := readQueryHeaderCookie(true, nil, got, io.NopCloser(bytes.NewReader(nil)), nil, request, request.Header, http.StatusOK); err == nil {
 		t.Fatal("readQueryHeaderCookie unexpectedly succeeded for empty body in compatibility mode")
 	}
 }
@@ -5,3 +5,19 @@ import "context"
 type Handler func(ctx context.Context, req any) (any, any)
 
 type Middleware func(ctx context.Context, req any, next Handler) (any, any)
+
+// chainMiddleware returns middleware calling outer and then inner inside it.
+// Any of them can be nil.
+func chainMiddleware(outer, inner Middleware) Middleware {
+	if outer == nil {
+		return inner
+	}
+	if inner == nil {
+		return outer
+	}
+	return func(ctx context.Context, req any, next Handler) (any, any) {
+		return outer(ctx, req, func(ctx context.Context, req any) (any, any) {
+			return inner(ctx, req, next)
+		})
+	}
+}
@@ -0,0 +1,164 @@
+package api2
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"text/template"
+)
+
+var mockTemplate = template.Must(template.New("mock").Funcs(template.FuncMap{
+	"lower": func(s string) string {
+		return strings.ToLower(s[:1]) + s[1:]
+	},
+}).Parse(mockTemplateStr))
+
+const mockTemplateStr = `package {{ .Pkg }}
+
+// Code generated by api2. DO NOT EDIT.
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+{{ range .ServiceInterfaces }}
+var _ {{ . }} = (*Mock)(nil)
+{{ end }}
+// Mock implements the service by calling the functions set in its fields.
+// A method whose function is not set returns an error. Requests of all calls
+// are recorded. Set the functions before calling the methods.
+type Mock struct {
+{{- range .Methods }}
+	{{ .Name }}Func func(ctx context.Context, req *{{ .Request }}) (*{{ .Response }}, error)
+{{- end }}
+
+	mu sync.Mutex
+{{- range .Methods }}
+	{{ lower .Name }}Calls []*{{ .Request }}
+{{- end }}
+}
+{{ range .Methods }}
+func (m *Mock) {{ .Name }}(ctx context.Context, req *{{ .Request }}) (*{{ .Response }}, error) {
+	m.mu.Lock()
+	m.{{ lower .Name }}Calls = append(m.{{ lower .Name }}Calls, req)
+	m.mu.Unlock()
+	if m.{{ .Name }}Func == nil {
+		return nil, fmt.Errorf("Mock.{{ .Name }}Func is not set")
+	}
+	return m.{{ .Name }}Func(ctx, req)
+}
+
+// {{ .Name }}Calls returns requests of all calls of {{ .Name }}.
+func (m *Mock) {{ .Name }}Calls() []*{{ .Request }} {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]*{{ .Request }}(nil), m.{{ lower .Name }}Calls...)
+}
+{{ end }}`
+
+// GenerateMockCode accepts global functions GetRoutes of a package and
+// returns the code of a mock of the service and path to the file where the
+// code should be saved (mock.go in the same directory where GetRoutes and
+// types of requests, responses and service are defined).
+func GenerateMockCode(getRoutess ...interface{}) (code, mockFile string, err error) {
+	var routes []Route
+	var serviceInterfaces []string
+	var pkg string
+	for _, getRoutes := range getRoutess {
+		dir := filepath.Dir(getFileByFunction(getRoutes))
+		mockFile = filepath.Join(dir, "mock.go")
+
+		pkg1, _, err := detectPkgs(dir)
+		if err != nil {
+			return "", "", fmt.Errorf("failed to determine pkg for dir %s: %v", dir, err)
+		}
+		if pkg != "" && pkg1 != pkg {
+			return "", "", fmt.Errorf("instances of GetRoutes belong to different directories: %s and %s", pkg, pkg1)
+		}
+		pkg = pkg1
+
+		genValue := reflect.ValueOf(getRoutes)
+		serviceArg := reflect.New(genValue.Type().In(0)).Elem()
+		routesValues := genValue.Call([]reflect.Value{serviceArg})
+		routes = append(routes, routesValues[0].Interface().([]Route)...)
+
+		if genValue.Type().In(0).Kind() == reflect.Interface {
+			serviceInterfaces = append(serviceInterfaces, genValue.Type().In(0).Name())
+		}
+	}
+
+	type Method struct {
+		Name     string
+		Request  string
+		Response string
+	}
+	type Vars struct {
+		Pkg               string
+		ServiceInterfaces []string
+		Methods           []Method
+	}
+	vars := Vars{
+		Pkg:               pkg,
+		ServiceInterfaces: serviceInterfaces,
+	}
+	for i, r := range routes {
+		name, request, response, err := getMethod(r.Handler)
+		if err != nil {
+			return "", "", fmt.Errorf("failed to analyze method %d in package %s: %v", i, pkg, err)
+		}
+		vars.Methods = append(vars.Methods, Method{
+			Name:     name,
+			Request:  request,
+			Response: response,
+		})
+	}
+
+	var codeBuf bytes.Buffer
+	if err := mockTemplate.Execute(&codeBuf, vars); err != nil {
+		return "", "", fmt.Errorf("template executation failed for package %s: %v", pkg, err)
+	}
+	formatted, err := format.Source(codeBuf.Bytes())
+	if err != nil {
+		return "", "", fmt.Errorf("failed to format mock for package %s: %v", pkg, err)
+	}
+
+	return string(formatted), mockFile, nil
+}
+
+// GenerateMock generates file mock.go with type Mock implementing the service
+// near the file in which passed GetRoutes function is defined. Serve the mock
+// with GetRoutes(mock) to test clients or pass it to code using the service.
+func GenerateMock(getRoutes ...interface{}) {
+	fileName := getFileByFunction(getRoutes[0])
+	log.Printf("api2 generates mock for %s ...", fileName)
+	if err := generateMock(getRoutes...); err != nil {
+		log.Fatalf("api2 failed to generate mock for %s: %v", fileName, err)
+	}
+}
+
+func generateMock(getRoutes ...interface{}) error {
+	code, mockFile, err := GenerateMockCode(getRoutes...)
+	if err != nil {
+		return err
+	}
+
+	// Check that the file does not exist or was generated.
+	oldContent, err := os.ReadFile(mockFile)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("unknown error when reading from %s: %v", mockFile, err)
+	} else if err == nil && !codeGeneratedRE.Match(oldContent) {
+		return fmt.Errorf("file %s exists and was not generated; please remove it to proceed", mockFile)
+	}
+
+	if err := os.WriteFile(mockFile, []byte(code), 0644); err != nil {
+		return fmt.Errorf("failed to write file %s: %v", mockFile, err)
+	}
+
+	return nil
+}
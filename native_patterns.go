@@ -0,0 +1,140 @@
+package api2
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// nativeParam is a URL parameter of a route registered as http.ServeMux
+// pattern. Constraints are checked by the handler, since http.ServeMux does
+// not support them.
+type nativeParam struct {
+	name       string
+	constraint *paramConstraint
+}
+
+// nativePattern converts the method and the path of a route to a pattern of
+// http.ServeMux, e.g. "GET", "/users/:user/files/:path*" to
+// "GET /users/{user}/files/{path...}". If named is false, names of
+// parameters are omitted ("GET /users/{}/files/{...}"): such patterns are
+// equal for routes matching the same requests.
+func nativePattern(method, path string, named bool) (string, []nativeParam) {
+	parts := splitUrl(path)
+	var params []nativeParam
+	for i, part := range parts {
+		name, wildcard, constraint, isParam := parseParam(part)
+		if !isParam {
+			continue
+		}
+		params = append(params, nativeParam{name: name, constraint: constraint})
+		if !named {
+			name = ""
+		}
+		if wildcard {
+			if i != len(parts)-1 {
+				panic(fmt.Sprintf("route %s %s: wildcard parameter must be the last segment of the path to be used in http.ServeMux pattern", method, path))
+			}
+			parts[i] = "{" + name + "...}"
+		} else {
+			parts[i] = "{" + name + "}"
+		}
+	}
+	if len(parts) == 0 {
+		// Match only "/", not the whole tree.
+		return method + " /{$}", params
+	}
+	return method + " /" + strings.Join(parts, "/"), params
+}
+
+func bindNativePatterns(mux Router, routes []Route, handlers []http.HandlerFunc, human bool, errorf func(format string, args ...interface{})) {
+	// Detect duplicate routes the same way as in the default mode.
+	rt := newRouter()
+	for i, route := range routes {
+		rt.add(route.Method, route.Path, i)
+	}
+
+	// Routes differing only by names or constraints of parameters match the
+	// same requests. They share a handler checking the constraints.
+	var shapes []string
+	shape2indices := make(map[string][]int)
+	params := make([][]nativeParam, len(routes))
+	for i, route := range routes {
+		shape, routeParams := nativePattern(route.Method, route.Path, false)
+		if _, has := shape2indices[shape]; !has {
+			shapes = append(shapes, shape)
+		}
+		shape2indices[shape] = append(shape2indices[shape], i)
+		params[i] = routeParams
+	}
+
+	for _, shape := range shapes {
+		indices := shape2indices[shape]
+		// More constrained routes go first.
+		sort.SliceStable(indices, func(a, b int) bool {
+			return countConstraints(params[indices[a]]) > countConstraints(params[indices[b]])
+		})
+		first := routes[indices[0]]
+		pattern, patternParams := nativePattern(first.Method, first.Path, true)
+
+		mux.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+			r, human2 := withHuman(r, human)
+			values := make([]string, len(patternParams))
+			for i, p := range patternParams {
+				values[i] = r.PathValue(p.name)
+			}
+			for _, index := range indices {
+				if !matchNativeConstraints(params[index], values) {
+					continue
+				}
+				// Make the values available under names used in the route.
+				for i, p := range params[index] {
+					if p.name != patternParams[i].name {
+						r.SetPathValue(p.name, values[i])
+					}
+				}
+				handlers[index](w, r)
+				return
+			}
+			if err := jsonError(w, human2, http.StatusNotFound, "failed to find route by path"); err != nil {
+				errorf("%s handler failed to send NotFound error to client: %v", r.URL.Path, err)
+			}
+		})
+	}
+
+	// http.ServeMux answers unknown paths and wrong methods with plain text.
+	// The catch-all pattern matches all requests not matched by the patterns
+	// above, so errors have the same JSON format as in the default mode.
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		r, human2 := withHuman(r, human)
+		if _, _, pathMatched := rt.match(r.Method, r.URL.Path); pathMatched {
+			if err := jsonError(w, human2, http.StatusMethodNotAllowed, "unsupported method: %v", r.Method); err != nil {
+				errorf("%s handler failed to send MethodNotAllowed error to client: %v", r.URL.Path, err)
+			}
+			return
+		}
+		if err := jsonError(w, human2, http.StatusNotFound, "failed to find route by path"); err != nil {
+			errorf("%s handler failed to send NotFound error to client: %v", r.URL.Path, err)
+		}
+	})
+}
+
+func countConstraints(params []nativeParam) int {
+	n := 0
+	for _, p := range params {
+		if p.constraint != nil {
+			n++
+		}
+	}
+	return n
+}
+
+func matchNativeConstraints(params []nativeParam, values []string) bool {
+	for i, p := range params {
+		if p.constraint != nil && !p.constraint.Match(values[i]) {
+			return false
+		}
+	}
+	return true
+}
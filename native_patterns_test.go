@@ -0,0 +1,47 @@
+package api2
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNativePattern(t *testing.T) {
+	cases := []struct {
+		method          string
+		path            string
+		wantPattern     string
+		wantShape       string
+		wantConstraints []string
+	}{
+		{method: http.MethodPost, path: "/echo", wantPattern: "POST /echo"},
+		{method: http.MethodPost, path: "/echo/", wantPattern: "POST /echo"},
+		{method: http.MethodGet, path: "/", wantPattern: "GET /{$}"},
+		{method: http.MethodPost, path: "/echo/:user", wantPattern: "POST /echo/{user}", wantShape: "POST /echo/{}"},
+		{method: http.MethodGet, path: "/users/:user/files/:path*", wantPattern: "GET /users/{user}/files/{path...}", wantShape: "GET /users/{}/files/{...}"},
+		{method: http.MethodGet, path: "/users/:id<int>/posts/:post<uuid>", wantPattern: "GET /users/{id}/posts/{post}", wantShape: "GET /users/{}/posts/{}", wantConstraints: []string{"id", "post"}},
+	}
+
+	for _, tc := range cases {
+		pattern, params := nativePattern(tc.method, tc.path, true)
+		require.Equal(t, tc.wantPattern, pattern, tc.path)
+		var constrained []string
+		for _, p := range params {
+			if p.constraint != nil {
+				constrained = append(constrained, p.name)
+			}
+		}
+		require.Equal(t, tc.wantConstraints, constrained, tc.path)
+
+		shape, _ := nativePattern(tc.method, tc.path, false)
+		if tc.wantShape == "" {
+			tc.wantShape = tc.wantPattern
+		}
+		require.Equal(t, tc.wantShape, shape, tc.path)
+	}
+
+	require.Panics(t, func() {
+		nativePattern(http.MethodGet, "/files/:path*/raw", true)
+	})
+}
@@ -110,9 +110,7 @@ OUTER:
 		op := spec.NewOperation()
 
 		parameters := []*spec.ParameterRef{}
-		reqFields := reflect.VisibleFields(req)
-
-		for _, field := range reqFields {
+		for _, field := range flattenFields(req) {
 			if tag, ok := field.Tag.Lookup("query"); ok {
 				parameters = append(parameters, &spec.ParameterRef{
 					Value: &spec.Parameter{
@@ -123,12 +121,16 @@ OUTER:
 					},
 				})
 			} else if tag, ok := field.Tag.Lookup("url"); ok {
+				schema := mapGoTypeToOpenAPISchema(field.Type)
+				if constraint := findParamConstraint(r.Path, tag); constraint != nil {
+					applyParamConstraint(schema, constraint)
+				}
 				parameters = append(parameters, &spec.ParameterRef{
 					Value: &spec.Parameter{
 						Name:     tag,
 						In:       "path",
 						Required: true,
-						Schema:   spec.NewSchemaRef("", mapGoTypeToOpenAPISchema(field.Type)),
+						Schema:   spec.NewSchemaRef("", schema),
 					},
 				})
 			}
@@ -154,6 +156,7 @@ OUTER:
 			Value: spec.NewRequestBody().WithContent(spec.NewContentWithSchemaRef(spec.NewSchemaRef(typegen.RefSchemaPrefix+r.ReqType, nil), []string{"application/json"})),
 		}
 		op.Tags = append(op.Tags, r.FnInfo.PkgName)
+		op.Tags = append(op.Tags, RouteGroups(&route)...)
 		p.SetOperation(r.Method, op)
 
 	}
@@ -161,7 +164,7 @@ OUTER:
 }
 
 func convertColonPathToBraces(path string) string {
-	parts := strings.Split(path, "/")
+	parts := strings.Split(stripParamConstraints(path), "/")
 	for i, part := range parts {
 		if len(part) > 0 && part[0] == ':' {
 			parts[i] = "{" + part[1:] + "}"
@@ -170,6 +173,33 @@ func convertColonPathToBraces(path string) string {
 	return strings.Join(parts, "/")
 }
 
+// findParamConstraint returns the constraint of URL parameter in the path
+// or nil if the parameter has no constraint.
+func findParamConstraint(path, param string) *paramConstraint {
+	for _, part := range strings.Split(path, "/") {
+		if name, _, constraint, isParam := parseParam(part); isParam && name == param {
+			return constraint
+		}
+	}
+	return nil
+}
+
+func applyParamConstraint(schema *spec.Schema, constraint *paramConstraint) {
+	switch constraint.kind {
+	case "int":
+		schema.Type = &spec.Types{spec.TypeInteger}
+	case "uint":
+		schema.Type = &spec.Types{spec.TypeInteger}
+		schema.Min = new(float64)
+	case "uuid":
+		schema.Type = &spec.Types{spec.TypeString}
+		schema.Format = "uuid"
+		schema.Pattern = constraint.Pattern()
+	default:
+		schema.Pattern = constraint.Pattern()
+	}
+}
+
 func mapGoTypeToOpenAPISchema(t reflect.Type) *spec.Schema {
 	switch t.Kind() {
 	case reflect.Bool:
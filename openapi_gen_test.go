@@ -0,0 +1,26 @@
+package api2
+
+import (
+	"reflect"
+	"testing"
+
+	spec "github.com/getkin/kin-openapi/openapi3"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenApiPathParamConstraints(t *testing.T) {
+	path := "/users/:id<uint>/files/:name<re:[a-z0-9-]+>/:path*"
+	require.Equal(t, "/users/{id}/files/{name}/{path*}", convertColonPathToBraces(path))
+
+	idSchema := mapGoTypeToOpenAPISchema(reflect.TypeOf(""))
+	applyParamConstraint(idSchema, findParamConstraint(path, "id"))
+	require.Equal(t, &spec.Types{spec.TypeInteger}, idSchema.Type)
+	require.Equal(t, 0.0, *idSchema.Min)
+
+	nameSchema := mapGoTypeToOpenAPISchema(reflect.TypeOf(""))
+	applyParamConstraint(nameSchema, findParamConstraint(path, "name"))
+	require.Equal(t, &spec.Types{spec.TypeString}, nameSchema.Type)
+	require.Equal(t, "^(?:[a-z0-9-]+)$", nameSchema.Pattern)
+
+	require.Nil(t, findParamConstraint(path, "path"))
+}
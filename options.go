@@ -16,6 +16,19 @@ type Config struct {
 	client        HttpClient
 	maxBody       int64
 	human         bool
+	retry         *RetryPolicy // Affects only clients.
+
+	circuitBreaker *CircuitBreakerPolicy // Affects only clients.
+	interceptor    Interceptor           // Affects only clients.
+	credentials    CredentialProvider    // Affects only clients.
+	signer         *RequestSigner        // Affects only clients.
+	balancer       *LoadBalancer         // Affects only clients.
+	hedge          *HedgePolicy          // Affects only clients.
+
+	verifier *SignatureVerifier // Affects only BindRoutes.
+	dump     *DumpPolicy        // Affects only BindRoutes.
+
+	nativePatterns bool // Affects only BindRoutes.
 
 	middleware Middleware
 }
@@ -67,3 +80,92 @@ func AddMiddleware(m Middleware) Option {
 		config.middleware = m
 	}
 }
+
+// NativePatterns makes BindRoutes register each route in the mux as a pattern
+// of http.ServeMux of Go 1.22+ including the method, e.g. route
+// "/users/:user/files/:path*" with method GET is registered as
+// "GET /users/{user}/files/{path...}". URL parameters are read using
+// r.PathValue. This allows api2 routes to coexist with other handlers
+// registered in the same mux under the same prefixes. The mux must be
+// *http.ServeMux or support the same patterns. Wildcard parameters are only
+// supported in the last segment of the path and must match at least one
+// segment. BindRoutes also registers catch-all pattern "/" answering unknown
+// paths and unsupported methods with JSON errors as in the default mode, so
+// the mux must not have another handler for "/".
+func NativePatterns(enabled bool) Option {
+	return func(config *Config) {
+		config.nativePatterns = enabled
+	}
+}
+
+// Retry makes the client retry failed calls according to the policy.
+func Retry(policy *RetryPolicy) Option {
+	return func(config *Config) {
+		config.retry = policy
+	}
+}
+
+// CircuitBreaker makes the client fail fast with CircuitOpenError while the
+// route or the host fails too often, according to the policy.
+func CircuitBreaker(policy *CircuitBreakerPolicy) Option {
+	return func(config *Config) {
+		config.circuitBreaker = policy
+	}
+}
+
+// AddInterceptor adds the interceptor to the client. Interceptors are called
+// in the order they were added: the first one is the outermost.
+func AddInterceptor(i Interceptor) Option {
+	return func(config *Config) {
+		config.interceptor = chainInterceptors(config.interceptor, i)
+	}
+}
+
+// Credentials makes the client set Authorization header of each call to the
+// value returned by the provider. It overrides AuthorizationHeader. If the
+// server responds with 401 Unauthorized, the call is made once again with
+// new credentials.
+func Credentials(provider CredentialProvider) Option {
+	return func(config *Config) {
+		config.credentials = provider
+	}
+}
+
+// SignRequests makes the client sign requests with the signer.
+func SignRequests(signer *RequestSigner) Option {
+	return func(config *Config) {
+		config.signer = signer
+	}
+}
+
+// VerifySignatures makes BindRoutes reject requests without valid signatures
+// made by RequestSigner.
+func VerifySignatures(verifier *SignatureVerifier) Option {
+	return func(config *Config) {
+		config.verifier = verifier
+	}
+}
+
+// LoadBalance makes the client send requests to endpoints of the balancer
+// instead of baseURL.
+func LoadBalance(balancer *LoadBalancer) Option {
+	return func(config *Config) {
+		config.balancer = balancer
+	}
+}
+
+// Hedge makes the client send extra requests of idempotent routes if the
+// response is slow, according to the policy.
+func Hedge(policy *HedgePolicy) Option {
+	return func(config *Config) {
+		config.hedge = policy
+	}
+}
+
+// DumpRequests makes BindRoutes log incoming requests as curl commands and
+// the responses according to the policy.
+func DumpRequests(policy *DumpPolicy) Option {
+	return func(config *Config) {
+		config.dump = policy
+	}
+}
@@ -0,0 +1,448 @@
+// Package replayclient records HTTP traffic of api2 clients to a cassette
+// file and replays it in tests without network.
+package replayclient
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"unicode/utf8"
+)
+
+type HttpClient interface {
+	Do(req *http.Request) (*http.Response, error)
+	CloseIdleConnections()
+}
+
+// Cassette is the content of a cassette file.
+type Cassette struct {
+	Interactions []Interaction `json:"interactions"`
+}
+
+// Interaction is a recorded request and its response.
+type Interaction struct {
+	Request  Request  `json:"request"`
+	Response Response `json:"response"`
+}
+
+type Request struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Header http.Header `json:"header,omitempty"`
+	Body   Body        `json:"body,omitempty"`
+}
+
+type Response struct {
+	StatusCode int         `json:"status_code"`
+	Header     http.Header `json:"header,omitempty"`
+	Body       Body        `json:"body,omitempty"`
+}
+
+// Body is stored in JSON as a string if it is valid UTF-8 and as an object
+// {"base64": "..."} otherwise.
+type Body []byte
+
+func (b Body) MarshalJSON() ([]byte, error) {
+	if utf8.Valid(b) {
+		return json.Marshal(string(b))
+	}
+	return json.Marshal(map[string]string{"base64": base64.StdEncoding.EncodeToString(b)})
+}
+
+func (b *Body) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err == nil {
+		*b = Body(s)
+		return nil
+	}
+	var encoded struct {
+		Base64 string `json:"base64"`
+	}
+	if err := json.Unmarshal(data, &encoded); err != nil {
+		return err
+	}
+	decoded, err := base64.StdEncoding.DecodeString(encoded.Base64)
+	if err != nil {
+		return err
+	}
+	*b = decoded
+	return nil
+}
+
+// Match is a set of parts of requests compared when replaying.
+type Match int
+
+const (
+	MatchMethod Match = 1 << iota
+	MatchPath
+	MatchQuery
+	// Bodies are compared as JSON values if both are valid JSON.
+	MatchBody
+
+	MatchAll = MatchMethod | MatchPath | MatchQuery | MatchBody
+)
+
+// Redacted replaces secret values in cassettes.
+const Redacted = "REDACTED"
+
+// Config configures redaction and matching. The same config should be used
+// for recording and replaying, since requests are redacted before they are
+// matched.
+type Config struct {
+	// RedactHeaders are names of headers of requests and responses which
+	// values are replaced by Redacted, e.g. "Authorization".
+	RedactHeaders []string
+
+	// RedactQuery are names of query parameters which values are replaced.
+	RedactQuery []string
+
+	// RedactJSONFields are names of fields of JSON objects in bodies which
+	// values are replaced, at any depth.
+	RedactJSONFields []string
+
+	// Match selects the parts of requests compared when replaying.
+	// Defaults to MatchAll.
+	Match Match
+
+	// AllowRepeats lets an interaction be replayed more than once. Otherwise
+	// each interaction is replayed once. In both cases the order of calls
+	// does not matter: a request is served by the first recorded interaction
+	// matching it, skipping the interactions already replayed (unless
+	// AllowRepeats is set). Identical requests get the recorded responses in
+	// the recorded order.
+	AllowRepeats bool
+}
+
+func (c *Config) redactHeader(header http.Header) http.Header {
+	header = header.Clone()
+	for _, name := range c.RedactHeaders {
+		if values, has := header[http.CanonicalHeaderKey(name)]; has {
+			for i := range values {
+				values[i] = Redacted
+			}
+		}
+	}
+	return header
+}
+
+func (c *Config) redactURL(u *url.URL) string {
+	if len(c.RedactQuery) == 0 || u.RawQuery == "" {
+		return u.String()
+	}
+	query := u.Query()
+	for _, name := range c.RedactQuery {
+		if values, has := query[name]; has {
+			for i := range values {
+				values[i] = Redacted
+			}
+		}
+	}
+	u2 := *u
+	u2.RawQuery = query.Encode()
+	return u2.String()
+}
+
+func (c *Config) redactBody(body []byte) []byte {
+	if len(c.RedactJSONFields) == 0 || len(body) == 0 {
+		return body
+	}
+	var value interface{}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil {
+		return body
+	}
+	value = c.redactJSON(value)
+	redacted, err := json.Marshal(value)
+	if err != nil {
+		return body
+	}
+	if bytes.HasSuffix(body, []byte("\n")) {
+		redacted = append(redacted, '\n')
+	}
+	return redacted
+}
+
+func (c *Config) redactJSON(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, field := range v {
+			redact := false
+			for _, name := range c.RedactJSONFields {
+				if key == name {
+					redact = true
+				}
+			}
+			if redact {
+				v[key] = Redacted
+			} else {
+				v[key] = c.redactJSON(field)
+			}
+		}
+	case []interface{}:
+		for i := range v {
+			v[i] = c.redactJSON(v[i])
+		}
+	}
+	return value
+}
+
+// newRequest converts and redacts the request. The body of the request is
+// replaced with a copy.
+func (c *Config) newRequest(req *http.Request) (Request, error) {
+	var body []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		if err != nil {
+			return Request{}, fmt.Errorf("failed to read request body: %w", err)
+		}
+		if err := req.Body.Close(); err != nil {
+			return Request{}, fmt.Errorf("failed to close request body: %w", err)
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	return Request{
+		Method: req.Method,
+		URL:    c.redactURL(req.URL),
+		Header: c.redactHeader(req.Header),
+		Body:   c.redactBody(body),
+	}, nil
+}
+
+// Recorder is HttpClient recording requests and responses. The cassette is
+// written when the recorder is closed (e.g. by api2.Client.Close).
+// Responses are read into memory, so streaming responses are delivered
+// after they end.
+type Recorder struct {
+	impl   HttpClient
+	path   string
+	config Config
+
+	mu       sync.Mutex
+	cassette Cassette
+}
+
+// NewRecorder creates a recorder writing the cassette to path. The directory
+// of path must exist.
+func NewRecorder(impl HttpClient, path string, config Config) (*Recorder, error) {
+	if path == "" {
+		return nil, fmt.Errorf("replayclient: empty cassette path")
+	}
+	dir := filepath.Dir(path)
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, fmt.Errorf("replayclient: bad directory of cassette %s: %w", path, err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("replayclient: %s is not a directory", dir)
+	}
+	return &Recorder{
+		impl:   impl,
+		path:   path,
+		config: config,
+	}, nil
+}
+
+func (r *Recorder) Do(req *http.Request) (*http.Response, error) {
+	recorded, err := r.config.newRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := r.impl.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+	if err := res.Body.Close(); err != nil {
+		return nil, fmt.Errorf("failed to close response body: %w", err)
+	}
+	res.Body = io.NopCloser(bytes.NewReader(body))
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.cassette.Interactions = append(r.cassette.Interactions, Interaction{
+		Request: recorded,
+		Response: Response{
+			StatusCode: res.StatusCode,
+			Header:     r.config.redactHeader(res.Header),
+			Body:       r.config.redactBody(body),
+		},
+	})
+	return res, nil
+}
+
+func (r *Recorder) CloseIdleConnections() {
+	r.impl.CloseIdleConnections()
+}
+
+// Save writes the cassette file.
+func (r *Recorder) Save() error {
+	r.mu.Lock()
+	data, err := json.MarshalIndent(r.cassette, "", "  ")
+	r.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(r.path, append(data, '\n'), 0o644)
+}
+
+// Close saves the cassette and closes the underlying client if it is
+// io.Closer.
+func (r *Recorder) Close() error {
+	if err := r.Save(); err != nil {
+		return err
+	}
+	if closer, ok := r.impl.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// Replayer is HttpClient serving responses from a cassette. It returns an
+// error describing the request if no interaction matches it.
+type Replayer struct {
+	config Config
+
+	mu       sync.Mutex
+	cassette Cassette
+	used     []bool
+}
+
+func NewReplayer(path string, config Config) (*Replayer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var cassette Cassette
+	if err := json.Unmarshal(data, &cassette); err != nil {
+		return nil, fmt.Errorf("failed to parse cassette %s: %w", path, err)
+	}
+	if config.Match == 0 {
+		config.Match = MatchAll
+	}
+	return &Replayer{
+		config:   config,
+		cassette: cassette,
+		used:     make([]bool, len(cassette.Interactions)),
+	}, nil
+}
+
+func (r *Replayer) Do(req *http.Request) (*http.Response, error) {
+	if err := req.Context().Err(); err != nil {
+		return nil, err
+	}
+	recorded, err := r.config.newRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	found := -1
+	for i, interaction := range r.cassette.Interactions {
+		if r.used[i] && !r.config.AllowRepeats {
+			continue
+		}
+		if r.config.match(recorded, interaction.Request) {
+			found = i
+			break
+		}
+	}
+	if found == -1 {
+		return nil, fmt.Errorf("replayclient: no recorded interaction matches request %s %s with body %q", recorded.Method, recorded.URL, recorded.Body)
+	}
+	r.used[found] = true
+
+	recordedRes := r.cassette.Interactions[found].Response
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", recordedRes.StatusCode, http.StatusText(recordedRes.StatusCode)),
+		StatusCode:    recordedRes.StatusCode,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        recordedRes.Header.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(recordedRes.Body)),
+		ContentLength: int64(len(recordedRes.Body)),
+		Request:       req,
+	}, nil
+}
+
+func (r *Replayer) CloseIdleConnections() {
+}
+
+// Unused returns descriptions of interactions which were not replayed.
+func (r *Replayer) Unused() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var unused []string
+	for i, interaction := range r.cassette.Interactions {
+		if !r.used[i] {
+			unused = append(unused, interaction.Request.Method+" "+interaction.Request.URL)
+		}
+	}
+	return unused
+}
+
+func (c *Config) match(got, want Request) bool {
+	if c.Match&MatchMethod != 0 && got.Method != want.Method {
+		return false
+	}
+	gotURL, err1 := url.Parse(got.URL)
+	wantURL, err2 := url.Parse(want.URL)
+	if err1 != nil || err2 != nil {
+		return false
+	}
+	if c.Match&MatchPath != 0 && gotURL.EscapedPath() != wantURL.EscapedPath() {
+		return false
+	}
+	if c.Match&MatchQuery != 0 && canonicalQuery(gotURL) != canonicalQuery(wantURL) {
+		return false
+	}
+	if c.Match&MatchBody != 0 && !equalBodies(got.Body, want.Body) {
+		return false
+	}
+	return true
+}
+
+func canonicalQuery(u *url.URL) string {
+	query := u.Query()
+	for _, values := range query {
+		sort.Strings(values)
+	}
+	return query.Encode()
+}
+
+func equalBodies(a, b []byte) bool {
+	if bytes.Equal(a, b) {
+		return true
+	}
+	// Numbers are kept as json.Number: float64 would make int64 IDs
+	// differing beyond 2^53 equal.
+	var va, vb interface{}
+	if decodeJSON(a, &va) != nil || decodeJSON(b, &vb) != nil {
+		return false
+	}
+	ca, err1 := json.Marshal(va)
+	cb, err2 := json.Marshal(vb)
+	return err1 == nil && err2 == nil && string(ca) == string(cb)
+}
+
+func decodeJSON(data []byte, v interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	return decoder.Decode(v)
+}
@@ -0,0 +1,133 @@
+package replayclient
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/starius/api2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	type LoginRequest struct {
+		User     string `json:"user"`
+		Password string `json:"password"`
+		Lang     string `query:"lang"`
+	}
+	type LoginResponse struct {
+		Token    string `json:"token"`
+		Greeting string `json:"greeting"`
+	}
+	type BinaryRequest struct {
+	}
+	type BinaryResponse struct {
+		Data []byte `use_as_body:"true" is_raw:"true"`
+	}
+
+	loginHandler := func(ctx context.Context, req *LoginRequest) (*LoginResponse, error) {
+		return &LoginResponse{Token: "t-" + req.User, Greeting: req.Lang + " hello " + req.User}, nil
+	}
+	binaryHandler := func(ctx context.Context, req *BinaryRequest) (*BinaryResponse, error) {
+		return &BinaryResponse{Data: []byte{0xff, 0x00, 0xfe}}, nil
+	}
+	routes := []api2.Route{
+		{Method: http.MethodPost, Path: "/login", Handler: loginHandler},
+		{Method: http.MethodGet, Path: "/binary", Handler: binaryHandler},
+	}
+
+	mux := http.NewServeMux()
+	api2.BindRoutes(mux, routes)
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	config := Config{
+		RedactHeaders:    []string{"Authorization"},
+		RedactJSONFields: []string{"password", "token"},
+	}
+	cassettePath := filepath.Join(t.TempDir(), "cassette.json")
+	ctx := context.Background()
+
+	_, err := NewRecorder(http.DefaultClient, filepath.Join(t.TempDir(), "missing", "cassette.json"), config)
+	require.ErrorContains(t, err, "bad directory of cassette")
+
+	recorder, err := NewRecorder(http.DefaultClient, cassettePath, config)
+	require.NoError(t, err)
+	client := api2.NewClient(routes, server.URL, api2.CustomClient(recorder), api2.AuthorizationHeader("Bearer secret"))
+	loginRes := &LoginResponse{}
+	require.NoError(t, client.Call(ctx, loginRes, &LoginRequest{User: "alice", Password: "pass1", Lang: "en"}))
+	require.Equal(t, "t-alice", loginRes.Token)
+	require.NoError(t, client.Call(ctx, loginRes, &LoginRequest{User: "bob", Password: "pass2", Lang: "de"}))
+	binaryRes := &BinaryResponse{}
+	require.NoError(t, client.Call(ctx, binaryRes, &BinaryRequest{}))
+	require.Equal(t, []byte{0xff, 0x00, 0xfe}, binaryRes.Data)
+	require.NoError(t, client.Close())
+
+	data, err := os.ReadFile(cassettePath)
+	require.NoError(t, err)
+	require.NotContains(t, string(data), "secret")
+	require.NotContains(t, string(data), "pass1")
+	require.NotContains(t, string(data), "t-alice")
+	var cassette Cassette
+	require.NoError(t, json.Unmarshal(data, &cassette))
+	require.Len(t, cassette.Interactions, 3)
+	require.Equal(t, []string{Redacted}, cassette.Interactions[0].Request.Header["Authorization"])
+	require.Equal(t, Body{0xff, 0x00, 0xfe}, cassette.Interactions[2].Response.Body)
+
+	// Replay with the server down.
+	server.Close()
+	replayer, err := NewReplayer(cassettePath, config)
+	require.NoError(t, err)
+	client = api2.NewClient(routes, "http://127.0.0.1:1", api2.CustomClient(replayer))
+
+	// Order of calls does not matter, redacted values match anything.
+	require.NoError(t, client.Call(ctx, loginRes, &LoginRequest{User: "bob", Password: "other", Lang: "de"}))
+	require.Equal(t, "de hello bob", loginRes.Greeting)
+	require.Equal(t, Redacted, loginRes.Token)
+	require.Equal(t, []string{
+		"POST " + server.URL + "/login?lang=en",
+		"GET " + server.URL + "/binary",
+	}, replayer.Unused())
+
+	require.NoError(t, client.Call(ctx, binaryRes, &BinaryRequest{}))
+	require.Equal(t, []byte{0xff, 0x00, 0xfe}, binaryRes.Data)
+
+	// Unmatched requests fail.
+	err = client.Call(ctx, loginRes, &LoginRequest{User: "carol", Lang: "en"})
+	require.ErrorContains(t, err, "no recorded interaction matches request POST")
+	err = client.Call(ctx, loginRes, &LoginRequest{User: "alice", Lang: "fr"})
+	require.ErrorContains(t, err, "no recorded interaction matches")
+
+	require.NoError(t, client.Call(ctx, loginRes, &LoginRequest{User: "alice", Lang: "en"}))
+	require.Equal(t, "en hello alice", loginRes.Greeting)
+	require.Empty(t, replayer.Unused())
+
+	// Each interaction is replayed once.
+	err = client.Call(ctx, loginRes, &LoginRequest{User: "alice", Lang: "en"})
+	require.ErrorContains(t, err, "no recorded interaction matches")
+
+	t.Run("matching", func(t *testing.T) {
+		config := config
+		config.Match = MatchMethod | MatchPath
+		config.AllowRepeats = true
+		replayer, err := NewReplayer(cassettePath, config)
+		require.NoError(t, err)
+		client := api2.NewClient(routes, "http://127.0.0.1:1", api2.CustomClient(replayer))
+		for i := 0; i < 3; i++ {
+			require.NoError(t, client.Call(ctx, loginRes, &LoginRequest{User: "dave", Lang: "es"}))
+			require.Equal(t, "en hello alice", loginRes.Greeting)
+		}
+	})
+}
+
+func TestEqualBodies(t *testing.T) {
+	require.True(t, equalBodies([]byte(`{"a":1,"b":"x"}`), []byte(`{"b": "x", "a": 1}`)))
+	require.False(t, equalBodies([]byte(`{"a":1}`), []byte(`{"a":2}`)))
+	// float64 can not distinguish these IDs.
+	require.False(t, equalBodies([]byte(`{"id":9007199254740993}`), []byte(`{"id":9007199254740992}`)))
+	require.False(t, equalBodies([]byte(`not json`), []byte(`{}`)))
+}
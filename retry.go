@@ -0,0 +1,200 @@
+package api2
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"math/rand/v2"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy configures retries of failed calls made by Client. Pass it to
+// NewClient in option Retry. Use DefaultRetryPolicy to get reasonable values.
+//
+// Only calls of routes with idempotent HTTP methods (GET, HEAD, OPTIONS,
+// TRACE, PUT, DELETE) and routes marked Idempotent are retried. Requests
+// with streaming bodies (is_stream) are not retried, since their bodies can
+// not be sent again. Other bodies are rewound using http.Request.GetBody.
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of attempts including the first one.
+	// Values less than 2 disable retries.
+	MaxAttempts int
+
+	// InitialBackoff is the delay before the first retry. The delay is
+	// multiplied by Multiplier (if it is greater than 1) after each attempt
+	// and is limited by MaxBackoff.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	Multiplier     float64
+
+	// Jitter is the fraction of the delay which is random, from 0 to 1.
+	// E.g. if it is 0.5, the delay is chosen uniformly between 50% and 100%
+	// of the computed value.
+	Jitter float64
+
+	// Budget limits the total duration of the call including all attempts
+	// and delays (0 means no limit). The deadline of ctx limits it as well:
+	// if the next attempt can not start before the deadline, the last result
+	// is returned.
+	Budget time.Duration
+
+	// RetryableStatuses are HTTP status codes of responses causing a retry.
+	// If the response has header Retry-After, the delay is at least its value.
+	RetryableStatuses []int
+
+	// RetryableError tells if the error returned by HttpClient.Do (e.g.
+	// a network error) causes a retry. If it is nil, all errors do except
+	// cancellation of ctx.
+	RetryableError func(err error) bool
+}
+
+// DefaultRetryPolicy returns a policy making up to 3 attempts with delays
+// starting from 100ms. It retries network errors and responses with status
+// codes 429, 502, 503 and 504.
+func DefaultRetryPolicy() *RetryPolicy {
+	return &RetryPolicy{
+		MaxAttempts:    3,
+		InitialBackoff: 100 * time.Millisecond,
+		MaxBackoff:     10 * time.Second,
+		Multiplier:     2,
+		Jitter:         0.5,
+		RetryableStatuses: []int{
+			http.StatusTooManyRequests,
+			http.StatusBadGateway,
+			http.StatusServiceUnavailable,
+			http.StatusGatewayTimeout,
+		},
+	}
+}
+
+func isIdempotentMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// canRetry returns if the request of the route can be sent again.
+func canRetry(route Route, req *http.Request) bool {
+	if !route.Idempotent && !isIdempotentMethod(route.Method) {
+		return false
+	}
+	// Streaming bodies have no GetBody.
+	return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+}
+
+// backoff returns the delay after the attempt (starting from 1).
+func (p *RetryPolicy) backoff(attempt int) time.Duration {
+	delay := float64(p.InitialBackoff)
+	if p.Multiplier > 1 {
+		delay *= math.Pow(p.Multiplier, float64(attempt-1))
+	}
+	if p.MaxBackoff > 0 && delay > float64(p.MaxBackoff) {
+		delay = float64(p.MaxBackoff)
+	}
+	if p.Jitter > 0 {
+		delay -= delay * p.Jitter * rand.Float64()
+	}
+	return time.Duration(delay)
+}
+
+// shouldRetry returns if the result of an attempt must be retried and
+// the minimum delay requested by the server.
+func (p *RetryPolicy) shouldRetry(ctx context.Context, res *http.Response, err error) (bool, time.Duration) {
+	if err != nil {
+		if ctx.Err() != nil {
+			return false, 0
+		}
+		if p.RetryableError != nil {
+			return p.RetryableError(err), 0
+		}
+		return true, 0
+	}
+	if !slices.Contains(p.RetryableStatuses, res.StatusCode) {
+		return false, 0
+	}
+	retryAfter, _ := parseRetryAfter(res.Header.Get("Retry-After"), time.Now())
+	return true, retryAfter
+}
+
+// parseRetryAfter parses the value of Retry-After header: the number of
+// seconds or HTTP date.
+func parseRetryAfter(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if seconds, err := strconv.Atoi(value); err == nil {
+		if seconds < 0 {
+			return 0, false
+		}
+		return time.Duration(seconds) * time.Second, true
+	}
+	t, err := http.ParseTime(value)
+	if err != nil {
+		return 0, false
+	}
+	if t.Before(now) {
+		return 0, true
+	}
+	return t.Sub(now), true
+}
+
+// do sends the request, retrying it according to the retry policy.
+func (c *Client) do(ctx context.Context, route Route, req *http.Request) (*http.Response, error) {
+	p := c.retry
+	if p == nil || p.MaxAttempts < 2 || !canRetry(route, req) {
+		return c.attempt(route, req)
+	}
+
+	var deadline time.Time
+	if p.Budget > 0 {
+		deadline = time.Now().Add(p.Budget)
+	}
+	if ctxDeadline, has := ctx.Deadline(); has && (deadline.IsZero() || ctxDeadline.Before(deadline)) {
+		deadline = ctxDeadline
+	}
+
+	for attempt := 1; ; attempt++ {
+		res, err := c.attempt(route, req)
+		retry, retryAfter := p.shouldRetry(ctx, res, err)
+		if !retry || attempt >= p.MaxAttempts {
+			return res, err
+		}
+		delay := p.backoff(attempt)
+		if retryAfter > delay {
+			delay = retryAfter
+		}
+		if !deadline.IsZero() && time.Now().Add(delay).After(deadline) {
+			return res, err
+		}
+
+		if res != nil {
+			// Let the connection be reused.
+			_, _ = io.Copy(io.Discard, io.LimitReader(res.Body, 64*1024))
+			if err := res.Body.Close(); err != nil {
+				c.errorf("failed to close resource: %v", err)
+			}
+		}
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, ctx.Err()
+		case <-timer.C:
+		}
+
+		if req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, fmt.Errorf("failed to rewind request body: %w", err)
+			}
+			req.Body = body
+		}
+	}
+}
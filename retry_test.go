@@ -0,0 +1,70 @@
+package api2
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2020, time.July, 10, 11, 30, 0, 0, time.UTC)
+	cases := []struct {
+		value   string
+		want    time.Duration
+		wantHas bool
+	}{
+		{value: "", wantHas: false},
+		{value: "5", want: 5 * time.Second, wantHas: true},
+		{value: "-1", wantHas: false},
+		{value: "soon", wantHas: false},
+		{value: "Fri, 10 Jul 2020 11:30:30 GMT", want: 30 * time.Second, wantHas: true},
+		{value: "Fri, 10 Jul 2020 11:00:00 GMT", want: 0, wantHas: true},
+	}
+	for _, tc := range cases {
+		got, has := parseRetryAfter(tc.value, now)
+		require.Equal(t, tc.wantHas, has, tc.value)
+		require.Equal(t, tc.want, got, tc.value)
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	p := &RetryPolicy{
+		InitialBackoff: 100 * time.Millisecond,
+		MaxBackoff:     time.Second,
+		Multiplier:     2,
+	}
+	require.Equal(t, 100*time.Millisecond, p.backoff(1))
+	require.Equal(t, 200*time.Millisecond, p.backoff(2))
+	require.Equal(t, 800*time.Millisecond, p.backoff(4))
+	require.Equal(t, time.Second, p.backoff(5))
+	require.Equal(t, time.Second, p.backoff(100))
+
+	p.Jitter = 0.5
+	for i := 0; i < 100; i++ {
+		delay := p.backoff(2)
+		require.GreaterOrEqual(t, delay, 100*time.Millisecond)
+		require.LessOrEqual(t, delay, 200*time.Millisecond)
+	}
+}
+
+func TestCanRetry(t *testing.T) {
+	newRequest := func(method string, body io.Reader) *http.Request {
+		req, err := http.NewRequest(method, "http://example.com", body)
+		require.NoError(t, err)
+		return req
+	}
+
+	require.True(t, canRetry(Route{Method: http.MethodGet}, newRequest(http.MethodGet, nil)))
+	require.True(t, canRetry(Route{Method: http.MethodPut}, newRequest(http.MethodPut, strings.NewReader("{}"))))
+	require.False(t, canRetry(Route{Method: http.MethodPost}, newRequest(http.MethodPost, strings.NewReader("{}"))))
+	require.True(t, canRetry(Route{Method: http.MethodPost, Idempotent: true}, newRequest(http.MethodPost, strings.NewReader("{}"))))
+
+	// Streaming body can not be rewound.
+	stream := newRequest(http.MethodPut, nil)
+	stream.Body = io.NopCloser(strings.NewReader("data"))
+	require.False(t, canRetry(Route{Method: http.MethodPut}, stream))
+}
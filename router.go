@@ -0,0 +1,228 @@
+package api2
+
+import (
+	"fmt"
+	"strings"
+)
+
+// router finds a route by HTTP method and path. It is a tree of path
+// segments. On each level static segments take precedence over parameters
+// (parameters with constraints go first) and parameters take precedence
+// over wildcards, so the order of routes does not matter. If a branch does
+// not lead to a route, the next alternative is tried.
+type router struct {
+	root routerNode
+
+	// Paths of added routes by method and shape of the path (the path with
+	// names of parameters removed).
+	shapes map[string]string
+}
+
+type routerNode struct {
+	static    map[string]*routerNode
+	params    []*routerParam // Constrained parameters go first.
+	wildcards []*routerParam
+
+	// Index of the route ending in this node by HTTP method.
+	method2index map[string]int
+}
+
+type routerParam struct {
+	name       string
+	constraint *paramConstraint
+	node       *routerNode
+}
+
+// routerMatch is the state of matching.
+type routerMatch struct {
+	method string
+
+	// Names and values of parameters, interleaved.
+	params []string
+
+	// Some route matches the path, but not the method.
+	pathMatched bool
+}
+
+func newRouter() *router {
+	return &router{}
+}
+
+// trimSlashes removes leading and trailing slashes as splitUrl does.
+func trimSlashes(path string) string {
+	for len(path) > 0 && path[0] == '/' {
+		path = path[1:]
+	}
+	for len(path) > 0 && path[len(path)-1] == '/' {
+		path = path[:len(path)-1]
+	}
+	return path
+}
+
+// cutSegment returns the first segment of path and the rest after '/'.
+func cutSegment(path string) (segment, rest string) {
+	if i := strings.IndexByte(path, '/'); i != -1 {
+		return path[:i], path[i+1:]
+	}
+	return path, ""
+}
+
+// add adds route path of the route with the index. It panics if the same path
+// and method were already added or if another route of the method differs
+// only in names of parameters, e.g. "/users/:id" and "/users/:name", since
+// only one of them could match.
+func (rt *router) add(method, path string, index int) {
+	node := &rt.root
+	var shape strings.Builder
+	for _, part := range splitUrl(path) {
+		name, wildcard, constraint, isParam := parseParam(part)
+		shape.WriteByte('/')
+		switch {
+		case !isParam:
+			shape.WriteString(part)
+		case wildcard:
+			shape.WriteString(":*")
+		case constraint != nil:
+			shape.WriteString(":<" + constraint.source + ">")
+		default:
+			shape.WriteString(":")
+		}
+		if !isParam {
+			if node.static == nil {
+				node.static = make(map[string]*routerNode)
+			}
+			child, has := node.static[part]
+			if !has {
+				child = &routerNode{}
+				node.static[part] = child
+			}
+			node = child
+			continue
+		}
+		if wildcard {
+			node.wildcards, node = addRouterParam(node.wildcards, name, nil)
+		} else {
+			node.params, node = addRouterParam(node.params, name, constraint)
+		}
+	}
+	if node.method2index == nil {
+		node.method2index = make(map[string]int)
+	}
+	if _, has := node.method2index[method]; has {
+		panic(fmt.Sprintf("route %s %s is registered twice", method, path))
+	}
+	key := method + " " + shape.String()
+	if other, has := rt.shapes[key]; has {
+		panic(fmt.Sprintf("routes %s %s and %s %s differ only in names of URL parameters", method, other, method, path))
+	}
+	if rt.shapes == nil {
+		rt.shapes = make(map[string]string)
+	}
+	rt.shapes[key] = path
+	node.method2index[method] = index
+}
+
+func addRouterParam(params []*routerParam, name string, constraint *paramConstraint) ([]*routerParam, *routerNode) {
+	for _, p := range params {
+		if p.name == name && p.constraint == constraint {
+			return params, p.node
+		}
+	}
+	p := &routerParam{
+		name:       name,
+		constraint: constraint,
+		node:       &routerNode{},
+	}
+	params = append(params, p)
+	// Keep parameters with constraints before parameters without them.
+	for i := len(params) - 1; i > 0 && params[i].constraint != nil && params[i-1].constraint == nil; i-- {
+		params[i], params[i-1] = params[i-1], params[i]
+	}
+	return params, p.node
+}
+
+// match returns the index of the route matching the method and path (-1 if
+// not found) and the values of URL parameters (nil if the route has none).
+// If the index is -1, pathMatched tells if some route has matching path,
+// but another method.
+func (rt *router) match(method, path string) (index int, param2value map[string]string, pathMatched bool) {
+	m := routerMatch{method: method}
+	index = rt.root.match(&m, trimSlashes(path))
+	if index == -1 {
+		return -1, nil, m.pathMatched
+	}
+	if len(m.params) != 0 {
+		param2value = make(map[string]string, len(m.params)/2)
+		for i := 0; i < len(m.params); i += 2 {
+			param2value[m.params[i]] = m.params[i+1]
+		}
+	}
+	return index, param2value, false
+}
+
+func (n *routerNode) match(m *routerMatch, path string) int {
+	if path == "" {
+		if n.method2index != nil {
+			if index, has := n.method2index[m.method]; has {
+				return index
+			}
+			m.pathMatched = true
+		}
+		// Wildcards can match zero segments.
+		for _, w := range n.wildcards {
+			if index := m.matchParam(w, "", ""); index != -1 {
+				return index
+			}
+		}
+		return -1
+	}
+
+	segment, rest := cutSegment(path)
+	if child, has := n.static[segment]; has {
+		if index := child.match(m, rest); index != -1 {
+			return index
+		}
+	}
+
+	for _, p := range n.params {
+		if p.constraint != nil && !p.constraint.Match(segment) {
+			continue
+		}
+		if index := m.matchParam(p, segment, rest); index != -1 {
+			return index
+		}
+	}
+
+	for _, w := range n.wildcards {
+		// Try to capture as few segments as possible.
+		if index := m.matchParam(w, "", path); index != -1 {
+			return index
+		}
+		end := 0
+		for {
+			i := strings.IndexByte(path[end:], '/')
+			if i == -1 {
+				if index := m.matchParam(w, path, ""); index != -1 {
+					return index
+				}
+				break
+			}
+			end += i
+			if index := m.matchParam(w, path[:end], path[end+1:]); index != -1 {
+				return index
+			}
+			end++
+		}
+	}
+
+	return -1
+}
+
+func (m *routerMatch) matchParam(p *routerParam, value, rest string) int {
+	m.params = append(m.params, p.name, value)
+	index := p.node.match(m, rest)
+	if index == -1 {
+		m.params = m.params[:len(m.params)-2]
+	}
+	return index
+}
@@ -0,0 +1,123 @@
+package api2
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRouterPrecedence(t *testing.T) {
+	paths := []string{
+		"/files/:path*",
+		"/files/:path*/raw",
+		"/users/:name",
+		"/users/:id<int>",
+		"/users/me",
+		"/users/:name/posts",
+		"/users/me/:path*",
+	}
+
+	cases := []struct {
+		path        string
+		wantPath    string
+		wantParams  map[string]string
+		wantMissing bool
+	}{
+		{path: "/users/me", wantPath: "/users/me"},
+		{path: "/users/42", wantPath: "/users/:id<int>", wantParams: map[string]string{"id": "42"}},
+		{path: "/users/bob", wantPath: "/users/:name", wantParams: map[string]string{"name": "bob"}},
+		{path: "/users/me/posts", wantPath: "/users/me/:path*", wantParams: map[string]string{"path": "posts"}},
+		{path: "/users/bob/posts", wantPath: "/users/:name/posts", wantParams: map[string]string{"name": "bob"}},
+		{path: "/files/a/b/raw", wantPath: "/files/:path*/raw", wantParams: map[string]string{"path": "a/b"}},
+		{path: "/files/a/b/c", wantPath: "/files/:path*", wantParams: map[string]string{"path": "a/b/c"}},
+		{path: "/files", wantPath: "/files/:path*", wantParams: map[string]string{"path": ""}},
+		{path: "/users", wantMissing: true},
+		{path: "/users/bob/comments", wantMissing: true},
+	}
+
+	// The result must not depend on the order of routes.
+	orders := [][]string{paths, make([]string, len(paths))}
+	for i, path := range paths {
+		orders[1][len(paths)-1-i] = path
+	}
+
+	for _, order := range orders {
+		rt := newRouter()
+		for i, path := range order {
+			rt.add(http.MethodGet, path, i)
+		}
+		for _, tc := range cases {
+			t.Run(tc.path, func(t *testing.T) {
+				index, param2value, pathMatched := rt.match(http.MethodGet, tc.path)
+				require.False(t, pathMatched)
+				if tc.wantMissing {
+					require.Equal(t, -1, index)
+					return
+				}
+				require.NotEqual(t, -1, index)
+				require.Equal(t, tc.wantPath, order[index])
+				require.Equal(t, tc.wantParams, param2value)
+			})
+		}
+	}
+}
+
+func TestRouterMethods(t *testing.T) {
+	rt := newRouter()
+	rt.add(http.MethodGet, "/users/:id", 0)
+	rt.add(http.MethodPost, "/users/:id", 1)
+	rt.add(http.MethodGet, "/users/:id<int>/posts", 2)
+
+	index, _, _ := rt.match(http.MethodPost, "/users/1")
+	require.Equal(t, 1, index)
+
+	index, _, pathMatched := rt.match(http.MethodDelete, "/users/1")
+	require.Equal(t, -1, index)
+	require.True(t, pathMatched)
+
+	index, _, pathMatched = rt.match(http.MethodGet, "/users/bob/posts")
+	require.Equal(t, -1, index)
+	require.False(t, pathMatched)
+
+	require.Panics(t, func() {
+		rt.add(http.MethodGet, "/users/:id", 3)
+	})
+}
+
+func TestRouterConflicts(t *testing.T) {
+	rt := newRouter()
+	rt.add(http.MethodGet, "/users/:id", 0)
+	rt.add(http.MethodGet, "/users/:id<int>", 1)
+	rt.add(http.MethodGet, "/users/:path*", 2)
+	rt.add(http.MethodPost, "/users/:name", 3)
+
+	// Only one of the routes could match.
+	require.PanicsWithValue(t, "routes GET /users/:id and GET /users/:name differ only in names of URL parameters", func() {
+		rt.add(http.MethodGet, "/users/:name", 4)
+	})
+	require.Panics(t, func() {
+		rt.add(http.MethodGet, "/users/:n<int>", 4)
+	})
+	require.Panics(t, func() {
+		rt.add(http.MethodGet, "/users/:rest*", 4)
+	})
+
+	index, param2value, _ := rt.match(http.MethodPost, "/users/bob")
+	require.Equal(t, 3, index)
+	require.Equal(t, map[string]string{"name": "bob"}, param2value)
+}
+
+func TestRouterStaticAllocs(t *testing.T) {
+	rt := newRouter()
+	rt.add(http.MethodGet, "/api/v1/users", 0)
+	rt.add(http.MethodGet, "/api/v1/users/:id", 1)
+	rt.add(http.MethodPost, "/api/v1/posts", 2)
+
+	allocs := testing.AllocsPerRun(100, func() {
+		if index, _, _ := rt.match(http.MethodGet, "/api/v1/users"); index != 0 {
+			t.Fatalf("unexpected index %d", index)
+		}
+	})
+	require.Equal(t, 0.0, allocs)
+}
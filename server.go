@@ -34,136 +34,151 @@ func BindRoutes(mux Router, routes []Route, opts ...Option) {
 	errorf := config.errorf
 	human := config.human
 
-	routePaths := make([]string, len(routes))
-	for i, route := range routes {
-		routePaths[i] = fmt.Sprintf("%s: %s", route.Method, route.Path)
+	var d *dumper
+	if config.dump != nil {
+		d = newDumper(config.dump)
+	}
+	for _, route := range routes {
+		// Panic early on bad values even if dumps are disabled.
+		dumpMetaRate(route)
 	}
 
-	// Detect route conflicts
-	conflicts := detectRouteConflicts(routePaths)
-	if len(conflicts) > 0 {
-		for _, conflict := range conflicts {
-			conflictMsg := fmt.Sprintf("Route conflict detected:\n  Route %d: %s (specificity score: %d)\n  Route %d: %s (specificity score: %d)\n  Issue: Less specific route appears before more specific route",
-				conflict.route1Index, conflict.route1Path, conflict.route1Score,
-				conflict.route2Index, conflict.route2Path, conflict.route2Score)
-
-			panic(fmt.Sprintf("ROUTE VALIDATION FAILED: %s\n\nSuggestion: reorder routes manually with more specific routes first.", conflictMsg))
+	handlers := make([]http.HandlerFunc, len(routes))
+	for i, route := range routes {
+		handlers[i] = newHTTPHandler(route, human, errorf, config.middleware, config.maxBody)
+		if config.verifier != nil {
+			handlers[i] = config.verifier.wrap(route, handlers[i], config.maxBody, errorf)
+		}
+		if d != nil {
+			handlers[i] = d.wrap(route, handlers[i], errorf)
 		}
 	}
 
-	path2routes := make(map[string][]Route)
-	for _, route := range routes {
-		path := cutUrlParams(route.Path)
-		path2routes[path] = append(path2routes[path], route)
+	if config.nativePatterns {
+		bindNativePatterns(mux, routes, handlers, human, errorf)
+		return
 	}
 
-	for path, routes := range path2routes {
-		method2routes := make(map[string][]Route, len(routes))
-		for _, route := range routes {
-			method2routes[route.Method] = append(method2routes[route.Method], route)
-		}
-		method2handler := make(map[string]http.HandlerFunc, len(routes))
-		for method, routes := range method2routes {
-			method2handler[method] = newHTTPMethodHandler(routes, human, errorf, config.middleware)
-		}
+	// The router matches paths regardless of the order of routes.
+	rt := newRouter()
+	for i, route := range routes {
+		rt.add(route.Method, route.Path, i)
+	}
 
-		mux.HandleFunc(path, func(w http.ResponseWriter, r *http.Request) {
-			// Calling FormValue before parsing JSON "eats" r.Body if Content-Type is
-			// application/x-www-form-urlencoded. This happens in curl for me.
-			human2 := human || r.FormValue("human") != ""
-			if human2 {
-				r = r.WithContext(context.WithValue(r.Context(), humanType{}, true))
-			}
-			handler, has := method2handler[r.Method]
-			if !has {
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		r, human2 := withHuman(r, human)
+		index, param2value, pathMatched := rt.match(r.Method, r.URL.Path)
+		if index == -1 {
+			if pathMatched {
 				if err := jsonError(w, human2, http.StatusMethodNotAllowed, "unsupported method: %v", r.Method); err != nil {
 					errorf("%s handler failed to send MethodNotAllowed error to client: %v", r.URL.Path, err)
 				}
 				return
 			}
-			r.Body = http.MaxBytesReader(w, r.Body, config.maxBody)
-			handler(w, r)
-		})
+			if err := jsonError(w, human2, http.StatusNotFound, "failed to find route by path"); err != nil {
+				errorf("%s handler failed to send NotFound error to client: %v", r.URL.Path, err)
+			}
+			return
+		}
+		if param2value != nil {
+			r = r.WithContext(context.WithValue(r.Context(), paramMapType{}, param2value))
+		}
+		handlers[index](w, r)
 	}
-}
 
-// GetMatcher returns a function converting http.Request to Route.
-func GetMatcher(routes []Route) func(*http.Request) (*Route, bool) {
-	path2method2route := make(map[string]map[string]*Route)
+	// Register the handler for all static prefixes of the routes in the mux.
+	// The router dispatches the request further.
+	registered := make(map[string]bool)
 	for _, route := range routes {
-		route := route
-		method2route, has := path2method2route[route.Path]
-		if !has {
-			method2route = make(map[string]*Route)
-			path2method2route[route.Path] = method2route
+		path := cutUrlParams(route.Path)
+		if registered[path] {
+			continue
 		}
-		method2route[route.Method] = &route
+		registered[path] = true
+		mux.HandleFunc(path, handler)
 	}
+}
 
-	// Use mux to detect route.Path from http.Request.
-	mux := http.NewServeMux()
-	BindRoutes(mux, routes)
+// withHuman marks the context of the request if JSON must be formatted for
+// humans: if it is enabled in options or by "human" form value.
+func withHuman(r *http.Request, human bool) (*http.Request, bool) {
+	// Calling FormValue before parsing JSON "eats" r.Body if Content-Type is
+	// application/x-www-form-urlencoded. This happens in curl for me.
+	human2 := human || r.FormValue("human") != ""
+	if human2 {
+		r = r.WithContext(context.WithValue(r.Context(), humanType{}, true))
+	}
+	return r, human2
+}
+
+// GetMatcher returns a function converting http.Request to Route.
+func GetMatcher(routes []Route) func(*http.Request) (*Route, bool) {
+	rt := newRouter()
+	for i, route := range routes {
+		rt.add(route.Method, route.Path, i)
+	}
 
 	return func(r *http.Request) (*Route, bool) {
-		_, path := mux.Handler(r)
-		method2route, has := path2method2route[path]
-		if !has {
+		index, _, _ := rt.match(r.Method, r.URL.Path)
+		if index == -1 {
 			return nil, false
 		}
-		route, has := method2route[r.Method]
-		return route, has
+		return &routes[index], true
 	}
 }
 
-func newHTTPMethodHandler(routes []Route, human bool, errorf func(format string, args ...interface{}), middleware Middleware) http.HandlerFunc {
-	if len(routes) == 1 && len(findUrlKeys(routes[0].Path)) == 0 {
-		// Single handler without URL parameters.
-		return newHTTPHandler(routes[0], human, errorf, middleware)
+// routeCaller returns a function creating a new request of the route and
+// the handler of the route.
+func routeCaller(route Route) (func() interface{}, Handler) {
+	h := route.Handler
+	if f, ok := h.(funcer); ok {
+		h = f.Func()
 	}
-	paths := make([]string, 0, len(routes))
-	handlers := make([]http.HandlerFunc, 0, len(routes))
-	for _, route := range routes {
-		paths = append(paths, route.Path)
-		handlers = append(handlers, newHTTPHandler(route, human, errorf, middleware))
+
+	handlerValue := reflect.ValueOf(h)
+	handlerType := handlerValue.Type()
+	validateHandler(handlerType, route.Path)
+	if handlerValue.IsNil() {
+		// E.g. NewRoute with nil handler or Method of nil interface,
+		// which can only be used by clients.
+		panic(fmt.Sprintf("route %s %s: handler is nil", route.Method, route.Path))
 	}
-	c := newPathClassifier(paths)
 
-	return func(w http.ResponseWriter, r *http.Request) {
-		index, param2value := c.Classify(r.URL.Path)
-		if index == -1 {
-			// Calling FormValue before parsing JSON "eats" r.Body if Content-Type is
-			// application/x-www-form-urlencoded. This happens in curl for me.
-			human2 := human || r.FormValue("human") != ""
-			if err := jsonError(w, human2, http.StatusNotFound, "failed to find route by path"); err != nil {
-				errorf("%s handler failed to send NotFound error to client: %v", r.URL.Path, err)
-			}
-			return
-		}
+	newRequest := func() interface{} {
+		return reflect.New(handlerType.In(1).Elem()).Interface()
+	}
+	start := func(ctx context.Context, req any) (any, any) {
+		results := handlerValue.Call([]reflect.Value{reflect.ValueOf(ctx), reflect.ValueOf(req)})
+		resp := results[0].Interface()
+		errReflect := results[1].Interface()
 
-		handler := handlers[index]
-		r = r.WithContext(context.WithValue(r.Context(), paramMapType{}, param2value))
-		handler(w, r)
+		return resp, errReflect
 	}
+	if typed, ok := route.Handler.(typedCaller); ok {
+		// The route was created by NewRoute, no need to use reflection.
+		newRequest = typed.newRequest
+		start = func(ctx context.Context, req any) (any, any) {
+			return typed.call(ctx, req)
+		}
+	}
+	return newRequest, start
 }
 
-func newHTTPHandler(route Route, human bool, errorf func(format string, args ...interface{}), middleware Middleware) http.HandlerFunc {
-	h := route.Handler
+func newHTTPHandler(route Route, human bool, errorf func(format string, args ...interface{}), middleware Middleware, maxBody int64) http.HandlerFunc {
 	t := route.Transport
 	if t == nil {
 		t = DefaultTransport
 	}
-
-	if m, ok := h.(*interfaceMethod); ok {
-		h = m.Func()
+	if route.MaxBody != 0 {
+		maxBody = route.MaxBody
 	}
-
-	handlerValue := reflect.ValueOf(h)
-	handlerType := handlerValue.Type()
-	validateHandler(handlerType, route.Path)
+	middleware = chainMiddleware(middleware, route.Middleware)
+	newRequest, start := routeCaller(route)
 
 	return func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, maxBody)
 		ctx := r.Context()
-		req := reflect.New(handlerType.In(1).Elem()).Interface()
+		req := newRequest()
 		ctx, err := t.DecodeRequest(ctx, r, req)
 		if err != nil {
 			err = t.EncodeError(ctx, w, httpError{
@@ -176,13 +191,6 @@ func newHTTPHandler(route Route, human bool, errorf func(format string, args ...
 			return
 		}
 
-		start := func(ctx context.Context, req any) (any, any) {
-			results := handlerValue.Call([]reflect.Value{reflect.ValueOf(ctx), reflect.ValueOf(req)})
-			resp := results[0].Interface()
-			errReflect := results[1].Interface()
-
-			return resp, errReflect
-		}
 		var resp any
 		var errReflect any
 		switch middleware != nil {
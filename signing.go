@@ -0,0 +1,311 @@
+package api2
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Headers of signed requests.
+const (
+	SignatureKeyIDHeader         = "X-Api2-Key-Id"
+	SignatureTimestampHeader     = "X-Api2-Timestamp"
+	SignatureNonceHeader         = "X-Api2-Nonce"
+	SignatureContentSHA256Header = "X-Api2-Content-Sha256"
+	SignatureSignedHeadersHeader = "X-Api2-Signed-Headers"
+	SignatureHeader              = "X-Api2-Signature"
+)
+
+// UnsignedPayload is the value of X-Api2-Content-Sha256 header of requests
+// with streaming bodies. Such bodies are not covered by the signature.
+const UnsignedPayload = "UNSIGNED-PAYLOAD"
+
+// RequestSigner signs requests made by Client with HMAC-SHA256. Pass it to
+// NewClient in option SignRequests. The server verifies signatures using
+// SignatureVerifier.
+//
+// The signature covers the method, the path and the query of the URL, the
+// timestamp, a random nonce, the headers listed in Headers and SHA-256 of
+// the body. Bodies of streaming requests (is_stream) are not covered: the
+// digest is replaced by UnsignedPayload. Each attempt of a retried call is
+// signed again.
+type RequestSigner struct {
+	KeyID string
+	Key   []byte
+
+	// Headers are names of additional headers to sign if they are present
+	// in the request. If it is nil, Content-Type is signed.
+	Headers []string
+}
+
+func (s *RequestSigner) sign(req *http.Request, now time.Time) error {
+	digest, err := requestBodyDigest(req)
+	if err != nil {
+		return err
+	}
+	var nonce [16]byte
+	if _, err := rand.Read(nonce[:]); err != nil {
+		return fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	headers := s.Headers
+	if headers == nil {
+		headers = []string{"Content-Type"}
+	}
+	var signed []string
+	for _, name := range headers {
+		if _, has := req.Header[http.CanonicalHeaderKey(name)]; has {
+			signed = append(signed, strings.ToLower(name))
+		}
+	}
+
+	req.Header.Set(SignatureKeyIDHeader, s.KeyID)
+	req.Header.Set(SignatureTimestampHeader, strconv.FormatInt(now.Unix(), 10))
+	req.Header.Set(SignatureNonceHeader, hex.EncodeToString(nonce[:]))
+	req.Header.Set(SignatureContentSHA256Header, digest)
+	req.Header.Set(SignatureSignedHeadersHeader, strings.Join(signed, ";"))
+	req.Header.Set(SignatureHeader, computeSignature(s.Key, req))
+	return nil
+}
+
+// requestBodyDigest returns hex SHA-256 of the body or UnsignedPayload if
+// the body is a stream.
+func requestBodyDigest(req *http.Request) (string, error) {
+	h := sha256.New()
+	switch {
+	case req.Body == nil || req.Body == http.NoBody:
+	case req.GetBody != nil:
+		body, err := req.GetBody()
+		if err != nil {
+			return "", fmt.Errorf("failed to get request body to sign: %w", err)
+		}
+		defer body.Close()
+		if _, err := io.Copy(h, body); err != nil {
+			return "", fmt.Errorf("failed to read request body to sign: %w", err)
+		}
+	default:
+		return UnsignedPayload, nil
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// computeSignature returns hex HMAC-SHA256 of the canonical form of the
+// request made from the headers set by RequestSigner.sign.
+func computeSignature(key []byte, req *http.Request) string {
+	var b strings.Builder
+	b.WriteString(req.Method)
+	b.WriteByte('\n')
+	b.WriteString(req.URL.EscapedPath())
+	b.WriteByte('\n')
+	b.WriteString(req.URL.RawQuery)
+	b.WriteByte('\n')
+	for _, name := range []string{SignatureKeyIDHeader, SignatureTimestampHeader, SignatureNonceHeader, SignatureSignedHeadersHeader} {
+		b.WriteString(req.Header.Get(name))
+		b.WriteByte('\n')
+	}
+	if signed := req.Header.Get(SignatureSignedHeadersHeader); signed != "" {
+		for _, name := range strings.Split(signed, ";") {
+			b.WriteString(name)
+			b.WriteByte(':')
+			b.WriteString(strings.Join(req.Header.Values(name), ","))
+			b.WriteByte('\n')
+		}
+	}
+	b.WriteString(req.Header.Get(SignatureContentSHA256Header))
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(b.String()))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// SignatureVerifier verifies signatures of requests made by clients with
+// RequestSigner. Pass it to BindRoutes in option VerifySignatures. Requests
+// without valid signatures are rejected with 401 Unauthorized before they
+// are decoded.
+//
+// A request is rejected if its timestamp differs from the time of the
+// server by more than MaxSkew or if a request with the same key and nonce
+// was accepted within the window.
+//
+// The zero value accepts no keys until SetKey is called.
+type SignatureVerifier struct {
+	// MaxSkew is the maximum difference between the timestamp of a request
+	// and the time of the server. Defaults to 5 minutes.
+	MaxSkew time.Duration
+
+	// RequiredHeaders are names of headers which must be signed. Requests
+	// without them or not listing them in X-Api2-Signed-Headers are
+	// rejected. Use the same names in RequestSigner.Headers.
+	RequiredHeaders []string
+
+	mu        sync.Mutex
+	keys      map[string][]byte
+	seen      map[string]time.Time
+	lastSweep time.Time
+}
+
+// NewSignatureVerifier creates a verifier accepting requests signed with
+// any of the keys (by key ID). Keep both old and new keys during rotation.
+func NewSignatureVerifier(keys map[string][]byte) *SignatureVerifier {
+	v := &SignatureVerifier{
+		MaxSkew: 5 * time.Minute,
+		keys:    make(map[string][]byte, len(keys)),
+		seen:    make(map[string]time.Time),
+	}
+	for keyID, key := range keys {
+		v.keys[keyID] = key
+	}
+	return v
+}
+
+// SetKey adds or replaces the key.
+func (v *SignatureVerifier) SetKey(keyID string, key []byte) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if v.keys == nil {
+		v.keys = make(map[string][]byte)
+	}
+	v.keys[keyID] = key
+}
+
+func (v *SignatureVerifier) maxSkew() time.Duration {
+	if v.MaxSkew > 0 {
+		return v.MaxSkew
+	}
+	return 5 * time.Minute
+}
+
+// DeleteKey removes the key. Requests signed with it are rejected.
+func (v *SignatureVerifier) DeleteKey(keyID string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	delete(v.keys, keyID)
+}
+
+// verify checks the signature of the request and replaces its body with
+// the buffered copy. Unsigned payload is accepted only if allowUnsigned.
+func (v *SignatureVerifier) verify(r *http.Request, w http.ResponseWriter, maxBody int64, allowUnsigned bool, now time.Time) error {
+	keyID := r.Header.Get(SignatureKeyIDHeader)
+	v.mu.Lock()
+	key, has := v.keys[keyID]
+	v.mu.Unlock()
+	if !has {
+		return fmt.Errorf("unknown signing key %q", keyID)
+	}
+
+	timestamp, err := strconv.ParseInt(r.Header.Get(SignatureTimestampHeader), 10, 64)
+	if err != nil {
+		return fmt.Errorf("bad signature timestamp: %w", err)
+	}
+	signedAt := time.Unix(timestamp, 0)
+	if skew := now.Sub(signedAt).Abs(); skew > v.maxSkew() {
+		return fmt.Errorf("signature timestamp is off by %s", skew)
+	}
+
+	digest := r.Header.Get(SignatureContentSHA256Header)
+	if digest == UnsignedPayload {
+		if !allowUnsigned {
+			return fmt.Errorf("unsigned payload is allowed only for streaming requests")
+		}
+	} else {
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBody))
+		if err != nil {
+			return fmt.Errorf("failed to read request body: %w", err)
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		sum := sha256.Sum256(body)
+		if digest != hex.EncodeToString(sum[:]) {
+			return fmt.Errorf("body digest mismatch")
+		}
+	}
+
+	if len(v.RequiredHeaders) != 0 {
+		signed := make(map[string]bool)
+		for _, name := range strings.Split(r.Header.Get(SignatureSignedHeadersHeader), ";") {
+			signed[strings.ToLower(name)] = true
+		}
+		for _, name := range v.RequiredHeaders {
+			if !signed[strings.ToLower(name)] {
+				return fmt.Errorf("header %s is not signed", name)
+			}
+		}
+	}
+
+	want := computeSignature(key, r)
+	if !hmac.Equal([]byte(want), []byte(r.Header.Get(SignatureHeader))) {
+		return fmt.Errorf("signature mismatch")
+	}
+
+	// The nonce is checked last to remember only valid requests.
+	nonce := r.Header.Get(SignatureNonceHeader)
+	if nonce == "" {
+		return fmt.Errorf("no signature nonce")
+	}
+	return v.checkReplay(keyID+" "+nonce, signedAt.Add(v.maxSkew()), now)
+}
+
+// checkReplay remembers the nonce until it expires and rejects it if it
+// was already seen.
+func (v *SignatureVerifier) checkReplay(nonce string, expiry, now time.Time) error {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if v.seen == nil {
+		v.seen = make(map[string]time.Time)
+	}
+	if now.Sub(v.lastSweep) > v.maxSkew() {
+		for n, e := range v.seen {
+			if now.After(e) {
+				delete(v.seen, n)
+			}
+		}
+		v.lastSweep = now
+	}
+
+	if e, has := v.seen[nonce]; has && !now.After(e) {
+		return fmt.Errorf("replayed request")
+	}
+	v.seen[nonce] = expiry
+	return nil
+}
+
+// wrap returns the handler verifying signatures before calling the handler.
+func (v *SignatureVerifier) wrap(route Route, handler http.HandlerFunc, maxBody int64, errorf func(format string, args ...interface{})) http.HandlerFunc {
+	t := route.Transport
+	if t == nil {
+		t = DefaultTransport
+	}
+	if route.MaxBody != 0 {
+		maxBody = route.MaxBody
+	}
+	h := route.Handler
+	if f, ok := h.(funcer); ok {
+		h = f.Func()
+	}
+	requestType := reflect.TypeOf(h).In(1).Elem()
+	allowUnsigned := requestType.Kind() == reflect.Struct && getPrepared(requestType).Stream
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		if err := v.verify(r, w, maxBody, allowUnsigned, time.Now()); err != nil {
+			err = t.EncodeError(r.Context(), w, httpError{
+				Code:    http.StatusUnauthorized,
+				Message: fmt.Sprintf("bad request signature: %v", err),
+			})
+			if err != nil {
+				errorf("%s %s handler failed to send signature error to client: %v", r.Method, r.URL.Path, err)
+			}
+			return
+		}
+		handler(w, r)
+	}
+}
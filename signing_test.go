@@ -0,0 +1,110 @@
+package api2
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignatureVerifier(t *testing.T) {
+	signer := &RequestSigner{KeyID: "k1", Key: []byte("secret1")}
+	v := NewSignatureVerifier(map[string][]byte{"k1": []byte("secret1")})
+	now := time.Now()
+
+	newSigned := func(body string, at time.Time) *http.Request {
+		req, err := http.NewRequest(http.MethodPost, "http://example.com/a/b?x=1", strings.NewReader(body))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		require.NoError(t, signer.sign(req, at))
+		return req
+	}
+	verify := func(req *http.Request, allowUnsigned bool) error {
+		return v.verify(req, httptest.NewRecorder(), 1024, allowUnsigned, now)
+	}
+
+	req := newSigned(`{"a":1}`, now)
+	require.Equal(t, "content-type", req.Header.Get(SignatureSignedHeadersHeader))
+	require.NoError(t, verify(req, false))
+	body, err := io.ReadAll(req.Body)
+	require.NoError(t, err)
+	require.Equal(t, `{"a":1}`, string(body))
+
+	req.Body = io.NopCloser(strings.NewReader(`{"a":1}`))
+	require.ErrorContains(t, verify(req, false), "replayed request")
+
+	req = newSigned(`{"a":1}`, now)
+	req.Body = io.NopCloser(strings.NewReader(`{"a":2}`))
+	require.ErrorContains(t, verify(req, false), "body digest mismatch")
+
+	req = newSigned(`{"a":1}`, now)
+	req.URL.Path = "/a/c"
+	require.ErrorContains(t, verify(req, false), "signature mismatch")
+
+	req = newSigned(`{"a":1}`, now)
+	req.Header.Set("Content-Type", "text/plain")
+	require.ErrorContains(t, verify(req, false), "signature mismatch")
+
+	req = newSigned(`{"a":1}`, now.Add(-10*time.Minute))
+	require.ErrorContains(t, verify(req, false), "signature timestamp is off")
+
+	// Streaming bodies.
+	req, err = http.NewRequest(http.MethodPost, "http://example.com/upload", io.NopCloser(strings.NewReader("data")))
+	require.NoError(t, err)
+	require.NoError(t, signer.sign(req, now))
+	require.Equal(t, UnsignedPayload, req.Header.Get(SignatureContentSHA256Header))
+	require.ErrorContains(t, verify(req, false), "unsigned payload")
+	require.NoError(t, verify(req, true))
+
+	// Key rotation.
+	signer = &RequestSigner{KeyID: "k2", Key: []byte("secret2")}
+	require.ErrorContains(t, verify(newSigned("", now), false), `unknown signing key "k2"`)
+	v.SetKey("k2", []byte("secret2"))
+	require.NoError(t, verify(newSigned("", now), false))
+	v.DeleteKey("k1")
+	signer = &RequestSigner{KeyID: "k1", Key: []byte("secret1")}
+	require.ErrorContains(t, verify(newSigned("", now), false), "unknown signing key")
+
+	// Old nonces are forgotten.
+	v.SetKey("k1", []byte("secret1"))
+	req = newSigned("", now)
+	require.NoError(t, verify(req, false))
+	require.Len(t, v.seen, 4)
+	require.NoError(t, v.checkReplay("other", now.Add(time.Hour), now.Add(10*time.Minute)))
+	require.Len(t, v.seen, 1)
+}
+
+func TestSignatureVerifierZeroValue(t *testing.T) {
+	signer := &RequestSigner{KeyID: "k1", Key: []byte("secret1"), Headers: []string{"Content-Type", "X-Tenant"}}
+	var v SignatureVerifier
+	now := time.Now()
+
+	newSigned := func(tenant string) *http.Request {
+		req, err := http.NewRequest(http.MethodPost, "http://example.com/a", strings.NewReader(`{}`))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/json")
+		if tenant != "" {
+			req.Header.Set("X-Tenant", tenant)
+		}
+		require.NoError(t, signer.sign(req, now))
+		return req
+	}
+	verify := func(req *http.Request) error {
+		return v.verify(req, httptest.NewRecorder(), 1024, false, now)
+	}
+
+	require.ErrorContains(t, verify(newSigned("t1")), "unknown signing key")
+	v.SetKey("k1", []byte("secret1"))
+	require.NoError(t, verify(newSigned("t1")))
+
+	// Required headers must be signed.
+	v.RequiredHeaders = []string{"x-tenant"}
+	require.NoError(t, verify(newSigned("t1")))
+	require.ErrorContains(t, verify(newSigned("")), "header x-tenant is not signed")
+	signer.Headers = nil
+	require.ErrorContains(t, verify(newSigned("t1")), "header x-tenant is not signed")
+}
@@ -64,6 +64,15 @@ func getMethod(handler interface{}) (name, request, response string, err error)
 	return
 }
 
+// MethodName returns the name of the method of a service handling the route
+// with the handler, as it is named in generated clients. It is known for
+// handlers made by api2.Method and is deduced from names of request and
+// response types otherwise.
+func MethodName(handler interface{}) (string, error) {
+	name, _, _, err := getMethod(handler)
+	return name, err
+}
+
 func getFileByFunction(f interface{}) string {
 	rf := runtime.FuncForPC(reflect.ValueOf(f).Pointer())
 	fileName, _ := rf.FileLine(rf.Entry())
@@ -140,6 +149,15 @@ type Client struct {
 {{ range .ServiceInterfaces }}
 var _ {{ . }} = (*Client)(nil)
 {{ end }}
+// NewClient creates a client. Options, e.g. interceptors added by
+// api2.AddInterceptor, are passed to api2.NewClient.
+//
+// Methods of the client implement the service interface, so they take
+// options of a single call from the context:
+//
+//	ctx = api2.WithCallOptions(ctx, api2.CallTimeout(time.Second))
+//
+// Methods of WithOptions accept them after the request as well.
 func NewClient(baseURL string, opts ...api2.Option) (*Client, error) {
 	if _, err := url.ParseRequestURI(baseURL); err != nil {
 		return nil, err
@@ -166,6 +184,27 @@ func (c *Client) {{ .Name }}(ctx context.Context, req *{{ .Request }}) (res *{{
 	}
 	return
 }
+{{end}}
+// ClientWithOptions is the client whose methods accept options of a single
+// call after the request.
+type ClientWithOptions struct {
+	api2client *api2.Client
+}
+
+// WithOptions returns the client whose methods accept options of a single
+// call after the request.
+func (c *Client) WithOptions() ClientWithOptions {
+	return ClientWithOptions{api2client: c.api2client}
+}
+{{ range .Methods }}
+func (c ClientWithOptions) {{ .Name }}(ctx context.Context, req *{{ .Request }}, opts ...api2.CallOption) (res *{{ .Response }}, err error) {
+	res = &{{ .Response }}{}
+	err = c.api2client.Call(ctx, res, req, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return
+}
 {{end}}`
 
 // GenerateClientCode accepts global function GetRoutes of a package and
@@ -207,24 +246,6 @@ func GenerateClientCode(getRoutess ...interface{}) (code, clientFile string, err
 		}
 	}
 
-	// Validate route ordering to prevent conflicts
-	routePaths := make([]string, len(routes))
-	for i, route := range routes {
-		routePaths[i] = fmt.Sprintf("%s: %s", route.Method, route.Path)
-	}
-
-	// Detect route conflicts using existing validation logic
-	conflicts := detectRouteConflicts(routePaths)
-	if len(conflicts) > 0 {
-		for _, conflict := range conflicts {
-			conflictMsg := fmt.Sprintf("Route conflict detected:\n  Route %d: %s (specificity score: %d)\n  Route %d: %s (specificity score: %d)\n  Issue: Less specific route appears before more specific route",
-				conflict.route1Index, conflict.route1Path, conflict.route1Score,
-				conflict.route2Index, conflict.route2Path, conflict.route2Score)
-
-			return "", "", fmt.Errorf("ROUTE VALIDATION FAILED: %s\n\nSuggestion: reorder routes manually with more specific routes first.", conflictMsg)
-		}
-	}
-
 	code, err = runTemplate(routes, pkg, api2pkg, getRoutesNames, serviceInterfaces)
 	if err != nil {
 		return "", "", err
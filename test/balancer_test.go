@@ -0,0 +1,201 @@
+package api2
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/starius/api2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadBalance(t *testing.T) {
+	type WhoRequest struct {
+	}
+	type WhoResponse struct {
+		Replica int `json:"replica"`
+	}
+	type CreateRequest struct {
+	}
+	type CreateResponse struct {
+		Replica int `json:"replica"`
+	}
+
+	type replica struct {
+		server  *httptest.Server
+		healthy atomic.Bool
+	}
+	replicas := make([]*replica, 3)
+	var endpoints []string
+	for i := range replicas {
+		r := &replica{}
+		r.healthy.Store(true)
+		routes := []api2.Route{
+			api2.NewRoute(http.MethodGet, "/who", func(ctx context.Context, req *WhoRequest) (*WhoResponse, error) {
+				return &WhoResponse{Replica: i}, nil
+			}),
+			api2.NewRoute(http.MethodPost, "/create", func(ctx context.Context, req *CreateRequest) (*CreateResponse, error) {
+				return &CreateResponse{Replica: i}, nil
+			}),
+		}
+		mux := http.NewServeMux()
+		api2.BindRoutes(mux, routes)
+		mux.HandleFunc("/health", func(w http.ResponseWriter, req *http.Request) {
+			if !r.healthy.Load() {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}
+		})
+		r.server = httptest.NewServer(mux)
+		t.Cleanup(r.server.Close)
+		replicas[i] = r
+		endpoints = append(endpoints, r.server.URL)
+	}
+
+	routes := []api2.Route{
+		api2.NewRoute[WhoRequest, WhoResponse](http.MethodGet, "/who", nil),
+		api2.NewRoute[CreateRequest, CreateResponse](http.MethodPost, "/create", nil),
+	}
+	ctx := context.Background()
+	who := func(client *api2.Client) (int, error) {
+		res, err := api2.Call[WhoRequest, WhoResponse](ctx, client, &WhoRequest{})
+		if err != nil {
+			return -1, err
+		}
+		return res.Replica, nil
+	}
+
+	t.Run("round robin", func(t *testing.T) {
+		client := api2.NewClient(routes, "", api2.LoadBalance(&api2.LoadBalancer{
+			Endpoints: endpoints,
+		}))
+		defer client.Close()
+		counts := make(map[int]int)
+		for i := 0; i < 9; i++ {
+			replica, err := who(client)
+			require.NoError(t, err)
+			counts[replica]++
+		}
+		require.Equal(t, map[int]int{0: 3, 1: 3, 2: 3}, counts)
+	})
+
+	t.Run("resolver", func(t *testing.T) {
+		var resolves atomic.Int32
+		client := api2.NewClient(routes, "", api2.LoadBalance(&api2.LoadBalancer{
+			Strategy: api2.LeastOutstanding,
+			Resolve: func(ctx context.Context) ([]string, error) {
+				resolves.Add(1)
+				return endpoints[2:], nil
+			},
+			ResolveInterval: time.Hour,
+		}))
+		defer client.Close()
+		for i := 0; i < 3; i++ {
+			replica, err := who(client)
+			require.NoError(t, err)
+			require.Equal(t, 2, replica)
+		}
+		require.Equal(t, int32(1), resolves.Load())
+	})
+
+	t.Run("health checks", func(t *testing.T) {
+		replicas[0].healthy.Store(false)
+		defer replicas[0].healthy.Store(true)
+		client := api2.NewClient(routes, "", api2.LoadBalance(&api2.LoadBalancer{
+			Endpoints: endpoints,
+			HealthCheck: &api2.HealthCheck{
+				Path:     "/health",
+				Interval: 10 * time.Millisecond,
+			},
+		}))
+		defer client.Close()
+		require.Eventually(t, func() bool {
+			for i := 0; i < 6; i++ {
+				replica, err := who(client)
+				require.NoError(t, err)
+				if replica == 0 {
+					return false
+				}
+			}
+			return true
+		}, time.Second, 10*time.Millisecond)
+	})
+
+	t.Run("failover and ejection", func(t *testing.T) {
+		down := httptest.NewServer(http.NotFoundHandler())
+		down.Close()
+		client := api2.NewClient(routes, "", api2.LoadBalance(&api2.LoadBalancer{
+			Endpoints:    []string{down.URL, endpoints[1]},
+			MaxFailures:  2,
+			EjectionTime: time.Hour,
+		}))
+		defer client.Close()
+
+		// The idempotent route fails over to the live endpoint.
+		for i := 0; i < 4; i++ {
+			replica, err := who(client)
+			require.NoError(t, err)
+			require.Equal(t, 1, replica)
+		}
+
+		// The endpoint is ejected, so POST requests don't reach it.
+		for i := 0; i < 4; i++ {
+			res, err := api2.Call[CreateRequest, CreateResponse](ctx, client, &CreateRequest{})
+			require.NoError(t, err)
+			require.Equal(t, 1, res.Replica)
+		}
+	})
+
+	t.Run("no failover for non-idempotent routes", func(t *testing.T) {
+		down := httptest.NewServer(http.NotFoundHandler())
+		down.Close()
+		client := api2.NewClient(routes, "", api2.LoadBalance(&api2.LoadBalancer{
+			Endpoints: []string{down.URL, endpoints[1]},
+		}))
+		defer client.Close()
+		var failures int
+		for i := 0; i < 4; i++ {
+			if _, err := api2.Call[CreateRequest, CreateResponse](ctx, client, &CreateRequest{}); err != nil {
+				failures++
+			}
+		}
+		require.Equal(t, 2, failures)
+	})
+	t.Run("no failover after connecting", func(t *testing.T) {
+		// The endpoint accepts connections and closes them.
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		t.Cleanup(func() {
+			listener.Close()
+		})
+		var accepted atomic.Int32
+		go func() {
+			for {
+				conn, err := listener.Accept()
+				if err != nil {
+					return
+				}
+				accepted.Add(1)
+				conn.Close()
+			}
+		}()
+
+		client := api2.NewClient(routes, "", api2.LoadBalance(&api2.LoadBalancer{
+			Endpoints:   []string{"http://" + listener.Addr().String(), endpoints[1]},
+			MaxFailures: 100,
+		}))
+		defer client.Close()
+		var failures int
+		for i := 0; i < 4; i++ {
+			if _, err := who(client); err != nil {
+				failures++
+			}
+		}
+		// The request may have reached the server, so it is not resent.
+		require.Equal(t, 2, failures)
+		require.Equal(t, int32(2), accepted.Load())
+	})
+}
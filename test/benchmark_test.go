@@ -1,7 +1,9 @@
 package api2
 
 import (
+	"bytes"
 	"context"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"strconv"
@@ -60,3 +62,113 @@ func BenchmarkAPI(b *testing.B) {
 		}
 	}
 }
+
+func BenchmarkRouter(b *testing.B) {
+	type ListRequest struct {
+	}
+	type DetailsRequest struct {
+		ID string `url:"id"`
+	}
+	type Response struct {
+	}
+	listHandler := func(ctx context.Context, req *ListRequest) (*Response, error) {
+		return &Response{}, nil
+	}
+	detailsHandler := func(ctx context.Context, req *DetailsRequest) (*Response, error) {
+		return &Response{}, nil
+	}
+
+	var routes []api2.Route
+	for i := 0; i < 100; i++ {
+		routes = append(routes,
+			api2.Route{Method: http.MethodGet, Path: "/static" + strconv.Itoa(i) + "/list", Handler: listHandler},
+			api2.Route{Method: http.MethodGet, Path: "/param" + strconv.Itoa(i) + "/:id/details", Handler: detailsHandler},
+		)
+	}
+
+	mux := http.NewServeMux()
+	api2.BindRoutes(mux, routes)
+
+	for _, path := range []string{"/static99/list", "/param99/abc/details"} {
+		b.Run(path, func(b *testing.B) {
+			req := httptest.NewRequest(http.MethodGet, path, nil)
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				w := httptest.NewRecorder()
+				mux.ServeHTTP(w, req)
+				if w.Code != http.StatusOK {
+					b.Fatalf("unexpected status %d", w.Code)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkMixedFields(b *testing.B) {
+	type Request struct {
+		ID      int      `url:"id"`
+		Limit   int      `query:"limit"`
+		Cursor  string   `query:"cursor"`
+		Token   string   `header:"X-Token"`
+		Name    string   `json:"name"`
+		Tags    []string `json:"tags,omitempty"`
+		Counter int      `json:"counter"`
+	}
+	type Response struct {
+		Total int    `header:"X-Total"`
+		Name  string `json:"name"`
+		Count int    `json:"count"`
+	}
+	handler := func(ctx context.Context, req *Request) (*Response, error) {
+		return &Response{Total: req.Limit, Name: req.Name, Count: req.Counter}, nil
+	}
+	routes := []api2.Route{
+		{Method: http.MethodPost, Path: "/items/:id", Handler: handler},
+	}
+
+	req := &Request{
+		ID:      42,
+		Limit:   10,
+		Cursor:  "abc",
+		Token:   "secret",
+		Name:    "item",
+		Tags:    []string{"a", "b"},
+		Counter: 7,
+	}
+	transport := &api2.JsonTransport{}
+	ctx := context.Background()
+
+	b.Run("EncodeRequest", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			if _, err := transport.EncodeRequest(ctx, http.MethodPost, "http://example.com/items/:id", req); err != nil {
+				b.Fatalf("EncodeRequest failed: %v", err)
+			}
+		}
+	})
+
+	b.Run("Server", func(b *testing.B) {
+		mux := http.NewServeMux()
+		api2.BindRoutes(mux, routes)
+		httpReq, err := transport.EncodeRequest(ctx, http.MethodPost, "http://example.com/items/:id", req)
+		if err != nil {
+			b.Fatalf("EncodeRequest failed: %v", err)
+		}
+		body, err := io.ReadAll(httpReq.Body)
+		if err != nil {
+			b.Fatalf("failed to read body: %v", err)
+		}
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			r := httptest.NewRequest(http.MethodPost, httpReq.URL.String(), bytes.NewReader(body))
+			r.Header = httpReq.Header
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, r)
+			if w.Code != http.StatusOK {
+				b.Fatalf("unexpected status %d", w.Code)
+			}
+		}
+	})
+}
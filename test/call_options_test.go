@@ -0,0 +1,139 @@
+package api2
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/starius/api2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCallOptions(t *testing.T) {
+	type EchoRequest struct {
+		Text string `json:"text"`
+	}
+	type EchoResponse struct {
+		Text string `json:"text"`
+	}
+	type SleepRequest struct {
+		Duration time.Duration `query:"duration"`
+	}
+	type SleepResponse struct {
+	}
+	type StreamRequest struct {
+	}
+	type StreamResponse struct {
+		Body io.ReadCloser `use_as_body:"true" is_stream:"true"`
+	}
+
+	routes := []api2.Route{
+		api2.NewRoute(http.MethodPost, "/echo", func(ctx context.Context, req *EchoRequest) (*EchoResponse, error) {
+			return &EchoResponse{Text: req.Text}, nil
+		}),
+		api2.NewRoute(http.MethodGet, "/sleep", func(ctx context.Context, req *SleepRequest) (*SleepResponse, error) {
+			select {
+			case <-ctx.Done():
+			case <-time.After(req.Duration):
+			}
+			return &SleepResponse{}, nil
+		}),
+		api2.NewRoute(http.MethodGet, "/stream", func(ctx context.Context, req *StreamRequest) (*StreamResponse, error) {
+			return &StreamResponse{Body: io.NopCloser(strings.NewReader("data"))}, nil
+		}),
+	}
+
+	mux := http.NewServeMux()
+	api2.BindRoutes(mux, routes, api2.ErrorLogger(func(format string, args ...interface{}) {}))
+	var mu sync.Mutex
+	var lastRequest *http.Request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		lastRequest = r
+		mu.Unlock()
+		mux.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+	getLastRequest := func() *http.Request {
+		mu.Lock()
+		defer mu.Unlock()
+		return lastRequest
+	}
+
+	client := api2.NewClient(routes, server.URL, api2.AuthorizationHeader("Bearer default"))
+	ctx := context.Background()
+
+	t.Run("defaults", func(t *testing.T) {
+		res := &EchoResponse{}
+		require.NoError(t, client.Call(ctx, res, &EchoRequest{Text: "hi"}))
+		require.Equal(t, "hi", res.Text)
+		r := getLastRequest()
+		require.Equal(t, "Bearer default", r.Header.Get("Authorization"))
+		require.Empty(t, r.URL.RawQuery)
+	})
+
+	t.Run("headers and authorization", func(t *testing.T) {
+		res := &EchoResponse{}
+		require.NoError(t, client.Call(ctx, res, &EchoRequest{Text: "hi"},
+			api2.CallHeader("X-Request-Id", "42"),
+			api2.CallAuthorization("Bearer other"),
+			api2.CallHuman(true),
+		))
+		r := getLastRequest()
+		require.Equal(t, "42", r.Header.Get("X-Request-Id"))
+		require.Equal(t, "Bearer other", r.Header.Get("Authorization"))
+		require.Equal(t, "human=on", r.URL.RawQuery)
+
+		_, err := api2.Call[EchoRequest, EchoResponse](ctx, client, &EchoRequest{}, api2.CallAuthorization(""))
+		require.NoError(t, err)
+		require.Empty(t, getLastRequest().Header.Get("Authorization"))
+	})
+
+	t.Run("context", func(t *testing.T) {
+		// This is how options are passed to methods of generated clients.
+		ctx := api2.WithCallOptions(ctx, api2.CallHeader("X-Request-Id", "1"))
+		endpoint, err := api2.NewEndpoint[EchoRequest, EchoResponse](client)
+		require.NoError(t, err)
+		_, err = endpoint.Call(ctx, &EchoRequest{})
+		require.NoError(t, err)
+		require.Equal(t, "1", getLastRequest().Header.Get("X-Request-Id"))
+
+		// Options of the call are applied after options of the context.
+		_, err = endpoint.Call(ctx, &EchoRequest{}, api2.CallHeader("X-Request-Id", "2"), api2.CallAuthorization("Bearer 2"))
+		require.NoError(t, err)
+		require.Equal(t, []string{"1", "2"}, getLastRequest().Header.Values("X-Request-Id"))
+		require.Equal(t, "Bearer 2", getLastRequest().Header.Get("Authorization"))
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		_, err := api2.Call[SleepRequest, SleepResponse](ctx, client, &SleepRequest{Duration: time.Minute}, api2.CallTimeout(50*time.Millisecond))
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+
+		_, err = api2.Call[SleepRequest, SleepResponse](ctx, client, &SleepRequest{Duration: time.Millisecond}, api2.CallTimeout(time.Second))
+		require.NoError(t, err)
+	})
+
+	t.Run("stream", func(t *testing.T) {
+		// The streamed response body is not closed by the client.
+		res, err := api2.Call[StreamRequest, StreamResponse](ctx, client, &StreamRequest{})
+		require.NoError(t, err)
+		data, err := io.ReadAll(res.Body)
+		require.NoError(t, err)
+		require.Equal(t, "data", string(data))
+		require.NoError(t, res.Body.Close())
+	})
+
+	t.Run("timeout of stream", func(t *testing.T) {
+		res, err := api2.Call[StreamRequest, StreamResponse](ctx, client, &StreamRequest{}, api2.CallTimeout(time.Second))
+		require.NoError(t, err)
+		data, err := io.ReadAll(res.Body)
+		require.NoError(t, err)
+		require.Equal(t, "data", string(data))
+		require.NoError(t, res.Body.Close())
+	})
+}
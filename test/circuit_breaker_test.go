@@ -0,0 +1,200 @@
+package api2
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/starius/api2"
+	"github.com/starius/api2/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	type PingRequest struct {
+	}
+	type PingResponse struct {
+	}
+	type EchoRequest struct {
+		Text string `json:"text"`
+	}
+	type EchoResponse struct {
+		Text string `json:"text"`
+	}
+
+	var failing atomic.Bool
+	var calls atomic.Int32
+	routes := []api2.Route{
+		api2.NewRoute(http.MethodGet, "/ping", func(ctx context.Context, req *PingRequest) (*PingResponse, error) {
+			calls.Add(1)
+			if failing.Load() {
+				return nil, errors.Internal("broken")
+			}
+			return &PingResponse{}, nil
+		}),
+		api2.NewRoute(http.MethodPost, "/echo", func(ctx context.Context, req *EchoRequest) (*EchoResponse, error) {
+			calls.Add(1)
+			if req.Text == "" {
+				return nil, errors.InvalidArgument("empty text")
+			}
+			return &EchoResponse{Text: req.Text}, nil
+		}),
+	}
+
+	mux := http.NewServeMux()
+	api2.BindRoutes(mux, routes, api2.ErrorLogger(func(format string, args ...interface{}) {}))
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	var mu sync.Mutex
+	var transitions []string
+	policy := &api2.CircuitBreakerPolicy{
+		FailureRatio:     0.5,
+		MinRequests:      3,
+		Window:           time.Minute,
+		Cooldown:         100 * time.Millisecond,
+		HalfOpenRequests: 1,
+		OnStateChange: func(name string, from, to api2.CircuitState) {
+			mu.Lock()
+			defer mu.Unlock()
+			transitions = append(transitions, name+": "+from.String()+" -> "+to.String())
+		},
+	}
+	client := api2.NewClient(routes, server.URL, api2.CircuitBreaker(policy))
+	ctx := context.Background()
+	host := strings.TrimPrefix(server.URL, "http://")
+	getTransitions := func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		res := transitions
+		transitions = nil
+		return res
+	}
+
+	// Client errors (4xx) are not failures.
+	for i := 0; i < 5; i++ {
+		_, err := api2.Call[EchoRequest, EchoResponse](ctx, client, &EchoRequest{})
+		require.ErrorContains(t, err, "empty text")
+	}
+	require.Empty(t, getTransitions())
+
+	failing.Store(true)
+	for i := 0; i < 3; i++ {
+		_, err := api2.Call[PingRequest, PingResponse](ctx, client, &PingRequest{})
+		require.ErrorContains(t, err, "broken")
+	}
+	require.Equal(t, []string{
+		"route GET /ping: closed -> open",
+	}, getTransitions())
+
+	// The route fails fast.
+	calls.Store(0)
+	_, err := api2.Call[PingRequest, PingResponse](ctx, client, &PingRequest{})
+	var openErr *api2.CircuitOpenError
+	require.ErrorAs(t, err, &openErr)
+	require.Equal(t, "route GET /ping", openErr.Name)
+	require.Equal(t, http.StatusServiceUnavailable, openErr.HttpCode())
+	require.Equal(t, int32(0), calls.Load())
+
+	// Other routes of the host still work: 5 of 8 calls succeeded.
+	res, err := api2.Call[EchoRequest, EchoResponse](ctx, client, &EchoRequest{Text: "hi"})
+	require.NoError(t, err)
+	require.Equal(t, "hi", res.Text)
+
+	// After the cooldown a trial call is made. It fails and opens the route again.
+	time.Sleep(150 * time.Millisecond)
+	_, err = api2.Call[PingRequest, PingResponse](ctx, client, &PingRequest{})
+	require.ErrorContains(t, err, "broken")
+	require.Equal(t, []string{
+		"route GET /ping: open -> half-open",
+		"route GET /ping: half-open -> open",
+	}, getTransitions())
+
+	// The service recovers.
+	failing.Store(false)
+	time.Sleep(150 * time.Millisecond)
+	_, err = api2.Call[PingRequest, PingResponse](ctx, client, &PingRequest{})
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		"route GET /ping: open -> half-open",
+		"route GET /ping: half-open -> closed",
+	}, getTransitions())
+
+	// The host breaker opens if the server is down.
+	server.Close()
+	for i := 0; i < 3; i++ {
+		_, err := api2.Call[EchoRequest, EchoResponse](ctx, client, &EchoRequest{Text: "hi"})
+		require.Error(t, err)
+	}
+	require.Contains(t, getTransitions(), "host "+host+": closed -> open")
+	_, err = api2.Call[EchoRequest, EchoResponse](ctx, client, &EchoRequest{Text: "hi"})
+	require.ErrorAs(t, err, &openErr)
+}
+
+func TestCircuitBreakerPerEndpoint(t *testing.T) {
+	type PingRequest struct {
+	}
+	type PingResponse struct {
+		Replica int `json:"replica"`
+	}
+
+	var endpoints []string
+	for i := 0; i < 2; i++ {
+		routes := []api2.Route{
+			api2.NewRoute(http.MethodGet, "/ping", func(ctx context.Context, req *PingRequest) (*PingResponse, error) {
+				if i == 1 {
+					return nil, errors.Internal("broken replica")
+				}
+				return &PingResponse{Replica: i}, nil
+			}),
+		}
+		mux := http.NewServeMux()
+		api2.BindRoutes(mux, routes, api2.ErrorLogger(func(format string, args ...interface{}) {}))
+		server := httptest.NewServer(mux)
+		t.Cleanup(server.Close)
+		endpoints = append(endpoints, server.URL)
+	}
+
+	var mu sync.Mutex
+	var opened []string
+	client := api2.NewClient([]api2.Route{
+		api2.NewRoute[PingRequest, PingResponse](http.MethodGet, "/ping", nil),
+	}, "", api2.LoadBalance(&api2.LoadBalancer{
+		Endpoints:   endpoints,
+		MaxFailures: 1000,
+	}), api2.CircuitBreaker(&api2.CircuitBreakerPolicy{
+		FailureRatio:     0.9,
+		MinRequests:      3,
+		Window:           time.Minute,
+		Cooldown:         time.Minute,
+		HalfOpenRequests: 1,
+		OnStateChange: func(name string, from, to api2.CircuitState) {
+			if to == api2.CircuitOpen {
+				mu.Lock()
+				opened = append(opened, name)
+				mu.Unlock()
+			}
+		},
+	}))
+	defer client.Close()
+
+	// The broken replica opens only its own breaker after 3 failures.
+	failures := 0
+	for i := 0; i < 10; i++ {
+		res, err := api2.Call[PingRequest, PingResponse](context.Background(), client, &PingRequest{})
+		if err != nil {
+			failures++
+			continue
+		}
+		require.Equal(t, 0, res.Replica)
+	}
+	require.Equal(t, 3, failures)
+	mu.Lock()
+	defer mu.Unlock()
+	require.Equal(t, []string{"host " + strings.TrimPrefix(endpoints[1], "http://")}, opened)
+}
@@ -0,0 +1,127 @@
+package api2
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/starius/api2"
+	"github.com/starius/api2/clientcredentials"
+	"github.com/starius/api2/closingclient"
+	"github.com/starius/api2/debugclient"
+	"github.com/starius/api2/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCredentials(t *testing.T) {
+	type WhoamiRequest struct {
+		Authorization string `header:"Authorization"`
+		Text          string `json:"text"`
+	}
+	type WhoamiResponse struct {
+		Token string `json:"token"`
+		Text  string `json:"text"`
+	}
+
+	// The token server issues tokens "t1", "t2", ...
+	// The API server accepts only the latest one.
+	var issued, tokenRequests atomic.Int32
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tokenRequests.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": fmt.Sprintf("t%d", issued.Add(1)),
+			"token_type":   "Bearer",
+			"expires_in":   3600,
+		})
+	}))
+	t.Cleanup(tokenServer.Close)
+
+	var calls atomic.Int32
+	routes := []api2.Route{
+		api2.NewRoute(http.MethodPost, "/whoami", func(ctx context.Context, req *WhoamiRequest) (*WhoamiResponse, error) {
+			calls.Add(1)
+			token := strings.TrimPrefix(req.Authorization, "Bearer ")
+			if token != fmt.Sprintf("t%d", issued.Load()) {
+				return nil, errors.Unauthenticated("bad token %q", token)
+			}
+			return &WhoamiResponse{Token: token, Text: req.Text}, nil
+		}),
+	}
+	mux := http.NewServeMux()
+	api2.BindRoutes(mux, routes, api2.ErrorLogger(func(format string, args ...interface{}) {}))
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	var log bytes.Buffer
+	debugClient, err := debugclient.New(http.DefaultClient, &log)
+	require.NoError(t, err)
+	closingClient, err := closingclient.New(debugClient)
+	require.NoError(t, err)
+
+	// Tokens are requested through the wrapped client as well.
+	provider, err := clientcredentials.New(clientcredentials.Config{
+		TokenURL:     tokenServer.URL,
+		ClientID:     "id",
+		ClientSecret: "secret",
+		HttpClient:   closingClient,
+	})
+	require.NoError(t, err)
+
+	client := api2.NewClient(routes, server.URL,
+		api2.CustomClient(closingClient),
+		api2.AuthorizationHeader("Bearer static"),
+		api2.Credentials(provider),
+	)
+	t.Cleanup(func() {
+		require.NoError(t, client.Close())
+	})
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		res, err := api2.Call[WhoamiRequest, WhoamiResponse](ctx, client, &WhoamiRequest{Text: "hi"})
+		require.NoError(t, err)
+		require.Equal(t, "t1", res.Token)
+		require.Equal(t, "hi", res.Text)
+	}
+	require.Equal(t, int32(1), tokenRequests.Load())
+	require.Equal(t, int32(3), calls.Load())
+	require.Contains(t, log.String(), "Authorization: Bearer t1")
+
+	// The token is revoked: the call is repeated with a new token.
+	issued.Add(1)
+	calls.Store(0)
+	res, err := api2.Call[WhoamiRequest, WhoamiResponse](ctx, client, &WhoamiRequest{Text: "again"})
+	require.NoError(t, err)
+	require.Equal(t, "t3", res.Token)
+	require.Equal(t, "again", res.Text)
+	require.Equal(t, int32(2), tokenRequests.Load())
+	require.Equal(t, int32(2), calls.Load())
+
+	// Only one retry.
+	t.Run("rejected twice", func(t *testing.T) {
+		provider := api2.CacheCredentials(func(ctx context.Context) (string, time.Time, error) {
+			return "Bearer wrong", time.Time{}, nil
+		})
+		client := api2.NewClient(routes, server.URL, api2.Credentials(provider))
+		calls.Store(0)
+		_, err := api2.Call[WhoamiRequest, WhoamiResponse](ctx, client, &WhoamiRequest{})
+		require.ErrorContains(t, err, "bad token")
+		require.Equal(t, int32(2), calls.Load())
+	})
+
+	// Authorization of the call overrides the provider.
+	t.Run("call authorization", func(t *testing.T) {
+		calls.Store(0)
+		_, err := api2.Call[WhoamiRequest, WhoamiResponse](ctx, client, &WhoamiRequest{}, api2.CallAuthorization("Bearer other"))
+		require.ErrorContains(t, err, "bad token \"other\"")
+		require.Equal(t, int32(1), calls.Load())
+	})
+}
@@ -0,0 +1,149 @@
+package api2
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/starius/api2"
+	"github.com/starius/api2/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDirectClient(t *testing.T) {
+	type GreetRequest struct {
+		Name  string `url:"name"`
+		Lang  string `query:"lang"`
+		Trace string `header:"X-Trace"`
+	}
+	type GreetResponse struct {
+		Text string `json:"text"`
+	}
+	type SlowRequest struct {
+	}
+	type SlowResponse struct {
+	}
+	type DownloadRequest struct {
+	}
+	type DownloadResponse struct {
+		Body io.ReadCloser `use_as_body:"true" is_stream:"true"`
+	}
+
+	release := make(chan struct{})
+	routes := []api2.Route{
+		api2.NewRoute(http.MethodGet, "/greet/:name", func(ctx context.Context, req *GreetRequest) (*GreetResponse, error) {
+			if req.Lang == "" {
+				return nil, errors.InvalidArgument("no lang")
+			}
+			return &GreetResponse{Text: req.Lang + ": hello " + req.Name + req.Trace}, nil
+		}),
+		api2.NewRoute(http.MethodGet, "/slow", func(ctx context.Context, req *SlowRequest) (*SlowResponse, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		}),
+		api2.NewRoute(http.MethodGet, "/download", func(ctx context.Context, req *DownloadRequest) (*DownloadResponse, error) {
+			pr, pw := io.Pipe()
+			go func() {
+				_, _ = io.WriteString(pw, "first;")
+				<-release
+				_, _ = io.WriteString(pw, "second")
+				pw.Close()
+			}()
+			return &DownloadResponse{Body: pr}, nil
+		}),
+	}
+
+	var middlewareCalls int
+	middleware := func(ctx context.Context, req any, next api2.Handler) (any, any) {
+		middlewareCalls++
+		return next(ctx, req)
+	}
+
+	for _, mode := range []api2.DirectMode{api2.DirectWire, api2.DirectFast} {
+		t.Run(fmt.Sprintf("mode %d", mode), func(t *testing.T) {
+			middlewareCalls = 0
+			client := api2.NewDirectClient(routes, mode,
+				api2.AddMiddleware(middleware),
+				api2.ErrorLogger(func(format string, args ...interface{}) {}),
+			)
+			t.Cleanup(func() {
+				require.NoError(t, client.Close())
+			})
+			ctx := context.Background()
+
+			res, err := api2.Call[GreetRequest, GreetResponse](ctx, client, &GreetRequest{Name: "Bob", Lang: "en"})
+			require.NoError(t, err)
+			require.Equal(t, "en: hello Bob", res.Text)
+			require.Equal(t, 1, middlewareCalls)
+
+			_, err = api2.Call[GreetRequest, GreetResponse](ctx, client, &GreetRequest{Name: "Bob"})
+			require.ErrorContains(t, err, "no lang")
+
+			_, err = api2.Call[SlowRequest, SlowResponse](ctx, client, &SlowRequest{}, api2.CallTimeout(10*time.Millisecond))
+			require.ErrorContains(t, err, "deadline exceeded")
+
+			res, err = api2.Call[GreetRequest, GreetResponse](ctx, client, &GreetRequest{Name: "Bob", Lang: "en"}, api2.CallHeader("X-Trace", "!"))
+			if mode == api2.DirectWire {
+				require.NoError(t, err)
+				require.Equal(t, "en: hello Bob!", res.Text)
+			} else {
+				require.ErrorContains(t, err, "CallHeader is not supported")
+			}
+		})
+	}
+
+	t.Run("fast mode calls the route passed by interceptor", func(t *testing.T) {
+		client := api2.NewDirectClient(routes, api2.DirectFast, api2.AddInterceptor(func(ctx context.Context, route *api2.Route, req, res any, next api2.Invoker) error {
+			route.Path = "/missing"
+			return next(ctx, route, req, res)
+		}))
+		_, err := api2.Call[GreetRequest, GreetResponse](context.Background(), client, &GreetRequest{Name: "Bob", Lang: "en"})
+		require.ErrorContains(t, err, "route GET /missing is not served by the direct client")
+	})
+
+	t.Run("wire mode streams responses", func(t *testing.T) {
+		client := api2.NewDirectClient(routes, api2.DirectWire)
+		res, err := api2.Call[DownloadRequest, DownloadResponse](context.Background(), client, &DownloadRequest{})
+		require.NoError(t, err)
+
+		// The first part arrives before the handler finishes the stream.
+		first := make([]byte, len("first;"))
+		_, err = io.ReadFull(res.Body, first)
+		require.NoError(t, err)
+		require.Equal(t, "first;", string(first))
+
+		close(release)
+		rest, err := io.ReadAll(res.Body)
+		require.NoError(t, err)
+		require.Equal(t, "second", string(rest))
+		require.NoError(t, res.Body.Close())
+	})
+
+	t.Run("fast mode passes objects", func(t *testing.T) {
+		type EchoRequest struct {
+			Body io.ReadCloser `use_as_body:"true" is_stream:"true"`
+		}
+		type EchoResponse struct {
+			Body io.ReadCloser `use_as_body:"true" is_stream:"true"`
+		}
+		var gotReq *EchoRequest
+		routes := []api2.Route{
+			api2.NewRoute(http.MethodPost, "/echo", func(ctx context.Context, req *EchoRequest) (*EchoResponse, error) {
+				gotReq = req
+				return &EchoResponse{Body: req.Body}, nil
+			}),
+		}
+		client := api2.NewDirectClient(routes, api2.DirectFast)
+		req := &EchoRequest{Body: io.NopCloser(strings.NewReader("data"))}
+		res, err := api2.Call[EchoRequest, EchoResponse](context.Background(), client, req)
+		require.NoError(t, err)
+		require.Same(t, req, gotReq)
+		data, err := io.ReadAll(res.Body)
+		require.NoError(t, err)
+		require.Equal(t, "data", string(data))
+	})
+}
@@ -0,0 +1,224 @@
+package api2
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/starius/api2"
+	"github.com/stretchr/testify/require"
+)
+
+// syncBuffer is bytes.Buffer safe for concurrent use.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestDumpRequests(t *testing.T) {
+	type LoginRequest struct {
+		User     string `json:"user"`
+		Password string `json:"password"`
+	}
+	type LoginResponse struct {
+		Token string `json:"token"`
+	}
+	type PingRequest struct {
+	}
+	type PingResponse struct {
+	}
+	type UploadRequest struct {
+		Body io.ReadCloser `use_as_body:"true" is_stream:"true"`
+	}
+	type UploadResponse struct {
+		Size int `json:"size"`
+	}
+	type DownloadRequest struct {
+	}
+	type DownloadResponse struct {
+		Body io.ReadCloser `use_as_body:"true" is_stream:"true"`
+	}
+
+	// The streams are larger than MaxBodySize: only prefixes are kept.
+	data := strings.Repeat("0123456789", 100000)
+
+	routes := []api2.Route{
+		api2.NewRoute(http.MethodPost, "/login", func(ctx context.Context, req *LoginRequest) (*LoginResponse, error) {
+			return &LoginResponse{Token: "tok-" + req.User}, nil
+		}),
+		api2.NewRoute(http.MethodGet, "/ping", func(ctx context.Context, req *PingRequest) (*PingResponse, error) {
+			return &PingResponse{}, nil
+		}, api2.RouteMeta(api2.DumpMetaKey, false)),
+		api2.NewRoute(http.MethodPost, "/upload", func(ctx context.Context, req *UploadRequest) (*UploadResponse, error) {
+			got, err := io.ReadAll(req.Body)
+			if err != nil {
+				return nil, err
+			}
+			return &UploadResponse{Size: len(got)}, nil
+		}),
+		api2.NewRoute(http.MethodGet, "/download", func(ctx context.Context, req *DownloadRequest) (*DownloadResponse, error) {
+			return &DownloadResponse{Body: io.NopCloser(strings.NewReader(data))}, nil
+		}),
+	}
+
+	var log syncBuffer
+	mux := http.NewServeMux()
+	api2.BindRoutes(mux, routes, api2.DumpRequests(&api2.DumpPolicy{
+		Log:              &log,
+		BaseURL:          "https://api.example.com/",
+		RedactHeaders:    []string{"Authorization"},
+		RedactJSONFields: []string{"password", "token"},
+		MaxBodySize:      1000,
+	}))
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	client := api2.NewClient(routes, server.URL, api2.AuthorizationHeader("Bearer secret"))
+	ctx := context.Background()
+
+	res, err := api2.Call[LoginRequest, LoginResponse](ctx, client, &LoginRequest{User: "alice", Password: "pass"})
+	require.NoError(t, err)
+	require.Equal(t, "tok-alice", res.Token)
+
+	_, err = api2.Call[PingRequest, PingResponse](ctx, client, &PingRequest{})
+	require.NoError(t, err)
+
+	upload, err := api2.Call[UploadRequest, UploadResponse](ctx, client, &UploadRequest{Body: io.NopCloser(strings.NewReader(data))})
+	require.NoError(t, err)
+	require.Equal(t, len(data), upload.Size)
+
+	download, err := api2.Call[DownloadRequest, DownloadResponse](ctx, client, &DownloadRequest{})
+	require.NoError(t, err)
+	got, err := io.ReadAll(download.Body)
+	require.NoError(t, err)
+	require.NoError(t, download.Body.Close())
+	require.Equal(t, data, string(got))
+
+	// Replace non-deterministic elements.
+	re := regexp.MustCompile(`Date: [^\r\n]+\r\n|-H 'Accept-Encoding: gzip' |-H 'Content-Length: [0-9]+' |-H 'User-Agent: [^']+' |Accept-Encoding: gzip\r\n|User-Agent: [^\r\n]+\r\n`)
+	gotLog := re.ReplaceAllString(log.String(), "")
+
+	prefix := strings.Repeat("0123456789", 100)
+	wantLog := "=== server request 1 ===\n" +
+		"$ curl -X 'POST' -d '{\"password\":\"REDACTED\",\"user\":\"alice\"}\n' -H 'Accept: application/json' -H 'Authorization: REDACTED' -H 'Content-Type: application/json; charset=UTF-8' 'https://api.example.com/login'\n" +
+		"=== end of server request 1 ===\n" +
+		"=== server response 1 ===\n" +
+		"HTTP/1.1 200 OK\r\nContent-Type: application/json; charset=UTF-8\r\n\r\n{\"token\":\"REDACTED\"}\n\n" +
+		"=== end of server response 1 ===\n" +
+		// The truncated body can not be replayed.
+		"=== server request 2 (incomplete) ===\n" +
+		"POST https://api.example.com/upload HTTP/1.1\r\nAccept: application/json\r\nAuthorization: REDACTED\r\nContent-Type: application/json; charset=UTF-8\r\n\r\n" + prefix + "\n" +
+		"... (truncated, 1000000 bytes total)\n... (stream)\n" +
+		"=== end of server request 2 ===\n" +
+		"=== server response 2 ===\n" +
+		"HTTP/1.1 200 OK\r\nContent-Type: application/json; charset=UTF-8\r\n\r\n{\"size\":1000000}\n\n" +
+		"=== end of server response 2 ===\n" +
+		"=== server request 3 ===\n" +
+		"$ curl -X 'GET' -d '{}\n' -H 'Accept: application/json' -H 'Authorization: REDACTED' -H 'Content-Type: application/json; charset=UTF-8' 'https://api.example.com/download'\n" +
+		"=== end of server request 3 ===\n" +
+		"=== server response 3 ===\n" +
+		"HTTP/1.1 200 OK\r\nContent-Type: application/json; charset=UTF-8\r\n\r\n" + prefix + "\n... (truncated, 1000000 bytes total)\n... (stream)\n" +
+		"=== end of server response 3 ===\n"
+	require.Equal(t, wantLog, gotLog)
+}
+
+func TestDumpRequestsOptIn(t *testing.T) {
+	type EchoRequest struct {
+		Text string `json:"text"`
+	}
+	type EchoResponse struct {
+		Text string `json:"text"`
+	}
+	type PingRequest struct {
+	}
+	type PingResponse struct {
+	}
+
+	routes := []api2.Route{
+		api2.NewRoute(http.MethodPost, "/echo", func(ctx context.Context, req *EchoRequest) (*EchoResponse, error) {
+			return &EchoResponse{Text: req.Text}, nil
+		}, api2.RouteMeta(api2.DumpMetaKey, true)),
+		api2.NewRoute(http.MethodGet, "/ping", func(ctx context.Context, req *PingRequest) (*PingResponse, error) {
+			return &PingResponse{}, nil
+		}),
+	}
+
+	var log syncBuffer
+	mux := http.NewServeMux()
+	api2.BindRoutes(mux, routes, api2.DumpRequests(&api2.DumpPolicy{
+		Log:   &log,
+		OptIn: true,
+	}))
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	client := api2.NewClient(routes, server.URL)
+	ctx := context.Background()
+
+	_, err := api2.Call[PingRequest, PingResponse](ctx, client, &PingRequest{})
+	require.NoError(t, err)
+	_, err = api2.Call[EchoRequest, EchoResponse](ctx, client, &EchoRequest{Text: "hi"})
+	require.NoError(t, err)
+
+	gotLog := log.String()
+	require.Equal(t, 1, strings.Count(gotLog, "=== server request"))
+	require.Contains(t, gotLog, "-d '{\"text\":\"hi\"}\n'")
+	require.Contains(t, gotLog, "'http://"+strings.TrimPrefix(server.URL, "http://")+"/echo'")
+	require.NotContains(t, gotLog, "/ping")
+}
+
+func TestDumpRequestsPartlyRead(t *testing.T) {
+	type PeekRequest struct {
+		Body io.ReadCloser `use_as_body:"true" is_stream:"true"`
+	}
+	type PeekResponse struct {
+		Head string `json:"head"`
+	}
+
+	routes := []api2.Route{
+		api2.NewRoute(http.MethodPost, "/peek", func(ctx context.Context, req *PeekRequest) (*PeekResponse, error) {
+			head := make([]byte, 4)
+			if _, err := io.ReadFull(req.Body, head); err != nil {
+				return nil, err
+			}
+			return &PeekResponse{Head: string(head)}, nil
+		}),
+	}
+
+	var log syncBuffer
+	mux := http.NewServeMux()
+	api2.BindRoutes(mux, routes, api2.DumpRequests(&api2.DumpPolicy{
+		Log: &log,
+	}))
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	client := api2.NewClient(routes, server.URL)
+	res, err := api2.Call[PeekRequest, PeekResponse](context.Background(), client, &PeekRequest{
+		Body: io.NopCloser(strings.NewReader("abcdefgh")),
+	})
+	require.NoError(t, err)
+	require.Equal(t, "abcd", res.Head)
+
+	gotLog := log.String()
+	require.Contains(t, gotLog, "=== server request 1 (incomplete) ===\nPOST http://"+strings.TrimPrefix(server.URL, "http://")+"/peek HTTP/1.1\r\n")
+	require.NotContains(t, gotLog, "$ curl")
+}
@@ -0,0 +1,67 @@
+package api2
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/starius/api2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGroup(t *testing.T) {
+	type UploadRequest struct {
+		Data string `json:"data"`
+	}
+	type UploadResponse struct {
+		Trace []string `json:"trace"`
+	}
+
+	type traceKey struct{}
+
+	uploadHandler := func(ctx context.Context, req *UploadRequest) (*UploadResponse, error) {
+		trace, _ := ctx.Value(traceKey{}).([]string)
+		return &UploadResponse{Trace: trace}, nil
+	}
+
+	tracing := func(name string) api2.Middleware {
+		return func(ctx context.Context, req any, next api2.Handler) (any, any) {
+			trace, _ := ctx.Value(traceKey{}).([]string)
+			ctx = context.WithValue(ctx, traceKey{}, append(trace, name))
+			return next(ctx, req)
+		}
+	}
+
+	group := &api2.Group{
+		Prefix:     "/v1/admin",
+		Name:       "admin",
+		Middleware: tracing("group"),
+		MaxBody:    100,
+	}
+	routes := group.Routes([]api2.Route{
+		{Method: http.MethodPost, Path: "/upload", Handler: uploadHandler, Middleware: tracing("route")},
+	})
+
+	mux := http.NewServeMux()
+	api2.BindRoutes(mux, routes, api2.AddMiddleware(tracing("server")))
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	client := api2.NewClient(routes, server.URL)
+	ctx := context.Background()
+
+	res := &UploadResponse{}
+	require.NoError(t, client.Call(ctx, res, &UploadRequest{Data: "small"}))
+	require.Equal(t, []string{"server", "group", "route"}, res.Trace)
+
+	httpRes, err := http.Post(server.URL+"/upload", "application/json", strings.NewReader("{}"))
+	require.NoError(t, err)
+	require.NoError(t, httpRes.Body.Close())
+	require.Equal(t, http.StatusNotFound, httpRes.StatusCode)
+
+	err = client.Call(ctx, res, &UploadRequest{Data: strings.Repeat("x", 200)})
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "request body too large")
+}
@@ -0,0 +1,134 @@
+package api2
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/starius/api2"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHedge(t *testing.T) {
+	type GetRequest struct {
+	}
+	type GetResponse struct {
+		Replica int `json:"replica"`
+	}
+	type PostRequest struct {
+	}
+	type PostResponse struct {
+		Replica int `json:"replica"`
+	}
+
+	var calls, canceled [2]atomic.Int32
+	var endpoints []string
+	for i := 0; i < 2; i++ {
+		// Replica 0 is slow.
+		wait := func(ctx context.Context) {
+			calls[i].Add(1)
+			if i != 0 {
+				return
+			}
+			select {
+			case <-ctx.Done():
+				canceled[i].Add(1)
+			case <-time.After(2 * time.Second):
+			}
+		}
+		routes := []api2.Route{
+			api2.NewRoute(http.MethodGet, "/get", func(ctx context.Context, req *GetRequest) (*GetResponse, error) {
+				wait(ctx)
+				return &GetResponse{Replica: i}, nil
+			}),
+			api2.NewRoute(http.MethodPost, "/post", func(ctx context.Context, req *PostRequest) (*PostResponse, error) {
+				wait(ctx)
+				return &PostResponse{Replica: i}, nil
+			}),
+		}
+		mux := http.NewServeMux()
+		api2.BindRoutes(mux, routes)
+		server := httptest.NewServer(mux)
+		t.Cleanup(server.Close)
+		endpoints = append(endpoints, server.URL)
+	}
+
+	routes := []api2.Route{
+		api2.NewRoute[GetRequest, GetResponse](http.MethodGet, "/get", nil, api2.RouteIdempotent()),
+		api2.NewRoute[PostRequest, PostResponse](http.MethodPost, "/post", nil),
+	}
+	ctx := context.Background()
+	reset := func() {
+		for i := range calls {
+			calls[i].Store(0)
+			canceled[i].Store(0)
+		}
+	}
+
+	client := api2.NewClient(routes, "",
+		api2.LoadBalance(&api2.LoadBalancer{
+			Endpoints: endpoints,
+		}),
+		api2.Hedge(&api2.HedgePolicy{
+			Delay: 50 * time.Millisecond,
+		}),
+	)
+	defer client.Close()
+
+	t.Run("idempotent", func(t *testing.T) {
+		reset()
+		for i := 0; i < 4; i++ {
+			start := time.Now()
+			res, err := api2.Call[GetRequest, GetResponse](ctx, client, &GetRequest{})
+			require.NoError(t, err)
+			require.Equal(t, 1, res.Replica)
+			require.Less(t, time.Since(start), time.Second)
+		}
+		// The slow replica got about a half of the first requests, and they
+		// were canceled.
+		require.Eventually(t, func() bool {
+			return canceled[0].Load() == calls[0].Load()
+		}, time.Second, 10*time.Millisecond)
+		require.Greater(t, calls[0].Load(), int32(0))
+	})
+
+	t.Run("not idempotent", func(t *testing.T) {
+		reset()
+		var slow int
+		for i := 0; i < 2; i++ {
+			res, err := api2.Call[PostRequest, PostResponse](ctx, client, &PostRequest{})
+			require.NoError(t, err)
+			if res.Replica == 0 {
+				slow++
+			}
+		}
+		require.Equal(t, 1, slow)
+		require.Equal(t, int32(2), calls[0].Load()+calls[1].Load())
+	})
+
+	t.Run("budget", func(t *testing.T) {
+		client := api2.NewClient(routes, endpoints[0], api2.Hedge(&api2.HedgePolicy{
+			Delay:  10 * time.Millisecond,
+			Budget: 0.01,
+		}))
+		defer client.Close()
+		reset()
+		// The initial 10 tokens allow 10 extra requests.
+		ctx, cancel := context.WithTimeout(ctx, 100*time.Millisecond)
+		defer cancel()
+		for i := 0; i < 15; i++ {
+			go func() {
+				_, _ = api2.Call[GetRequest, GetResponse](ctx, client, &GetRequest{})
+			}()
+		}
+		<-ctx.Done()
+		require.Eventually(t, func() bool {
+			return calls[0].Load() == 25
+		}, time.Second, 10*time.Millisecond)
+		time.Sleep(50 * time.Millisecond)
+		require.Equal(t, int32(25), calls[0].Load())
+	})
+}
@@ -0,0 +1,87 @@
+package api2
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/starius/api2"
+	"github.com/starius/api2/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInterceptors(t *testing.T) {
+	type EchoRequest struct {
+		Token string `header:"X-Token"`
+		Text  string `json:"text"`
+	}
+	type EchoResponse struct {
+		Text string `json:"text"`
+	}
+
+	routes := []api2.Route{
+		api2.NewRoute(http.MethodPost, "/echo", func(ctx context.Context, req *EchoRequest) (*EchoResponse, error) {
+			if req.Token != "secret" {
+				return nil, errors.Unauthenticated("bad token")
+			}
+			return &EchoResponse{Text: req.Text}, nil
+		}),
+	}
+
+	mux := http.NewServeMux()
+	api2.BindRoutes(mux, routes, api2.ErrorLogger(func(format string, args ...interface{}) {}))
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	var log []string
+	logger := func(ctx context.Context, route *api2.Route, req, res any, next api2.Invoker) error {
+		err := next(ctx, route, req, res)
+		log = append(log, fmt.Sprintf("%s %s %T %T %v", route.Method, route.Path, req, res, err))
+		return err
+	}
+	auth := func(ctx context.Context, route *api2.Route, req, res any, next api2.Invoker) error {
+		if r, ok := req.(*EchoRequest); ok && r.Token == "" {
+			r.Token = "secret"
+		}
+		return next(ctx, route, req, res)
+	}
+	upper := func(ctx context.Context, route *api2.Route, req, res any, next api2.Invoker) error {
+		if err := next(ctx, route, req, res); err != nil {
+			return err
+		}
+		r := res.(*EchoResponse)
+		r.Text = "<" + r.Text + ">"
+		return nil
+	}
+
+	client := api2.NewClient(routes, server.URL,
+		api2.AddInterceptor(logger),
+		api2.AddInterceptor(auth),
+		api2.AddInterceptor(upper),
+	)
+	ctx := context.Background()
+
+	res, err := api2.Call[EchoRequest, EchoResponse](ctx, client, &EchoRequest{Text: "hi"})
+	require.NoError(t, err)
+	require.Equal(t, "<hi>", res.Text)
+
+	_, err = api2.Call[EchoRequest, EchoResponse](ctx, client, &EchoRequest{Token: "wrong", Text: "hi"})
+	require.ErrorContains(t, err, "bad token")
+
+	require.Len(t, log, 2)
+	require.Equal(t, "POST /echo *api2.EchoRequest *api2.EchoResponse <nil>", log[0])
+	require.Contains(t, log[1], "bad token")
+
+	t.Run("short circuit", func(t *testing.T) {
+		cached := func(ctx context.Context, route *api2.Route, req, res any, next api2.Invoker) error {
+			res.(*EchoResponse).Text = "cached"
+			return nil
+		}
+		client := api2.NewClient(routes, "http://127.0.0.1:1", api2.AddInterceptor(cached))
+		res := &EchoResponse{}
+		require.NoError(t, client.Call(ctx, res, &EchoRequest{}))
+		require.Equal(t, "cached", res.Text)
+	})
+}
@@ -0,0 +1,153 @@
+package api2
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/starius/api2"
+	"github.com/starius/api2/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetry(t *testing.T) {
+	type PutRequest struct {
+		Key   string `url:"key"`
+		Value string `json:"value"`
+	}
+	type PutResponse struct {
+		Value string `json:"value"`
+	}
+	type PostRequest struct {
+		Value string `json:"value"`
+	}
+	type PostResponse struct {
+		Value string `json:"value"`
+	}
+	type UploadRequest struct {
+		Body io.ReadCloser `use_as_body:"true" is_stream:"true"`
+	}
+	type UploadResponse struct {
+	}
+
+	var failures, calls atomic.Int32
+	var retryAfter atomic.Value
+	retryAfter.Store("")
+	fail := func() error {
+		calls.Add(1)
+		if failures.Load() > 0 {
+			failures.Add(-1)
+			return errors.Unavailable("try later")
+		}
+		return nil
+	}
+
+	routes := []api2.Route{
+		api2.NewRoute(http.MethodPut, "/put/:key", func(ctx context.Context, req *PutRequest) (*PutResponse, error) {
+			if err := fail(); err != nil {
+				return nil, err
+			}
+			return &PutResponse{Value: req.Value}, nil
+		}),
+		api2.NewRoute(http.MethodPost, "/post", func(ctx context.Context, req *PostRequest) (*PostResponse, error) {
+			if err := fail(); err != nil {
+				return nil, err
+			}
+			return &PostResponse{Value: req.Value}, nil
+		}),
+		api2.NewRoute(http.MethodPut, "/upload", func(ctx context.Context, req *UploadRequest) (*UploadResponse, error) {
+			if err := fail(); err != nil {
+				return nil, err
+			}
+			return &UploadResponse{}, nil
+		}),
+	}
+
+	mux := http.NewServeMux()
+	api2.BindRoutes(mux, routes, api2.ErrorLogger(func(format string, args ...interface{}) {}))
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if value := retryAfter.Load().(string); value != "" {
+			w.Header().Set("Retry-After", value)
+		}
+		mux.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	policy := api2.DefaultRetryPolicy()
+	policy.InitialBackoff = time.Millisecond
+	client := api2.NewClient(routes, server.URL, api2.Retry(policy))
+	ctx := context.Background()
+
+	reset := func(n int32) {
+		failures.Store(n)
+		calls.Store(0)
+	}
+
+	t.Run("idempotent method", func(t *testing.T) {
+		reset(2)
+		res, err := api2.Call[PutRequest, PutResponse](ctx, client, &PutRequest{Key: "k", Value: "v"})
+		require.NoError(t, err)
+		require.Equal(t, "v", res.Value)
+		require.Equal(t, int32(3), calls.Load())
+	})
+
+	t.Run("max attempts", func(t *testing.T) {
+		reset(3)
+		_, err := api2.Call[PutRequest, PutResponse](ctx, client, &PutRequest{Key: "k", Value: "v"})
+		require.ErrorContains(t, err, "try later")
+		require.Equal(t, int32(3), calls.Load())
+	})
+
+	t.Run("non-idempotent method", func(t *testing.T) {
+		reset(1)
+		_, err := api2.Call[PostRequest, PostResponse](ctx, client, &PostRequest{Value: "v"})
+		require.ErrorContains(t, err, "try later")
+		require.Equal(t, int32(1), calls.Load())
+
+		// The same route marked idempotent is retried.
+		routes2 := []api2.Route{routes[1]}
+		routes2[0].Idempotent = true
+		client2 := api2.NewClient(routes2, server.URL, api2.Retry(policy))
+		reset(1)
+		res, err := api2.Call[PostRequest, PostResponse](ctx, client2, &PostRequest{Value: "v"})
+		require.NoError(t, err)
+		require.Equal(t, "v", res.Value)
+		require.Equal(t, int32(2), calls.Load())
+	})
+
+	t.Run("stream", func(t *testing.T) {
+		reset(1)
+		_, err := api2.Call[UploadRequest, UploadResponse](ctx, client, &UploadRequest{Body: io.NopCloser(strings.NewReader("data"))})
+		require.ErrorContains(t, err, "try later")
+		require.Equal(t, int32(1), calls.Load())
+	})
+
+	t.Run("Retry-After beyond deadline", func(t *testing.T) {
+		retryAfter.Store("10")
+		defer retryAfter.Store("")
+		reset(1)
+		ctx, cancel := context.WithTimeout(ctx, time.Second)
+		defer cancel()
+		start := time.Now()
+		_, err := api2.Call[PutRequest, PutResponse](ctx, client, &PutRequest{Key: "k", Value: "v"})
+		require.ErrorContains(t, err, "try later")
+		require.Equal(t, int32(1), calls.Load())
+		require.Less(t, time.Since(start), time.Second)
+	})
+
+	t.Run("Retry-After", func(t *testing.T) {
+		retryAfter.Store("1")
+		defer retryAfter.Store("")
+		reset(1)
+		start := time.Now()
+		_, err := api2.Call[PutRequest, PutResponse](ctx, client, &PutRequest{Key: "k", Value: "v"})
+		require.NoError(t, err)
+		require.Equal(t, int32(2), calls.Load())
+		require.GreaterOrEqual(t, time.Since(start), time.Second)
+	})
+}
@@ -0,0 +1,142 @@
+package api2
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/starius/api2"
+	"github.com/starius/api2/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignedRequests(t *testing.T) {
+	type PutRequest struct {
+		Key   string `url:"key"`
+		Value string `json:"value"`
+	}
+	type PutResponse struct {
+		Value string `json:"value"`
+	}
+	type UploadRequest struct {
+		Body io.ReadCloser `use_as_body:"true" is_stream:"true"`
+	}
+	type UploadResponse struct {
+		Size int `json:"size"`
+	}
+
+	var failures atomic.Int32
+	routes := []api2.Route{
+		api2.NewRoute(http.MethodPut, "/put/:key", func(ctx context.Context, req *PutRequest) (*PutResponse, error) {
+			if failures.Load() > 0 {
+				failures.Add(-1)
+				return nil, errors.Unavailable("try later")
+			}
+			return &PutResponse{Value: req.Key + "=" + req.Value}, nil
+		}),
+		api2.NewRoute(http.MethodPost, "/upload", func(ctx context.Context, req *UploadRequest) (*UploadResponse, error) {
+			data, err := io.ReadAll(req.Body)
+			if err != nil {
+				return nil, err
+			}
+			return &UploadResponse{Size: len(data)}, nil
+		}),
+	}
+
+	verifier := api2.NewSignatureVerifier(map[string][]byte{
+		"old": []byte("old secret"),
+		"new": []byte("new secret"),
+	})
+	mux := http.NewServeMux()
+	api2.BindRoutes(mux, routes,
+		api2.VerifySignatures(verifier),
+		api2.ErrorLogger(func(format string, args ...interface{}) {}),
+	)
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	ctx := context.Background()
+
+	for _, keyID := range []string{"old", "new"} {
+		client := api2.NewClient(routes, server.URL, api2.SignRequests(&api2.RequestSigner{
+			KeyID: keyID,
+			Key:   []byte(keyID + " secret"),
+		}))
+		res, err := api2.Call[PutRequest, PutResponse](ctx, client, &PutRequest{Key: "a", Value: "b"})
+		require.NoError(t, err)
+		require.Equal(t, "a=b", res.Value)
+
+		upload, err := api2.Call[UploadRequest, UploadResponse](ctx, client, &UploadRequest{Body: io.NopCloser(strings.NewReader("data"))})
+		require.NoError(t, err)
+		require.Equal(t, 4, upload.Size)
+	}
+
+	t.Run("retries are signed again", func(t *testing.T) {
+		policy := api2.DefaultRetryPolicy()
+		policy.InitialBackoff = time.Millisecond
+		client := api2.NewClient(routes, server.URL,
+			api2.SignRequests(&api2.RequestSigner{KeyID: "new", Key: []byte("new secret")}),
+			api2.Retry(policy),
+		)
+		failures.Store(2)
+		res, err := api2.Call[PutRequest, PutResponse](ctx, client, &PutRequest{Key: "a", Value: "c"})
+		require.NoError(t, err)
+		require.Equal(t, "a=c", res.Value)
+	})
+
+	t.Run("rejected", func(t *testing.T) {
+		unsigned := api2.NewClient(routes, server.URL)
+		_, err := api2.Call[PutRequest, PutResponse](ctx, unsigned, &PutRequest{Key: "a", Value: "b"})
+		require.ErrorContains(t, err, "unknown signing key")
+
+		wrongKey := api2.NewClient(routes, server.URL, api2.SignRequests(&api2.RequestSigner{
+			KeyID: "new",
+			Key:   []byte("guess"),
+		}))
+		_, err = api2.Call[PutRequest, PutResponse](ctx, wrongKey, &PutRequest{Key: "a", Value: "b"})
+		require.ErrorContains(t, err, "signature mismatch")
+	})
+
+	t.Run("replay", func(t *testing.T) {
+		var captured *http.Request
+		var body string
+		capture := api2.NewClient(routes, server.URL,
+			api2.SignRequests(&api2.RequestSigner{KeyID: "new", Key: []byte("new secret")}),
+			api2.CustomClient(clientFunc(func(req *http.Request) (*http.Response, error) {
+				data, err := io.ReadAll(req.Body)
+				if err != nil {
+					return nil, err
+				}
+				body = string(data)
+				captured = req
+				req.Body = io.NopCloser(strings.NewReader(body))
+				return http.DefaultClient.Do(req)
+			})),
+		)
+		_, err := api2.Call[PutRequest, PutResponse](ctx, capture, &PutRequest{Key: "a", Value: "b"})
+		require.NoError(t, err)
+
+		replayed := captured.Clone(ctx)
+		replayed.Body = io.NopCloser(strings.NewReader(body))
+		res, err := http.DefaultClient.Do(replayed)
+		require.NoError(t, err)
+		defer res.Body.Close()
+		require.Equal(t, http.StatusUnauthorized, res.StatusCode)
+		data, err := io.ReadAll(res.Body)
+		require.NoError(t, err)
+		require.Contains(t, string(data), "replayed request")
+	})
+}
+
+type clientFunc func(req *http.Request) (*http.Response, error)
+
+func (f clientFunc) Do(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func (f clientFunc) CloseIdleConnections() {
+}
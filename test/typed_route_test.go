@@ -0,0 +1,130 @@
+package api2
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/starius/api2"
+	"github.com/starius/api2/errors"
+	"github.com/stretchr/testify/require"
+)
+
+type GreetRequest struct {
+	Name string `url:"name"`
+}
+
+type GreetResponse struct {
+	Greeting string `json:"greeting"`
+}
+
+type Greeter interface {
+	Greet(ctx context.Context, req *GreetRequest) (*GreetResponse, error)
+}
+
+type greeter struct{}
+
+func (greeter) Greet(ctx context.Context, req *GreetRequest) (*GreetResponse, error) {
+	if req.Name == "nobody" {
+		return nil, errors.NotFound("no such user: %s", req.Name)
+	}
+	return &GreetResponse{Greeting: "Hello, " + req.Name}, nil
+}
+
+func GetGreeterRoutes(s Greeter) []api2.Route {
+	return []api2.Route{
+		api2.NewMethodRoute[GreetRequest, GreetResponse](http.MethodGet, "/greet/:name", &s, "Greet"),
+	}
+}
+
+func TestTypedRoutes(t *testing.T) {
+	type EchoRequest struct {
+		Text string `json:"text"`
+	}
+	type EchoResponse struct {
+		Text string `json:"text"`
+	}
+
+	var calls []string
+	middleware := func(ctx context.Context, req any, next api2.Handler) (any, any) {
+		calls = append(calls, "middleware")
+		return next(ctx, req)
+	}
+
+	routes := append(GetGreeterRoutes(greeter{}),
+		api2.NewRoute(http.MethodPost, "/echo", func(ctx context.Context, req *EchoRequest) (*EchoResponse, error) {
+			return &EchoResponse{Text: req.Text}, nil
+		}, api2.RouteMiddleware(middleware)),
+	)
+
+	mux := http.NewServeMux()
+	api2.BindRoutes(mux, routes)
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	// The client is created from routes with nil service.
+	clientRoutes := append(GetGreeterRoutes(nil),
+		api2.NewRoute[EchoRequest, EchoResponse](http.MethodPost, "/echo", nil),
+	)
+	client := api2.NewClient(clientRoutes, server.URL)
+	ctx := context.Background()
+
+	greet := &GreetResponse{}
+	require.NoError(t, client.Call(ctx, greet, &GreetRequest{Name: "Alice"}))
+	require.Equal(t, "Hello, Alice", greet.Greeting)
+
+	err := client.Call(ctx, greet, &GreetRequest{Name: "nobody"})
+	require.ErrorContains(t, err, "no such user")
+
+	echo := &EchoResponse{}
+	require.NoError(t, client.Call(ctx, echo, &EchoRequest{Text: "hi"}))
+	require.Equal(t, "hi", echo.Text)
+	require.Equal(t, []string{"middleware"}, calls)
+}
+
+func TestTypedClient(t *testing.T) {
+	type EchoRequest struct {
+		Text string `json:"text"`
+	}
+	type EchoResponse struct {
+		Text string `json:"text"`
+	}
+	type UnknownResponse struct {
+	}
+
+	routes := append(GetGreeterRoutes(greeter{}),
+		api2.NewRoute(http.MethodPost, "/echo", func(ctx context.Context, req *EchoRequest) (*EchoResponse, error) {
+			return &EchoResponse{Text: req.Text}, nil
+		}),
+	)
+
+	mux := http.NewServeMux()
+	api2.BindRoutes(mux, routes)
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	client := api2.NewClient(routes, server.URL)
+	ctx := context.Background()
+
+	echo, err := api2.Call[EchoRequest, EchoResponse](ctx, client, &EchoRequest{Text: "hi"})
+	require.NoError(t, err)
+	require.Equal(t, "hi", echo.Text)
+
+	_, err = api2.Call[EchoRequest, UnknownResponse](ctx, client, &EchoRequest{Text: "hi"})
+	require.ErrorIs(t, err, api2.ErrNoRoute)
+
+	greet, err := api2.NewEndpoint[GreetRequest, GreetResponse](client)
+	require.NoError(t, err)
+	require.Equal(t, "/greet/:name", greet.Route().Path)
+
+	res, err := greet.Call(ctx, &GreetRequest{Name: "Bob"})
+	require.NoError(t, err)
+	require.Equal(t, "Hello, Bob", res.Greeting)
+
+	_, err = greet.Call(ctx, &GreetRequest{Name: "nobody"})
+	require.ErrorContains(t, err, "no such user")
+
+	_, err = api2.NewEndpoint[GreetRequest, EchoResponse](client)
+	require.ErrorIs(t, err, api2.ErrNoRoute)
+}
@@ -2,6 +2,7 @@ package api2
 
 import (
 	"context"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"sort"
@@ -433,3 +434,169 @@ func TestUrlParams(t *testing.T) {
 		require.Equal(t, []string{"Welcome"}, res.CommentResponses)
 	})
 }
+
+func TestUrlParamConstraints(t *testing.T) {
+	type GetByIDRequest struct {
+		ID int `url:"id"`
+	}
+	type GetByIDResponse struct {
+		ID int `json:"id"`
+	}
+	type GetByNameRequest struct {
+		Name string `url:"name"`
+	}
+	type GetByNameResponse struct {
+		Name string `json:"name"`
+	}
+
+	handleGetByID := func(ctx context.Context, req *GetByIDRequest) (*GetByIDResponse, error) {
+		return &GetByIDResponse{ID: req.ID}, nil
+	}
+	handleGetByName := func(ctx context.Context, req *GetByNameRequest) (*GetByNameResponse, error) {
+		return &GetByNameResponse{Name: req.Name}, nil
+	}
+
+	routes := []api2.Route{
+		{Method: http.MethodGet, Path: "/users/:id<int>", Handler: handleGetByID},
+		{Method: http.MethodGet, Path: "/users/:name<re:[a-z]+>", Handler: handleGetByName},
+	}
+
+	mux := http.NewServeMux()
+	api2.BindRoutes(mux, routes)
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	client := api2.NewClient(routes, server.URL)
+	ctx := context.Background()
+
+	byID := &GetByIDResponse{}
+	require.NoError(t, client.Call(ctx, byID, &GetByIDRequest{ID: 42}))
+	require.Equal(t, 42, byID.ID)
+
+	byName := &GetByNameResponse{}
+	require.NoError(t, client.Call(ctx, byName, &GetByNameRequest{Name: "alice"}))
+	require.Equal(t, "alice", byName.Name)
+
+	// The value does not satisfy any constraint.
+	err := client.Call(ctx, byName, &GetByNameRequest{Name: "Alice"})
+	require.Error(t, err)
+
+	res, err := http.Get(server.URL + "/users/Alice")
+	require.NoError(t, err)
+	require.NoError(t, res.Body.Close())
+	require.Equal(t, http.StatusNotFound, res.StatusCode)
+}
+
+func TestNativePatterns(t *testing.T) {
+	type EchoRequest struct {
+		User string `url:"user"`
+		Text string `json:"text"`
+	}
+	type EchoResponse struct {
+		User string `json:"user"`
+		Text string `json:"text"`
+	}
+	type GetFileRequest struct {
+		Path string `url:"path"`
+	}
+	type GetFileResponse struct {
+		Path string `json:"path"`
+	}
+	type GetByIDRequest struct {
+		ID int `url:"id"`
+	}
+	type GetByIDResponse struct {
+		ID int `json:"id"`
+	}
+	type GetByNameRequest struct {
+		Name string `url:"name"`
+	}
+	type GetByNameResponse struct {
+		Name string `json:"name"`
+	}
+
+	handleEcho := func(ctx context.Context, req *EchoRequest) (*EchoResponse, error) {
+		return &EchoResponse{User: req.User, Text: req.Text}, nil
+	}
+	handleGetFile := func(ctx context.Context, req *GetFileRequest) (*GetFileResponse, error) {
+		return &GetFileResponse{Path: req.Path}, nil
+	}
+	handleGetByID := func(ctx context.Context, req *GetByIDRequest) (*GetByIDResponse, error) {
+		return &GetByIDResponse{ID: req.ID}, nil
+	}
+	handleGetByName := func(ctx context.Context, req *GetByNameRequest) (*GetByNameResponse, error) {
+		return &GetByNameResponse{Name: req.Name}, nil
+	}
+
+	routes := []api2.Route{
+		{Method: http.MethodPost, Path: "/echo/:user", Handler: handleEcho},
+		{Method: http.MethodGet, Path: "/files/:path*", Handler: handleGetFile},
+		{Method: http.MethodGet, Path: "/users/:name", Handler: handleGetByName},
+		{Method: http.MethodGet, Path: "/users/:id<int>", Handler: handleGetByID},
+	}
+
+	mux := http.NewServeMux()
+	api2.BindRoutes(mux, routes, api2.NativePatterns(true))
+
+	// Hand-written handlers under the same prefixes.
+	mux.HandleFunc("GET /echo/status", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+	mux.HandleFunc("GET /users/export", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("export"))
+	})
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	client := api2.NewClient(routes, server.URL)
+	ctx := context.Background()
+
+	echo := &EchoResponse{}
+	require.NoError(t, client.Call(ctx, echo, &EchoRequest{User: "alice", Text: "hello"}))
+	require.Equal(t, &EchoResponse{User: "alice", Text: "hello"}, echo)
+
+	file := &GetFileResponse{}
+	require.NoError(t, client.Call(ctx, file, &GetFileRequest{Path: "a/b/c.txt"}))
+	require.Equal(t, "a/b/c.txt", file.Path)
+
+	byID := &GetByIDResponse{}
+	require.NoError(t, client.Call(ctx, byID, &GetByIDRequest{ID: 42}))
+	require.Equal(t, 42, byID.ID)
+
+	byName := &GetByNameResponse{}
+	require.NoError(t, client.Call(ctx, byName, &GetByNameRequest{Name: "bob"}))
+	require.Equal(t, "bob", byName.Name)
+
+	for path, want := range map[string]string{
+		"/echo/status":  "ok",
+		"/users/export": "export",
+	} {
+		res, err := http.Get(server.URL + path)
+		require.NoError(t, err)
+		body, err := io.ReadAll(res.Body)
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+		require.Equal(t, want, string(body))
+	}
+
+	// The method is part of the pattern. Errors are JSON as in the default
+	// mode, not plain text of http.ServeMux.
+	for path, want := range map[string]struct {
+		status int
+		body   string
+	}{
+		"/echo/alice": {http.StatusMethodNotAllowed, `{"error":"unsupported method: GET"}`},
+		"/unknown":    {http.StatusNotFound, `{"error":"failed to find route by path"}`},
+		"/echo/a/b":   {http.StatusNotFound, `{"error":"failed to find route by path"}`},
+	} {
+		res, err := http.Get(server.URL + path)
+		require.NoError(t, err)
+		body, err := io.ReadAll(res.Body)
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+		require.Equal(t, want.status, res.StatusCode, path)
+		require.Equal(t, "application/json", res.Header.Get("Content-Type"), path)
+		require.JSONEq(t, want.body, string(body), path)
+	}
+}
@@ -0,0 +1,54 @@
+package api2
+
+import (
+	"context"
+	"reflect"
+)
+
+// Call calls remote method of the client by types Req and Res. Unlike
+// Client.Call, it returns an error wrapping ErrNoRoute instead of panicking
+// if the client has no route for the types. Options change only this call.
+func Call[Req, Res any](ctx context.Context, client *Client, request *Req, opts ...CallOption) (*Res, error) {
+	route, err := client.findRoute(reflect.TypeFor[*Req](), reflect.TypeFor[*Res]())
+	if err != nil {
+		return nil, err
+	}
+	response := new(Res)
+	if err := client.callRoute(WithCallOptions(ctx, opts...), route, response, request); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// Endpoint is a typed handle to one route of a client.
+type Endpoint[Req, Res any] struct {
+	client *Client
+	route  Route
+}
+
+// NewEndpoint finds the route of the client by types Req and Res. It returns
+// an error wrapping ErrNoRoute if the client has no such route.
+func NewEndpoint[Req, Res any](client *Client) (*Endpoint[Req, Res], error) {
+	route, err := client.findRoute(reflect.TypeFor[*Req](), reflect.TypeFor[*Res]())
+	if err != nil {
+		return nil, err
+	}
+	return &Endpoint[Req, Res]{
+		client: client,
+		route:  route,
+	}, nil
+}
+
+// Route returns the route of the endpoint.
+func (e *Endpoint[Req, Res]) Route() Route {
+	return e.route
+}
+
+// Call calls the remote method. Options change only this call.
+func (e *Endpoint[Req, Res]) Call(ctx context.Context, request *Req, opts ...CallOption) (*Res, error) {
+	response := new(Res)
+	if err := e.client.callRoute(WithCallOptions(ctx, opts...), e.route, response, request); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
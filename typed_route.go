@@ -0,0 +1,122 @@
+package api2
+
+import (
+	"context"
+	"fmt"
+)
+
+// RouteOption changes a route created by NewRoute or NewMethodRoute.
+type RouteOption func(route *Route)
+
+// RouteTransport sets Transport of the route.
+func RouteTransport(t Transport) RouteOption {
+	return func(route *Route) {
+		route.Transport = t
+	}
+}
+
+// RouteMeta sets the key of Meta of the route.
+func RouteMeta(key string, value interface{}) RouteOption {
+	return func(route *Route) {
+		if route.Meta == nil {
+			route.Meta = make(map[string]interface{})
+		}
+		route.Meta[key] = value
+	}
+}
+
+// RouteMiddleware sets Middleware of the route.
+func RouteMiddleware(m Middleware) RouteOption {
+	return func(route *Route) {
+		route.Middleware = m
+	}
+}
+
+// RouteMaxBody sets MaxBody of the route.
+func RouteMaxBody(maxBody int64) RouteOption {
+	return func(route *Route) {
+		route.MaxBody = maxBody
+	}
+}
+
+// RouteIdempotent marks the route as idempotent.
+func RouteIdempotent() RouteOption {
+	return func(route *Route) {
+		route.Idempotent = true
+	}
+}
+
+// typedCaller is implemented by handlers of routes created by NewRoute and
+// NewMethodRoute. The server calls them without reflection.
+type typedCaller interface {
+	newRequest() interface{}
+	call(ctx context.Context, req interface{}) (interface{}, error)
+}
+
+type typedHandler[Req, Res any] struct {
+	fn func(ctx context.Context, req *Req) (*Res, error)
+}
+
+// Func returns the handler function. It is nil if nil was passed to NewRoute.
+func (h *typedHandler[Req, Res]) Func() interface{} {
+	return h.fn
+}
+
+func (h *typedHandler[Req, Res]) newRequest() interface{} {
+	return new(Req)
+}
+
+func (h *typedHandler[Req, Res]) call(ctx context.Context, req interface{}) (interface{}, error) {
+	return h.fn(ctx, req.(*Req))
+}
+
+type typedMethod[Req, Res any] struct {
+	typedHandler[Req, Res]
+	method *interfaceMethod
+}
+
+func (m *typedMethod[Req, Res]) FuncInfo() (pkgFull, pkgName, structName, method string) {
+	return m.method.FuncInfo()
+}
+
+// NewRoute creates a route with the handler. Unlike setting Route.Handler
+// directly, the signature of the handler is checked at compile time and
+// the server calls the handler without reflection. The handler can be nil
+// if the route is only used to create a client: BindRoutes and
+// NewDirectClient panic on such routes.
+func NewRoute[Req, Res any](method, path string, handler func(ctx context.Context, req *Req) (*Res, error), opts ...RouteOption) Route {
+	route := Route{
+		Method:  method,
+		Path:    path,
+		Handler: &typedHandler[Req, Res]{fn: handler},
+	}
+	for _, opt := range opts {
+		opt(&route)
+	}
+	return route
+}
+
+// NewMethodRoute is like NewRoute, but the handler is the method of the
+// service, as in Method. servicePtr is a pointer to an interface (it can be
+// nil, e.g. to create a client) or a pointer to a pointer to a struct.
+// The route is known to code generators by the names of the service and
+// the method. It panics if the type of the method does not match Req and Res.
+func NewMethodRoute[Req, Res any](method, path string, servicePtr interface{}, methodName string, opts ...RouteOption) Route {
+	m := Method(servicePtr, methodName).(*interfaceMethod)
+	fn, ok := m.Func().(func(context.Context, *Req) (*Res, error))
+	if !ok {
+		panic(fmt.Sprintf("method %s has type %T, want %T", methodName, m.Func(), fn))
+	}
+	route := Route{
+		Method: method,
+		Path:   path,
+		Handler: &typedMethod[Req, Res]{
+			typedHandler: typedHandler[Req, Res]{fn: fn},
+			method:       m,
+		},
+	}
+	for _, opt := range opts {
+		opt(&route)
+	}
+	return route
+}
@@ -0,0 +1,73 @@
+package api2
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewRoute(t *testing.T) {
+	transport := &JsonTransport{}
+	route := NewRoute(http.MethodPost, "/hello", (&ServiceStruct{}).Hello,
+		RouteTransport(transport),
+		RouteMeta("roles", "admin"),
+		RouteMaxBody(100),
+	)
+	require.Equal(t, http.MethodPost, route.Method)
+	require.Equal(t, "/hello", route.Path)
+	require.Equal(t, transport, route.Transport)
+	require.Equal(t, map[string]interface{}{"roles": "admin"}, route.Meta)
+	require.Equal(t, int64(100), route.MaxBody)
+
+	typed := route.Handler.(typedCaller)
+	res, err := typed.call(context.Background(), typed.newRequest())
+	require.NoError(t, err)
+	require.Equal(t, &HelloResponse{}, res)
+
+	info := GetFnInfo(route.Handler)
+	require.Equal(t, "ServiceStruct", info.StructName)
+	require.Equal(t, "Hello", info.Method)
+
+	// Nil handler can be used to create a client.
+	clientRoute := NewRoute[HelloRequest, HelloResponse](http.MethodPost, "/hello", nil)
+	f := clientRoute.Handler.(funcer).Func()
+	validateHandler(reflect.TypeOf(f), "/hello")
+
+	// The server rejects it when the routes are bound.
+	require.PanicsWithValue(t, "route POST /hello: handler is nil", func() {
+		BindRoutes(http.NewServeMux(), []Route{clientRoute})
+	})
+	require.Panics(t, func() {
+		NewDirectClient([]Route{clientRoute}, DirectFast)
+	})
+}
+
+func TestNewMethodRoute(t *testing.T) {
+	var serviceInterfaceNil ServiceInterface
+	serviceInterface := ServiceInterface(&ServiceStruct{})
+
+	for _, servicePtr := range []*ServiceInterface{&serviceInterfaceNil, &serviceInterface} {
+		route := NewMethodRoute[HelloRequest, HelloResponse](http.MethodPost, "/hello", servicePtr, "Hello")
+		info := GetFnInfo(route.Handler)
+		require.Equal(t, FnInfo{
+			PkgFull:    "github.com/starius/api2",
+			PkgName:    "api2",
+			StructName: "ServiceInterface",
+			Method:     "Hello",
+		}, info)
+		f := route.Handler.(funcer).Func()
+		validateHandler(reflect.TypeOf(f), "/hello")
+	}
+
+	typed := NewMethodRoute[HelloRequest, HelloResponse](http.MethodPost, "/hello", &serviceInterface, "Hello").Handler.(typedCaller)
+	res, err := typed.call(context.Background(), typed.newRequest())
+	require.NoError(t, err)
+	require.Equal(t, &HelloResponse{}, res)
+
+	require.Panics(t, func() {
+		NewMethodRoute[HelloRequest, BodyPart](http.MethodPost, "/hello", &serviceInterface, "Hello")
+	})
+}
@@ -2,268 +2,158 @@ package api2
 
 import (
 	"fmt"
-	"slices"
+	"regexp"
+	"strconv"
 	"strings"
+	"sync"
 )
 
 type paramMapType struct{}
 
-type wildcardInfo struct {
-	position int    // position in the path parts array
-	name     string // parameter name without prefix
-}
-
-type routeSpecificity struct {
-	staticSegments   int  // number of static path segments
-	regularParams    int  // number of regular parameters (:param)
-	wildcardParams   int  // number of wildcard parameters (:param*)
-	endsWithWildcard bool // true if route ends with a wildcard parameter
-	score            int  // calculated specificity score
-}
-
-type routeConflict struct {
-	route1Index int
-	route2Index int
-	route1Path  string
-	route2Path  string
-	route1Score int
-	route2Score int
-	message     string
-}
-
+// classifier matches paths against masks regardless of HTTP method.
 type classifier struct {
-	maskPartsArray [][]string
-	paramsArray    [][]bool
-	paramsNum      []int
-	wildcardArray  [][]wildcardInfo // wildcard information for each route
+	router *router
 }
 
-func splitUrl(url string) []string {
-	parts := strings.Split(url, "/")
-	for len(parts) > 0 && parts[0] == "" {
-		parts = parts[1:]
-	}
-	for len(parts) > 0 && parts[len(parts)-1] == "" {
-		parts = parts[:len(parts)-1]
-	}
-	return parts
+// paramConstraint restricts values of URL parameter.
+// Syntax in route path: ":id<int>", ":id<uint>", ":id<uuid>",
+// ":name<re:[a-z0-9-]+>". Regular expression must match the whole value
+// and must not contain '/'.
+type paramConstraint struct {
+	source string // text between '<' and '>'
+	kind   string // "int", "uint", "uuid" or "re"
+	re     *regexp.Regexp
 }
 
-func newPathClassifier(masks []string) *classifier {
-	maskPartsArray := make([][]string, 0, len(masks))
-	paramsArray := make([][]bool, 0, len(masks))
-	paramsNum := make([]int, 0, len(masks))
-	wildcardArray := make([][]wildcardInfo, 0, len(masks))
-
-	for _, mask := range masks {
-		parts := splitUrl(mask)
-		params := make([]bool, len(parts))
-		wildcards := make([]wildcardInfo, 0)
-		count := 0
-
-		for i, part := range parts {
-			if strings.HasPrefix(part, ":") {
-				paramName := strings.TrimPrefix(part, ":")
+var uuidRE = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
 
-				// Check for wildcard suffix
-				if strings.HasSuffix(paramName, "*") {
-					// Wildcard parameter (zero or more segments)
-					paramName = strings.TrimSuffix(paramName, "*")
-					wildcards = append(wildcards, wildcardInfo{
-						position: i,
-						name:     paramName,
-					})
-				}
+// paramConstraints caches parsed constraints by their source.
+var paramConstraints sync.Map
 
-				parts[i] = paramName
-				params[i] = true
-				count++
-			}
+func getParamConstraint(source string) *paramConstraint {
+	if c, has := paramConstraints.Load(source); has {
+		return c.(*paramConstraint)
+	}
+	c := &paramConstraint{source: source, kind: source}
+	switch {
+	case source == "int", source == "uint":
+	case source == "uuid":
+		c.re = uuidRE
+	case strings.HasPrefix(source, "re:"):
+		c.kind = "re"
+		re, err := regexp.Compile("^(?:" + strings.TrimPrefix(source, "re:") + ")$")
+		if err != nil {
+			panic(fmt.Sprintf("bad regular expression in URL parameter constraint <%s>: %v", source, err))
 		}
-
-		maskPartsArray = append(maskPartsArray, parts)
-		paramsArray = append(paramsArray, params)
-		paramsNum = append(paramsNum, count)
-		wildcardArray = append(wildcardArray, wildcards)
+		c.re = re
+	default:
+		panic(fmt.Sprintf("unknown URL parameter constraint <%s>, want int, uint, uuid or re:...", source))
 	}
+	paramConstraints.Store(source, c)
+	return c
+}
 
-	return &classifier{
-		maskPartsArray: maskPartsArray,
-		paramsArray:    paramsArray,
-		paramsNum:      paramsNum,
-		wildcardArray:  wildcardArray,
+// Match returns if the value of URL parameter satisfies the constraint.
+func (c *paramConstraint) Match(value string) bool {
+	switch c.kind {
+	case "int":
+		_, err := strconv.ParseInt(value, 10, 64)
+		return err == nil
+	case "uint":
+		_, err := strconv.ParseUint(value, 10, 64)
+		return err == nil
+	default:
+		return c.re.MatchString(value)
 	}
 }
 
-func match(pathParts, maskParts []string, params []bool, count int) (bool, map[string]string) {
-	return matchWithWildcards(pathParts, maskParts, params, count, nil)
+// Pattern returns regular expression matching values satisfying the constraint.
+func (c *paramConstraint) Pattern() string {
+	switch c.kind {
+	case "int":
+		return `^-?[0-9]+$`
+	case "uint":
+		return `^[0-9]+$`
+	default:
+		return c.re.String()
+	}
 }
 
-func matchSimplePattern(pathParts, maskParts []string, params []bool, count int) (bool, map[string]string) {
-	if len(pathParts) != len(maskParts) {
-		return false, nil
+// parseParam parses a segment of route path. If the segment is a parameter
+// (":name", ":name*" or ":name<constraint>"), it returns its name, whether it
+// is a wildcard and its constraint (nil if absent). If the segment is static,
+// isParam is false.
+func parseParam(part string) (name string, wildcard bool, constraint *paramConstraint, isParam bool) {
+	if !strings.HasPrefix(part, ":") {
+		return "", false, nil, false
 	}
-	// Check if all static parts match.
-	for i := 0; i < len(pathParts); i++ {
-		if !params[i] && pathParts[i] != maskParts[i] {
-			return false, nil
+	name = part[1:]
+	if i := strings.IndexByte(name, '<'); i != -1 {
+		if !strings.HasSuffix(name, ">") {
+			panic(fmt.Sprintf("URL parameter %q: constraint must be at the end of path segment", part))
 		}
+		constraint = getParamConstraint(name[i+1 : len(name)-1])
+		name = name[:i]
 	}
-	// Fill values of the parameters.
-	param2value := make(map[string]string, count)
-	for i := 0; i < len(pathParts); i++ {
-		if !params[i] {
-			continue
+	if strings.HasSuffix(name, "*") {
+		if constraint != nil {
+			panic(fmt.Sprintf("URL parameter %q: wildcard parameters can not have constraints", part))
 		}
-		key := maskParts[i]
-		value := pathParts[i]
-		param2value[key] = value
+		name = strings.TrimSuffix(name, "*")
+		wildcard = true
 	}
-	return true, param2value
+	return name, wildcard, constraint, true
 }
 
-func matchWithWildcards(pathParts, maskParts []string, params []bool, count int, wildcards []wildcardInfo) (bool, map[string]string) {
-	// If no wildcards, use the original simple matching logic
-	if len(wildcards) == 0 {
-		return matchSimplePattern(pathParts, maskParts, params, count)
+// stripParamConstraints removes constraints from URL parameters in the path:
+// "/users/:id<int>" -> "/users/:id".
+func stripParamConstraints(path string) string {
+	if !strings.Contains(path, "<") {
+		return path
 	}
-
-	// Complex matching with wildcards
-	return matchWildcardPattern(pathParts, maskParts, params, wildcards)
-}
-
-func matchWildcardPattern(pathParts, maskParts []string, params []bool, wildcards []wildcardInfo) (bool, map[string]string) {
-	param2value := make(map[string]string)
-
-	// For each wildcard, we need to determine what segments it should capture
-	pathIndex := 0
-	maskIndex := 0
-
-	for maskIndex < len(maskParts) {
-		// Check if current mask position is a wildcard
-		isWildcard := false
-		var wildcardInfo wildcardInfo
-		for _, wc := range wildcards {
-			if wc.position == maskIndex {
-				isWildcard = true
-				wildcardInfo = wc
-				break
-			}
-		}
-
-		if isWildcard {
-			// Find the next static segment after this wildcard to determine boundaries
-			nextStaticIndex := findNextStaticSegment(maskParts, maskIndex+1, params)
-
-			if nextStaticIndex == -1 {
-				// Wildcard is at the end - capture remaining path segments
-				if pathIndex < len(pathParts) {
-					remainingSegments := pathParts[pathIndex:]
-					param2value[wildcardInfo.name] = strings.Join(remainingSegments, "/")
-					pathIndex = len(pathParts) // consumed all remaining segments
-				} else {
-					// Wildcard at the end can match zero segments
-					param2value[wildcardInfo.name] = ""
-				}
-				// Since wildcard is at the end, we can finish processing
-				maskIndex = len(maskParts)
-				break
-			} else {
-				// Find where the next static segment pattern matches in the path
-				matchPos := findStaticPattern(pathParts, pathIndex, maskParts, nextStaticIndex, params)
-				if matchPos == -1 {
-					return false, nil
-				}
-
-				// Calculate how many segments the wildcard should capture
-				capturedSegments := pathParts[pathIndex:matchPos]
-
-				param2value[wildcardInfo.name] = strings.Join(capturedSegments, "/")
-				pathIndex = matchPos
-			}
-		} else if params[maskIndex] {
-			// Regular parameter - must match exactly one segment
-			if pathIndex >= len(pathParts) {
-				return false, nil
-			}
-			param2value[maskParts[maskIndex]] = pathParts[pathIndex]
-			pathIndex++
-		} else {
-			// Static segment - must match exactly
-			if pathIndex >= len(pathParts) || pathParts[pathIndex] != maskParts[maskIndex] {
-				return false, nil
-			}
-			pathIndex++
+	parts := strings.Split(path, "/")
+	for i, part := range parts {
+		if name, wildcard, _, isParam := parseParam(part); isParam && !wildcard {
+			parts[i] = ":" + name
 		}
-
-		maskIndex++
 	}
-
-	// Ensure we've consumed all path segments
-	if pathIndex != len(pathParts) {
-		return false, nil
-	}
-
-	return true, param2value
+	return strings.Join(parts, "/")
 }
 
-func findNextStaticSegment(maskParts []string, startIndex int, params []bool) int {
-	for i := startIndex; i < len(maskParts); i++ {
-		if !params[i] {
-			return i
-		}
+func splitUrl(url string) []string {
+	parts := strings.Split(url, "/")
+	for len(parts) > 0 && parts[0] == "" {
+		parts = parts[1:]
 	}
-	return -1
-}
-
-func findStaticPattern(pathParts []string, startIndex int, maskParts []string, maskStartIndex int, params []bool) int {
-	// Find the end of the static pattern we're looking for
-	patternEnd := maskStartIndex
-	for patternEnd < len(maskParts) && !params[patternEnd] {
-		patternEnd++
+	for len(parts) > 0 && parts[len(parts)-1] == "" {
+		parts = parts[:len(parts)-1]
 	}
+	return parts
+}
 
-	patternLength := patternEnd - maskStartIndex
-
-	// Search for this pattern in the remaining path
-	for i := startIndex; i <= len(pathParts)-patternLength; i++ {
-		match := true
-		for j := 0; j < patternLength; j++ {
-			if pathParts[i+j] != maskParts[maskStartIndex+j] {
-				match = false
-				break
-			}
-		}
-		if match {
-			return i
-		}
+func newPathClassifier(masks []string) *classifier {
+	rt := newRouter()
+	for i, mask := range masks {
+		rt.add("", mask, i)
 	}
-
-	return -1
+	return &classifier{router: rt}
 }
 
 // Classify returns index of matching mask (-1 if not found) and parameters map.
 func (c *classifier) Classify(path string) (index int, param2value map[string]string) {
-	pathParts := splitUrl(path)
-	for i, maskParts := range c.maskPartsArray {
-		ok, param2value := matchWithWildcards(pathParts, maskParts, c.paramsArray[i], c.paramsNum[i], c.wildcardArray[i])
-		if ok {
-			return i, param2value
-		}
+	index, param2value, _ = c.router.match("", path)
+	if index != -1 && param2value == nil {
+		param2value = map[string]string{}
 	}
-	return -1, nil
+	return index, param2value
 }
 
 func findUrlKeys(mask string) []string {
 	parts := strings.Split(mask, "/")
 	result := make([]string, 0, len(parts))
 	for _, part := range parts {
-		if strings.HasPrefix(part, ":") {
-			paramName := strings.TrimPrefix(part, ":")
-			// Strip wildcard suffixes for parameter name consistency
-			if strings.HasSuffix(paramName, "*") {
-				paramName = strings.TrimSuffix(paramName, "*")
-			}
+		// Strip wildcard suffixes and constraints for parameter name consistency
+		if paramName, _, _, isParam := parseParam(part); isParam {
 			result = append(result, paramName)
 		}
 	}
@@ -285,14 +175,11 @@ func buildUrl(mask string, param2value map[string]string) (string, error) {
 	urlParts := strings.Split(mask, "/")
 	replaced := make(map[string]struct{}, len(param2value))
 	for i, part := range urlParts {
-		if !strings.HasPrefix(part, ":") {
+		// Strip wildcard suffixes and constraints for parameter lookup
+		paramName, _, _, isParam := parseParam(part)
+		if !isParam {
 			continue
 		}
-		paramName := strings.TrimPrefix(part, ":")
-		// Strip wildcard suffixes for parameter lookup
-		if strings.HasSuffix(paramName, "*") {
-			paramName = strings.TrimSuffix(paramName, "*")
-		}
 		value, has := param2value[paramName]
 		if !has {
 			return "", fmt.Errorf("unknown parameter: %s", paramName)
@@ -305,178 +192,3 @@ func buildUrl(mask string, param2value map[string]string) (string, error) {
 	}
 	return strings.Join(urlParts, "/"), nil
 }
-
-// calculateRouteSpecificity analyzes a route pattern and returns its specificity metrics
-func calculateRouteSpecificity(routePath string) routeSpecificity {
-	parts := splitUrl(routePath)
-
-	var staticSegments, regularParams, wildcardParams int
-	var endsWithWildcard bool
-
-	for i, part := range parts {
-		if strings.HasPrefix(part, ":") {
-			paramName := strings.TrimPrefix(part, ":")
-			if strings.HasSuffix(paramName, "*") {
-				wildcardParams++
-				if i == len(parts)-1 {
-					endsWithWildcard = true
-				}
-			} else {
-				regularParams++
-			}
-		} else {
-			staticSegments++
-		}
-	}
-
-	// Specificity scoring: higher score = more specific route
-	// Formula: (static_segments × 100) + (regular_params × 10) - (wildcard_params × 50) - (ends_with_wildcard × 25)
-	score := (staticSegments * 100) + (regularParams * 10) - (wildcardParams * 50)
-	if endsWithWildcard {
-		score -= 25
-	}
-
-	return routeSpecificity{
-		staticSegments:   staticSegments,
-		regularParams:    regularParams,
-		wildcardParams:   wildcardParams,
-		endsWithWildcard: endsWithWildcard,
-		score:            score,
-	}
-}
-
-// detectRouteConflicts analyzes a list of route patterns for potential conflicts
-func detectRouteConflicts(routePaths []string) []routeConflict {
-	conflicts := make([]routeConflict, 0)
-
-	// Calculate specificity for all routes
-	specificities := make([]routeSpecificity, len(routePaths))
-	for i, path := range routePaths {
-		specificities[i] = calculateRouteSpecificity(strings.Split(path, ": ")[1]) // Ignore HTTP method prefix
-	}
-
-	// Check each pair of routes for conflicts
-	for i := 0; i < len(routePaths); i++ {
-		for j := i + 1; j < len(routePaths); j++ {
-			if routesCanConflict(routePaths[i], routePaths[j]) {
-				// Determine which route has higher specificity
-				route1Spec := specificities[i]
-				route2Spec := specificities[j]
-
-				// If a less specific route appears before a more specific one, it's a conflict
-				if route1Spec.score < route2Spec.score {
-					conflicts = append(conflicts, routeConflict{
-						route1Index: i,
-						route2Index: j,
-						route1Path:  routePaths[i],
-						route2Path:  routePaths[j],
-						route1Score: route1Spec.score,
-						route2Score: route2Spec.score,
-						message:     fmt.Sprintf("Route %d (score: %d) is less specific than route %d (score: %d) but appears first", i, route1Spec.score, j, route2Spec.score),
-					})
-				}
-			}
-		}
-	}
-
-	return conflicts
-}
-
-// routesCanConflict checks if two route patterns could potentially match the same URL
-func routesCanConflict(route1, route2 string) bool {
-	method1 := strings.SplitN(route1, ": ", 2)[0]
-	method2 := strings.SplitN(route2, ": ", 2)[0]
-	if method1 != method2 {
-		return false // Different HTTP methods can't conflict
-	}
-	parts1 := splitUrl(route1)
-	parts2 := splitUrl(route2)
-
-	// Routes can only conflict if they start with the same pattern
-	minLen := len(parts1)
-	if len(parts2) < minLen {
-		minLen = len(parts2)
-	}
-
-	for i := 0; i < minLen; i++ {
-		part1 := parts1[i]
-		part2 := parts2[i]
-
-		// If both are static and different, they can't conflict
-		if !strings.HasPrefix(part1, ":") && !strings.HasPrefix(part2, ":") {
-			if part1 != part2 {
-				return false
-			}
-			continue
-		}
-
-		// If one is a wildcard, they can potentially conflict
-		if (strings.HasPrefix(part1, ":") && strings.HasSuffix(part1, "*")) ||
-			(strings.HasPrefix(part2, ":") && strings.HasSuffix(part2, "*")) {
-			return true
-		}
-
-		// Both are parameters (regular or wildcard), they can potentially conflict
-		if strings.HasPrefix(part1, ":") && strings.HasPrefix(part2, ":") {
-			continue
-		}
-	}
-
-	// If we've reached here and routes have the same length, they can conflict
-	if len(parts1) == len(parts2) {
-		return true
-	}
-
-	// If one route is longer, they could conflict if the shorter has wildcards
-	shorterParts := parts1
-	if len(parts2) < len(parts1) {
-		shorterParts = parts2
-	}
-
-	// Check if the shorter route ends with a wildcard
-	if len(shorterParts) > 0 {
-		lastPart := shorterParts[len(shorterParts)-1]
-		if strings.HasPrefix(lastPart, ":") && strings.HasSuffix(lastPart, "*") {
-			return true
-		}
-	}
-
-	return false
-}
-
-// sortRoutesBySpecificity sorts route patterns by their specificity scores (highest first)
-func sortRoutesBySpecificity(routePaths []string) []string {
-	// Create pairs of (route, specificity) for sorting
-	type routeWithSpec struct {
-		path          string
-		specificity   routeSpecificity
-		originalIndex int
-	}
-
-	routes := make([]routeWithSpec, len(routePaths))
-	for i, path := range routePaths {
-		routes[i] = routeWithSpec{
-			path:          path,
-			specificity:   calculateRouteSpecificity(path),
-			originalIndex: i,
-		}
-	}
-
-	// Sort by specificity score (highest first), then by original index for stability
-	slices.SortFunc(routes, func(a, b routeWithSpec) int {
-		// Higher score should come first
-		if a.specificity.score != b.specificity.score {
-			return b.specificity.score - a.specificity.score // descending order
-		}
-		// If scores are equal, maintain original order (ascending)
-		return a.originalIndex - b.originalIndex
-	})
-
-	// Extract sorted paths
-	sortedPaths := make([]string, len(routes))
-	for i, route := range routes {
-		sortedPaths[i] = route.path
-	}
-
-	return sortedPaths
-}
@@ -253,241 +253,6 @@ func TestClassifierWithWildcards(t *testing.T) {
 	}
 }
 
-func TestRouteSpecificity(t *testing.T) {
-	cases := []struct {
-		name             string
-		routePath        string
-		expectedStatic   int
-		expectedRegular  int
-		expectedWildcard int
-		expectedEndsWith bool
-		expectedScore    int
-	}{
-		{
-			name:             "simple static route",
-			routePath:        "/users",
-			expectedStatic:   1,
-			expectedRegular:  0,
-			expectedWildcard: 0,
-			expectedEndsWith: false,
-			expectedScore:    100, // 1*100 + 0*10 - 0*50 - 0*25
-		},
-		{
-			name:             "route with regular parameter",
-			routePath:        "/users/:id",
-			expectedStatic:   1,
-			expectedRegular:  1,
-			expectedWildcard: 0,
-			expectedEndsWith: false,
-			expectedScore:    110, // 1*100 + 1*10 - 0*50 - 0*25
-		},
-		{
-			name:             "route with wildcard at end",
-			routePath:        "/data/:path*",
-			expectedStatic:   1,
-			expectedRegular:  0,
-			expectedWildcard: 1,
-			expectedEndsWith: true,
-			expectedScore:    25, // 1*100 + 0*10 - 1*50 - 1*25
-		},
-		{
-			name:             "complex route with multiple parameters",
-			routePath:        "/wildcard/:param_a/static-part/:param_b*/static-part/:param_c/static_part",
-			expectedStatic:   4, // "wildcard", "static-part", "static-part", "static_part"
-			expectedRegular:  2, // param_a, param_c
-			expectedWildcard: 1, // param_b*
-			expectedEndsWith: false,
-			expectedScore:    370, // 4*100 + 2*10 - 1*50 - 0*25
-		},
-		{
-			name:             "very complex route",
-			routePath:        "/wildcard/:param_a/static-part/:param_b*/static-part/:param_c/static_part/:param_d*/static_part/:param_e/static_part/:param_f*/static_part",
-			expectedStatic:   7, // "wildcard", "static-part", "static-part", "static_part", "static_part", "static_part", "static_part"
-			expectedRegular:  3, // param_a, param_c, param_e
-			expectedWildcard: 3, // param_b*, param_d*, param_f*
-			expectedEndsWith: false,
-			expectedScore:    580, // 7*100 + 3*10 - 3*50 - 0*25 = 700 + 30 - 150 = 580
-		},
-		{
-			name:             "simple route with wildcard",
-			routePath:        "/wildcard/:param_a/static-part/:param_b*",
-			expectedStatic:   2, // "wildcard", "static-part"
-			expectedRegular:  1, // param_a
-			expectedWildcard: 1, // param_b*
-			expectedEndsWith: true,
-			expectedScore:    135, // 2*100 + 1*10 - 1*50 - 1*25
-		},
-	}
-
-	for _, tc := range cases {
-		tc := tc
-		t.Run(tc.name, func(t *testing.T) {
-			spec := calculateRouteSpecificity(tc.routePath)
-			require.Equal(t, tc.expectedStatic, spec.staticSegments, "Static segments mismatch")
-			require.Equal(t, tc.expectedRegular, spec.regularParams, "Regular parameters mismatch")
-			require.Equal(t, tc.expectedWildcard, spec.wildcardParams, "Wildcard parameters mismatch")
-			require.Equal(t, tc.expectedEndsWith, spec.endsWithWildcard, "Ends with wildcard mismatch")
-			require.Equal(t, tc.expectedScore, spec.score, "Specificity score mismatch")
-		})
-	}
-}
-
-func TestRouteConflictDetection(t *testing.T) {
-	cases := []struct {
-		name              string
-		routes            []string
-		expectedConflicts int
-	}{
-		{
-			name:              "no conflicts",
-			routes:            []string{"GET: /users", "GET: /posts", "GET: /comments"},
-			expectedConflicts: 0,
-		},
-		{
-			name:              "simple conflict - wildcard before specific",
-			routes:            []string{"GET: /data/:path*", "GET: /data/specific"},
-			expectedConflicts: 1,
-		},
-		{
-			name: "wildcard conflict - BasicWildcard vs AdvancedWildcard",
-			routes: []string{
-				"GET: /wildcard/:param_a/static-part/:param_b*",
-				"GET: /wildcard/:param_a/static-part/:param_b*/static-part/:param_c/static_part/:param_d*/static_part/:param_e/static_part/:param_f*/static_part",
-			},
-			expectedConflicts: 1,
-		},
-		{
-			name: "multiple conflicts",
-			routes: []string{
-				"GET: /api/:version*",
-				"GET: /api/:version/users/:id*",
-				"GET: /api/:version/users/:id/posts",
-			},
-			expectedConflicts: 3, // route 0 conflicts with routes 1 and 2, route 1 conflicts with route 2
-		},
-		{
-			name: "no conflict - different starting paths",
-			routes: []string{
-				"GET: /users/:id*",
-				"GET: /posts/:id*",
-			},
-			expectedConflicts: 0,
-		},
-		{
-			name: "multiple conflicts, different methods",
-			routes: []string{
-				"GET: /api/:version*",
-				"GET: /api/:version/users/:id*",
-				"POST: /api/:version/users/:id/posts",
-			},
-			expectedConflicts: 1, // route 0 conflicts with routes 1 but not with 2
-		},
-	}
-
-	for _, tc := range cases {
-		tc := tc
-		t.Run(tc.name, func(t *testing.T) {
-			conflicts := detectRouteConflicts(tc.routes)
-			require.Equal(t, tc.expectedConflicts, len(conflicts), "Number of conflicts mismatch")
-		})
-	}
-}
-
-func TestRouteSorting(t *testing.T) {
-	cases := []struct {
-		name          string
-		routes        []string
-		expectedOrder []string
-	}{
-		{
-			name: "sort by specificity",
-			routes: []string{
-				"/data/:path*",       // score: 25
-				"/data/specific",     // score: 200
-				"/data/:id/comments", // score: 210
-			},
-			expectedOrder: []string{
-				"/data/:id/comments", // score: 210 (highest)
-				"/data/specific",     // score: 200
-				"/data/:path*",       // score: 25 (lowest)
-			},
-		},
-		{
-			name: "AdvancedWildcard/BasicWildcard ordering",
-			routes: []string{
-				"/wildcard/:param_a/static-part/:param_b*", // score: 135
-				"/wildcard/:param_a/static-part/:param_b*/static-part/:param_c/static_part/:param_d*/static_part/:param_e/static_part/:param_f*/static_part", // score: 470
-			},
-			expectedOrder: []string{
-				"/wildcard/:param_a/static-part/:param_b*/static-part/:param_c/static_part/:param_d*/static_part/:param_e/static_part/:param_f*/static_part", // score: 470 (higher)
-				"/wildcard/:param_a/static-part/:param_b*", // score: 135 (lower)
-			},
-		},
-	}
-
-	for _, tc := range cases {
-		tc := tc
-		t.Run(tc.name, func(t *testing.T) {
-			sorted := sortRoutesBySpecificity(tc.routes)
-			require.Equal(t, tc.expectedOrder, sorted, "Route sorting order mismatch")
-		})
-	}
-}
-
-func TestRoutesCanConflict(t *testing.T) {
-	cases := []struct {
-		name        string
-		route1      string
-		route2      string
-		canConflict bool
-	}{
-		{
-			name:        "identical routes",
-			route1:      "GET: /users/:id",
-			route2:      "GET: /users/:id",
-			canConflict: true,
-		},
-		{
-			name:        "different static paths",
-			route1:      "GET: /users/:id",
-			route2:      "GET: /posts/:id",
-			canConflict: false,
-		},
-		{
-			name:        "wildcard can conflict with specific",
-			route1:      "GET: /data/:path*",
-			route2:      "GET: /data/specific/path",
-			canConflict: true,
-		},
-		{
-			name:        "wildcard vs simple with same prefix",
-			route1:      "GET: /wildcard/:param_a/static-part/:param_b*",
-			route2:      "GET: /wildcard/:param_a/static-part/:param_b*/static-part/:param_c/static_part",
-			canConflict: true,
-		},
-		{
-			name:        "no conflict - different lengths without wildcards",
-			route1:      "GET: /users/:id",
-			route2:      "GET: /users/:id/posts",
-			canConflict: false,
-		},
-		{
-			name:        "identical routes, different methods",
-			route1:      "GET: /users/:id",
-			route2:      "POST: /users/:id",
-			canConflict: false,
-		},
-	}
-
-	for _, tc := range cases {
-		tc := tc
-		t.Run(tc.name, func(t *testing.T) {
-			result := routesCanConflict(tc.route1, tc.route2)
-			require.Equal(t, tc.canConflict, result, "Conflict detection result mismatch")
-		})
-	}
-}
-
 func TestSplitUrl(t *testing.T) {
 	cases := []struct {
 		url  string
@@ -556,6 +321,10 @@ func TestFindUrlKeys(t *testing.T) {
 			mask: "/wildcard/:param_a/static-part/:param_b*/static-part/:param_c/static_part",
 			want: []string{"param_a", "param_b", "param_c"},
 		},
+		{
+			mask: "/users/:id<int>/files/:name<re:[a-z0-9-]+>",
+			want: []string{"id", "name"},
+		},
 	}
 	for _, tc := range cases {
 		tc := tc
@@ -690,3 +459,87 @@ func TestBuildUrl(t *testing.T) {
 		})
 	}
 }
+
+func TestClassifierWithConstraints(t *testing.T) {
+	cl := newPathClassifier([]string{
+		"/users/:id<int>",
+		"/users/:id<uuid>",
+		"/users/:name<re:[a-z][a-z0-9-]*>",
+		"/users/:other",
+		"/users/:id<uint>/files/:path*",
+	})
+
+	cases := []struct {
+		url             string
+		wantIndex       int
+		wantParam2value map[string]string
+	}{
+		{
+			url:             "/users/-123",
+			wantIndex:       0,
+			wantParam2value: map[string]string{"id": "-123"},
+		},
+		{
+			url:             "/users/0b8b4f5a-3b2c-4d7e-9f10-1a2b3c4d5e6f",
+			wantIndex:       1,
+			wantParam2value: map[string]string{"id": "0b8b4f5a-3b2c-4d7e-9f10-1a2b3c4d5e6f"},
+		},
+		{
+			url:             "/users/alice-1",
+			wantIndex:       2,
+			wantParam2value: map[string]string{"name": "alice-1"},
+		},
+		{
+			url:             "/users/Alice",
+			wantIndex:       3,
+			wantParam2value: map[string]string{"other": "Alice"},
+		},
+		{
+			url:             "/users/42/files/a/b",
+			wantIndex:       4,
+			wantParam2value: map[string]string{"id": "42", "path": "a/b"},
+		},
+		{
+			url:       "/users/-42/files/a/b",
+			wantIndex: -1,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.url, func(t *testing.T) {
+			gotIndex, gotParam2value := cl.Classify(tc.url)
+			require.Equal(t, tc.wantIndex, gotIndex)
+			require.Equal(t, tc.wantParam2value, gotParam2value)
+		})
+	}
+}
+
+func TestParseParam(t *testing.T) {
+	name, wildcard, constraint, isParam := parseParam(":name<re:[a-z]+>")
+	require.True(t, isParam)
+	require.False(t, wildcard)
+	require.Equal(t, "name", name)
+	require.Equal(t, "re:[a-z]+", constraint.source)
+	require.True(t, constraint.Match("abc"))
+	require.False(t, constraint.Match("abc1"))
+
+	name, wildcard, constraint, isParam = parseParam(":path*")
+	require.True(t, isParam)
+	require.True(t, wildcard)
+	require.Equal(t, "path", name)
+	require.Nil(t, constraint)
+
+	_, _, _, isParam = parseParam("static")
+	require.False(t, isParam)
+
+	require.Panics(t, func() { parseParam(":id<float>") })
+	require.Panics(t, func() { parseParam(":id<re:[a-z>") })
+	require.Panics(t, func() { parseParam(":id<int>x") })
+	require.Panics(t, func() { parseParam(":path*<int>") })
+}
+
+func TestStripParamConstraints(t *testing.T) {
+	require.Equal(t, "/users/:id/files/:name/:path*", stripParamConstraints("/users/:id<int>/files/:name<re:[a-z0-9-]+>/:path*"))
+	require.Equal(t, "/users/:id", stripParamConstraints("/users/:id"))
+}
@@ -0,0 +1,270 @@
+package api2
+
+import (
+	"encoding"
+	"encoding/base64"
+	"fmt"
+	"reflect"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ValueCodecs is a registry of codecs used to convert values of query,
+// header, cookie and url fields to strings and back. Use RegisterValueCodec
+// to add a codec for some type.
+//
+// Codecs are looked up by exact type of the field. If the type has no codec
+// in the registry, DefaultValueCodecs is consulted. If there is no codec
+// there either, encoding.TextMarshaler and encoding.TextUnmarshaler are used,
+// if implemented, then strconv for numbers (integers are decimal) and booleans
+// and fmt.Sscanf for other kinds of types.
+type ValueCodecs struct {
+	codecs sync.Map // reflect.Type -> *valueCodec.
+}
+
+type valueCodec struct {
+	encode func(v reflect.Value) (string, error)
+	decode func(value string, v reflect.Value) error
+}
+
+// NewValueCodecs creates an empty registry of value codecs. Assign it to
+// JsonTransport.ValueCodecs to use codecs only in particular routes.
+func NewValueCodecs() *ValueCodecs {
+	return &ValueCodecs{}
+}
+
+// DefaultValueCodecs is the global registry of value codecs. It is used by
+// all transports in addition to their own registries. It contains codecs
+// for time.Duration (formatted as "5s") and []byte (standard base64).
+var DefaultValueCodecs = NewValueCodecs()
+
+// RegisterValueCodec registers a codec for values of type T in the registry.
+// If codecs is nil, DefaultValueCodecs is used.
+//
+// Empty values are not passed to decode: the field keeps its zero value.
+func RegisterValueCodec[T any](codecs *ValueCodecs, encode func(T) (string, error), decode func(string) (T, error)) {
+	if codecs == nil {
+		codecs = DefaultValueCodecs
+	}
+	codecs.codecs.Store(reflect.TypeOf((*T)(nil)).Elem(), &valueCodec{
+		encode: func(v reflect.Value) (string, error) {
+			return encode(v.Interface().(T))
+		},
+		decode: func(value string, v reflect.Value) error {
+			obj, err := decode(value)
+			if err != nil {
+				return err
+			}
+			v.Set(reflect.ValueOf(&obj).Elem())
+			return nil
+		},
+	})
+}
+
+// RegisterTimeValueCodec registers a codec for time.Time using the layout
+// (see time.Layout) in the registry. If codecs is nil, DefaultValueCodecs
+// is used. Without it time.Time is encoded in RFC 3339 format.
+func RegisterTimeValueCodec(codecs *ValueCodecs, layout string) {
+	RegisterValueCodec(codecs, func(t time.Time) (string, error) {
+		return t.Format(layout), nil
+	}, func(value string) (time.Time, error) {
+		return time.Parse(layout, value)
+	})
+}
+
+func init() {
+	RegisterValueCodec(DefaultValueCodecs, func(d time.Duration) (string, error) {
+		return d.String(), nil
+	}, func(value string) (time.Duration, error) {
+		d, err := time.ParseDuration(value)
+		if err == nil {
+			return d, nil
+		}
+		// Compatibility with clients passing the number of nanoseconds.
+		ns, err2 := strconv.ParseInt(value, 10, 64)
+		if err2 != nil {
+			return 0, err
+		}
+		return time.Duration(ns), nil
+	})
+	RegisterValueCodec(DefaultValueCodecs, func(b []byte) (string, error) {
+		return base64.StdEncoding.EncodeToString(b), nil
+	}, base64.StdEncoding.DecodeString)
+}
+
+func (c *ValueCodecs) lookup(t reflect.Type) *valueCodec {
+	if c != nil && c != DefaultValueCodecs {
+		if codec, has := c.codecs.Load(t); has {
+			return codec.(*valueCodec)
+		}
+	}
+	if codec, has := DefaultValueCodecs.codecs.Load(t); has {
+		return codec.(*valueCodec)
+	}
+	return nil
+}
+
+// fieldCodec converts values of a query, header, cookie or url field of some
+// type. It is compiled once per field in prepare, so that the kind of the
+// type and the interfaces it implements are not inspected on each call.
+// Codecs registered in ValueCodecs take precedence over it.
+type fieldCodec struct {
+	typ    reflect.Type
+	encode func(v reflect.Value) (string, error)
+	decode func(value string, v reflect.Value) error
+}
+
+var (
+	textMarshalerType   = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	stringerType        = reflect.TypeOf((*fmt.Stringer)(nil)).Elem()
+	formatterType       = reflect.TypeOf((*fmt.Formatter)(nil)).Elem()
+)
+
+func compileFieldCodec(t reflect.Type) *fieldCodec {
+	return &fieldCodec{
+		typ:    t,
+		encode: compileValueEncoder(t),
+		decode: compileValueDecoder(t),
+	}
+}
+
+func sprintValue(v reflect.Value) (string, error) {
+	return fmt.Sprintf("%v", v.Interface()), nil
+}
+
+func compileValueEncoder(t reflect.Type) func(v reflect.Value) (string, error) {
+	if t.Implements(textMarshalerType) {
+		return func(v reflect.Value) (string, error) {
+			valueBytes, err := v.Interface().(encoding.TextMarshaler).MarshalText()
+			if err != nil {
+				return "", err
+			}
+			return string(valueBytes), nil
+		}
+	}
+	if t.Implements(stringerType) || t.Implements(formatterType) || t.Implements(errorType) {
+		// fmt calls the methods.
+		return sprintValue
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return func(v reflect.Value) (string, error) {
+			return v.String(), nil
+		}
+	case reflect.Bool:
+		return func(v reflect.Value) (string, error) {
+			return strconv.FormatBool(v.Bool()), nil
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return func(v reflect.Value) (string, error) {
+			return strconv.FormatInt(v.Int(), 10), nil
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return func(v reflect.Value) (string, error) {
+			return strconv.FormatUint(v.Uint(), 10), nil
+		}
+	case reflect.Float32, reflect.Float64:
+		bits := t.Bits()
+		return func(v reflect.Value) (string, error) {
+			return strconv.FormatFloat(v.Float(), 'g', -1, bits), nil
+		}
+	}
+	return sprintValue
+}
+
+func compileValueDecoder(t reflect.Type) func(value string, v reflect.Value) error {
+	if reflect.PointerTo(t).Implements(textUnmarshalerType) {
+		return func(value string, v reflect.Value) error {
+			return v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(value))
+		}
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return func(value string, v reflect.Value) error {
+			v.SetString(value)
+			return nil
+		}
+	case reflect.Bool:
+		return func(value string, v reflect.Value) error {
+			if value == "" {
+				return nil
+			}
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				return err
+			}
+			v.SetBool(b)
+			return nil
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		// Integers are decimal, unlike in fmt.Sscanf: prefixes 0x, 0o and 0b
+		// and underscores are rejected and "010" is 10, not 8.
+		bits := t.Bits()
+		return func(value string, v reflect.Value) error {
+			if value == "" {
+				return nil
+			}
+			i, err := strconv.ParseInt(value, 10, bits)
+			if err != nil {
+				return err
+			}
+			v.SetInt(i)
+			return nil
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		bits := t.Bits()
+		return func(value string, v reflect.Value) error {
+			if value == "" {
+				return nil
+			}
+			u, err := strconv.ParseUint(value, 10, bits)
+			if err != nil {
+				return err
+			}
+			v.SetUint(u)
+			return nil
+		}
+	case reflect.Float32, reflect.Float64:
+		bits := t.Bits()
+		return func(value string, v reflect.Value) error {
+			if value == "" {
+				return nil
+			}
+			f, err := strconv.ParseFloat(value, bits)
+			if err != nil {
+				return err
+			}
+			v.SetFloat(f)
+			return nil
+		}
+	}
+	return func(value string, v reflect.Value) error {
+		if value == "" {
+			return nil
+		}
+		_, err := fmt.Sscanf(value, "%v", v.Addr().Interface())
+		return err
+	}
+}
+
+// encode converts the value of a query, header, cookie or url field to string.
+func (c *ValueCodecs) encode(fc *fieldCodec, v reflect.Value) (string, error) {
+	if codec := c.lookup(fc.typ); codec != nil {
+		return codec.encode(v)
+	}
+	return fc.encode(v)
+}
+
+// decode parses the value of a query, header, cookie or url field.
+// v must be addressable.
+func (c *ValueCodecs) decode(fc *fieldCodec, value string, v reflect.Value) error {
+	if codec := c.lookup(fc.typ); codec != nil {
+		if value == "" {
+			return nil
+		}
+		return codec.decode(value, v)
+	}
+	return fc.decode(value, v)
+}
@@ -0,0 +1,155 @@
+package api2
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValueCodecsDecode(t *testing.T) {
+	type Direction int
+
+	cases := []struct {
+		value     string
+		objPtr    interface{}
+		want      interface{}
+		wantError bool
+	}{
+		{value: "5s", objPtr: new(time.Duration), want: 5 * time.Second},
+		{value: "1500000000", objPtr: new(time.Duration), want: 1500 * time.Millisecond},
+		{value: "5 seconds", objPtr: new(time.Duration), wantError: true},
+		{value: "AQID", objPtr: new([]byte), want: []byte{1, 2, 3}},
+		{value: "AQID!", objPtr: new([]byte), wantError: true},
+		{value: "42", objPtr: new(uint16), want: uint16(42)},
+		{value: "42abc", objPtr: new(uint16), wantError: true},
+		{value: "-1", objPtr: new(uint), wantError: true},
+		{value: "70000", objPtr: new(uint16), wantError: true},
+		{value: "-7", objPtr: new(int), want: -7},
+		{value: "7 7", objPtr: new(int), wantError: true},
+		{value: "010", objPtr: new(int), want: 10},
+		{value: "0x1F", objPtr: new(int), wantError: true},
+		{value: "0o17", objPtr: new(int64), wantError: true},
+		{value: "1_000", objPtr: new(int), wantError: true},
+		{value: "010", objPtr: new(uint), want: uint(10)},
+		{value: "0x1F", objPtr: new(uint32), wantError: true},
+		{value: "2", objPtr: new(Direction), want: Direction(2)},
+		{value: "true", objPtr: new(bool), want: true},
+		{value: "truex", objPtr: new(bool), wantError: true},
+		{value: "1.5", objPtr: new(float64), want: 1.5},
+		{value: "1.5.5", objPtr: new(float64), wantError: true},
+		{value: "foo bar", objPtr: new(string), want: "foo bar"},
+		{value: "", objPtr: new(int), want: 0},
+		{value: "", objPtr: new(time.Duration), want: time.Duration(0)},
+		{value: "|||", objPtr: new(CustomType), want: CustomType(3)},
+	}
+
+	for _, tc := range cases {
+		v := reflect.ValueOf(tc.objPtr).Elem()
+		err := DefaultValueCodecs.decode(compileFieldCodec(v.Type()), tc.value, v)
+		if tc.wantError {
+			if err == nil {
+				t.Errorf("decode(%q) into %s: expected error, got %v", tc.value, v.Type(), v.Interface())
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("decode(%q) into %s failed: %v", tc.value, v.Type(), err)
+			continue
+		}
+		if !reflect.DeepEqual(v.Interface(), tc.want) {
+			t.Errorf("decode(%q) into %s: got %#v, want %#v", tc.value, v.Type(), v.Interface(), tc.want)
+		}
+	}
+}
+
+func TestValueCodecsEncode(t *testing.T) {
+	cases := []struct {
+		obj  interface{}
+		want string
+	}{
+		{obj: 5 * time.Second, want: "5s"},
+		{obj: []byte{1, 2, 3}, want: "AQID"},
+		{obj: uint16(42), want: "42"},
+		{obj: true, want: "true"},
+		{obj: CustomType(2), want: "||"},
+		{obj: time.Date(2020, time.July, 10, 11, 30, 0, 0, time.UTC), want: "2020-07-10T11:30:00Z"},
+	}
+
+	for _, tc := range cases {
+		v := reflect.ValueOf(tc.obj)
+		got, err := DefaultValueCodecs.encode(compileFieldCodec(v.Type()), v)
+		if err != nil {
+			t.Errorf("encode(%#v) failed: %v", tc.obj, err)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("encode(%#v): got %q, want %q", tc.obj, got, tc.want)
+		}
+	}
+}
+
+type upperString string
+
+func TestValueCodecsPerTransport(t *testing.T) {
+	codecs := NewValueCodecs()
+	RegisterTimeValueCodec(codecs, time.DateOnly)
+	RegisterValueCodec(codecs, func(s upperString) (string, error) {
+		return strings.ToUpper(string(s)), nil
+	}, func(value string) (upperString, error) {
+		return upperString(strings.ToLower(value)), nil
+	})
+	transport := &JsonTransport{ValueCodecs: codecs}
+
+	type Request struct {
+		Day     time.Time     `query:"day"`
+		Name    upperString   `header:"X-Name"`
+		Timeout time.Duration `query:"timeout"`
+	}
+
+	req := &Request{
+		Day:     time.Date(2020, time.July, 10, 0, 0, 0, 0, time.UTC),
+		Name:    "alice",
+		Timeout: 90 * time.Second,
+	}
+	httpReq, err := transport.EncodeRequest(context.Background(), http.MethodGet, "http://example.com/foo", req)
+	if err != nil {
+		t.Fatalf("EncodeRequest failed: %v", err)
+	}
+	wantQuery := url.Values{"day": {"2020-07-10"}, "timeout": {"1m30s"}}
+	if !reflect.DeepEqual(httpReq.URL.Query(), wantQuery) {
+		t.Errorf("query: got %v, want %v", httpReq.URL.Query(), wantQuery)
+	}
+	if got := httpReq.Header.Get("X-Name"); got != "ALICE" {
+		t.Errorf("header: got %q, want %q", got, "ALICE")
+	}
+
+	body, err := io.ReadAll(httpReq.Body)
+	if err != nil {
+		t.Fatalf("failed to read body: %v", err)
+	}
+	serverReq := httptest.NewRequest(http.MethodGet, httpReq.URL.String(), bytes.NewReader(body))
+	serverReq.Header = httpReq.Header
+	got := &Request{}
+	if _, err := transport.DecodeRequest(context.Background(), serverReq, got); err != nil {
+		t.Fatalf("DecodeRequest failed: %v", err)
+	}
+	if !reflect.DeepEqual(got, req) {
+		t.Errorf("got %#v, want %#v", got, req)
+	}
+
+	// The codec of the transport does not affect other transports.
+	otherReq, err := DefaultTransport.EncodeRequest(context.Background(), http.MethodGet, "http://example.com/foo", req)
+	if err != nil {
+		t.Fatalf("EncodeRequest failed: %v", err)
+	}
+	if got := otherReq.URL.Query().Get("day"); got != "2020-07-10T00:00:00Z" {
+		t.Errorf("query of DefaultTransport: got %q", got)
+	}
+}
@@ -17,7 +17,10 @@ const yamlRoutes = `{{- range $key, $services := .}}
    {{- range $info := $methods}}
     {{$info.FnInfo.Method}}:
       method: "{{.Method}}"
-      path: "{{.Path}}"{{end}}
+      path: "{{.Path}}"
+      {{- if .Groups}}
+      groups: [{{range $i, $g := .Groups}}{{if $i}}, {{end}}"{{$g}}"{{end}}]
+      {{- end}}{{end}}
 	{{- end}}
 {{- end}}
 `
@@ -64,6 +67,7 @@ func genYamlRoutes(w io.Writer, routes []Route, p *typegen.Parser, options *Yaml
 		FnInfo      FnInfo
 		TypeInfoReq string
 		TypeInfoRes string
+		Groups      []string
 	}
 	m := map[string]map[string][]routeDef{}
 OUTER:
@@ -97,6 +101,7 @@ OUTER:
 			FnInfo:      fnInfo,
 			TypeInfoReq: string(TypeInfoReq),
 			TypeInfoRes: string(TypeInfoRes),
+			Groups:      RouteGroups(&route),
 		}
 
 		if _, ok := m[fnInfo.PkgName]; !ok {